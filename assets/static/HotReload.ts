@@ -1,4 +1,3 @@
-
 import { SocketClient, SocketPayload } from "./SocketClient.js";
 
 interface ReloadCSSPayloadData {
@@ -6,26 +5,165 @@ interface ReloadCSSPayloadData {
     css: string;
 }
 
-function reloadCSS(e: SocketPayload) {
-    const { id, css } = <ReloadCSSPayloadData>JSON.parse(e.data);
-    let style: HTMLStyleElement = document.querySelector("#" + CSS.escape(id));
-    if (!style) {
-        // new style element
-        style = document.createElement('style');
-        style.id = id;
-        style.type = 'text/css';
-        document.getElementsByTagName('head')[0].appendChild(style);
-        style.appendChild(document.createTextNode(css));
+interface ReloadTemplatePayloadData {
+    id: string;
+    template: string;
+}
+
+interface ReloadValuePayloadData {
+    id: string;
+    value: any;
+}
+
+/**
+ * Dispatched on window when a template changes, so that frameworks can swap it in place, e.g.
+ *     window.addEventListener("swarm:reload-template", (e: CustomEvent) => {
+ *         if (recompile(e.detail.id, e.detail.template)) e.preventDefault();
+ *     });
+ * If no listener calls preventDefault(), the page is reloaded instead
+ */
+const reloadTemplateEvent = "swarm:reload-template";
+
+/**
+ * Dispatched on window when the value of a module (e.g. a JSON file) changes, with { id, value } as its detail.
+ * The new value is used when the module is next imported, but modules that already imported it keep the old one,
+ * so the page is reloaded unless a listener calls preventDefault()
+ */
+const reloadValueEvent = "swarm:reload-value";
+
+/** Remembers a full reload across the page load, so that it can be acknowledged afterwards */
+const pendingReloadKey = "swarm-pending-reload";
+
+/** How long to wait for more scripts to load, before registering them */
+const registerDelay = 500;
+
+// errors while the page loads are reported when acknowledging a full reload
+const errors: string[] = [];
+window.addEventListener("error", (e: ErrorEvent) => errors.push(e.message));
+window.addEventListener("unhandledrejection", (e: PromiseRejectionEvent) => errors.push(String(e.reason)));
+
+/** Lists the URL paths of the scripts this page has loaded from swarm, so that it's only sent reloads that affect it */
+function loadedBundles(): string[] {
+    const urls = Array.from(document.querySelectorAll("script[src]")).map(script => (<HTMLScriptElement>script).src);
+    performance.getEntriesByType("resource").forEach(entry => urls.push(entry.name));
+
+    const bundles: string[] = [];
+    urls.forEach(url => {
+        const parsed = new URL(url, location.href);
+        if (parsed.host === location.host && /\.js$/.test(parsed.pathname) && bundles.indexOf(parsed.pathname) < 0) {
+            bundles.push(parsed.pathname);
+        }
+    });
+    return bundles;
+}
+
+let registeredBundles = "";
+function register(sc: SocketClient) {
+    const bundles = loadedBundles();
+    const base = document.querySelector("base");
+    registeredBundles = bundles.join();
+    sc.send("register", { url: location.href, baseHref: base ? base.getAttribute("href") : "", bundles: bundles });
+}
+
+/** Registers again once scripts stop loading, e.g. bundles loaded by SystemJS after the socket connected */
+let registerTimer = 0;
+function registerWhenChanged(sc: SocketClient) {
+    clearTimeout(registerTimer);
+    registerTimer = setTimeout(() => loadedBundles().join() !== registeredBundles && register(sc), registerDelay);
+}
+
+/** Acknowledges a full reload once the page has loaded, reporting any errors it caused */
+function acknowledgeReload(sc: SocketClient) {
+    const id = sessionStorage.getItem(pendingReloadKey);
+    if (!id) {
+        return;
     }
-    else {
-        // replace css within existing style element
-        style.childNodes[0].textContent = css;
+    sessionStorage.removeItem(pendingReloadKey);
+    const ack = () => sc.send("ack", { id: Number(id), error: errors.join("\n") || undefined });
+    document.readyState === "complete" ? ack() : window.addEventListener("load", ack);
+}
+
+function reload(e: SocketPayload) {
+    sessionStorage.setItem(pendingReloadKey, String(e.id));
+    window.location.reload();
+}
+
+function reloadCSS(e: SocketPayload, sc: SocketClient) {
+    try {
+        const { id, css } = <ReloadCSSPayloadData>JSON.parse(e.data);
+        let style: HTMLStyleElement = document.querySelector("#" + CSS.escape(id));
+        if (!style) {
+            // new style element
+            style = document.createElement('style');
+            style.id = id;
+            style.type = 'text/css';
+            document.getElementsByTagName('head')[0].appendChild(style);
+            style.appendChild(document.createTextNode(css));
+        }
+        else {
+            // replace css within existing style element
+            style.childNodes[0].textContent = css;
+        }
+        sc.send("ack", { id: e.id });
+    }
+    catch (err) {
+        sc.send("ack", { id: e.id, error: String(err) });
+    }
+}
+
+/** Replaces a module's value in SystemJS's registry, so that it's used when next imported */
+function replaceModule(id: string, value: any) {
+    const System = (<any>window).System;
+    if (!System || !System.newModule) {
+        return;
     }
+    const key = System.normalizeSync ? System.normalizeSync(id) : id;
+    const module = System.newModule({ default: value, __useDefault: value });
+    System.registry ? System.registry.set(key, module) : System.set(key, module);
+}
+
+/** Swaps a module's value, then lets listeners of the event apply it to the page, or reloads if none do */
+function swapModule(e: SocketPayload, sc: SocketClient, eventName: string, id: string, value: any, detail: any) {
+    let error: string;
+    try {
+        replaceModule(id, value);
+    }
+    catch (err) {
+        error = String(err);
+    }
+
+    const event = new CustomEvent(eventName, { detail: detail, cancelable: true });
+    window.dispatchEvent(event);
+    if (!event.defaultPrevented) {
+        // nothing swapped the module in place
+        reload(e);
+        return;
+    }
+    sc.send("ack", { id: e.id, error: error });
+}
+
+function reloadTemplate(e: SocketPayload, sc: SocketClient) {
+    const { id, template } = <ReloadTemplatePayloadData>JSON.parse(e.data);
+    swapModule(e, sc, reloadTemplateEvent, id, template, { id, template });
+}
+
+function reloadValue(e: SocketPayload, sc: SocketClient) {
+    const { id, value } = <ReloadValuePayloadData>JSON.parse(e.data);
+    swapModule(e, sc, reloadValueEvent, id, value, { id, value });
 }
 
 const sc = new SocketClient();
+sc.onOpen(client => {
+    register(client);
+    acknowledgeReload(client);
+});
 sc.on(e => {
-    e.type == "reload-css" && reloadCSS(e);
-    e.type == "reload" && window.location.reload();
+    e.type == "reload-css" && reloadCSS(e, sc);
+    e.type == "reload-template" && reloadTemplate(e, sc);
+    e.type == "reload-value" && reloadValue(e, sc);
+    e.type == "reload" && reload(e);
 });
-sc.connect();
\ No newline at end of file
+if ("PerformanceObserver" in window) {
+    new PerformanceObserver(() => registerWhenChanged(sc)).observe({ entryTypes: ["resource"] });
+}
+sc.connect();
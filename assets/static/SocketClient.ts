@@ -9,7 +9,12 @@ export class EventEmitter<T> {
 	emit = (event: T) => this.listeners.forEach(listener => listener(event));
 }
 
+/** The version of the hot reload protocol, which must match swarm's */
+export const protocolVersion = 2;
+
 export interface SocketPayload {
+    v: number
+    id: number
     type: string
     data: string
 }
@@ -19,7 +24,9 @@ export type OnOpenFn = (client: SocketClient) => void;
 export class SocketClient {
 	url: string;
 	emitter: EventEmitter<SocketPayload>;
+	openEmitter: EventEmitter<SocketClient>;
 	client: WebSocket;
+	warnedAboutVersion = false;
 
 	constructor() {
 		const port = window.location.port;
@@ -27,6 +34,7 @@ export class SocketClient {
 		const domain = location.hostname || "localhost";
 		this.url = `${protocol}${domain}:${port}/__swarm__/ws`;
 		this.emitter = new EventEmitter();
+		this.openEmitter = new EventEmitter();
 	}
 	reconnect() {
 		setTimeout(() => this.connect(), 5000)
@@ -36,6 +44,11 @@ export class SocketClient {
 		this.emitter.on(fn);
 	}
 
+	/** Listens for each time the socket (re)connects */
+	onOpen(fn: OnOpenFn) {
+		this.openEmitter.on(fn);
+	}
+
 	connect() {
 		console.log("%cConnecting to websocket at " + this.url, "color: #237abe");
 		setTimeout(() => {
@@ -44,16 +57,29 @@ export class SocketClient {
 		}, 0);
     }
     
-	send(eventName, data) {
-		if (this.client.readyState === 1) {
-			this.client.send(JSON.stringify({ event: eventName, data: data || {} }));
+	send(type: string, data?: any) {
+		if (this.client && this.client.readyState === 1) {
+			this.client.send(JSON.stringify({ v: protocolVersion, type: type, data: data || {} }));
 		}
 	}
 
+	/** Emits a message from the server, unless it uses a different version of the protocol */
+	private receive(payload: SocketPayload) {
+		if (payload.v !== protocolVersion) {
+			if (!this.warnedAboutVersion) {
+				console.warn(`swarm uses hot reload protocol version ${payload.v}, but this page uses version ${protocolVersion}: reload the page to update it`);
+				this.warnedAboutVersion = true;
+			}
+			return;
+		}
+		this.emitter.emit(payload);
+	}
+
 	/** Wires up the socket client messages to be emitted on our event emitter */
 	private bindEvents() {
-		this.client.onopen = event => { console.log("%cConnected", "color: #237abe"); this.client.onclose = (event: CloseEvent) => this.reconnect(); };
+		this.client.onopen = event => { console.log("%cConnected", "color: #237abe"); this.client.onclose = (event: CloseEvent) => this.reconnect(); this.openEmitter.emit(this); };
 		this.client.onerror = (event: any) => console.error(event);
-		this.client.onmessage = (event: MessageEvent) => event.data && this.emitter.emit(<SocketPayload>JSON.parse(event.data));
+		// several messages may arrive at once, separated by newlines
+		this.client.onmessage = (event: MessageEvent) => event.data && event.data.split("\n").forEach(line => this.receive(<SocketPayload>JSON.parse(line)));
 	}
-}
\ No newline at end of file
+}
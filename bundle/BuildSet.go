@@ -0,0 +1,225 @@
+package bundle
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/logging"
+	"github.com/mrcrowl/swarm/monitor"
+	"github.com/mrcrowl/swarm/source"
+)
+
+// BuildSet runs several builds at once, each with its own ModuleSet, sharing one Workspace and its file cache
+type BuildSet struct {
+	workspace  *source.Workspace
+	fileCache  *source.FileCache
+	mutex      sync.RWMutex // guards names and moduleSets, which change when switching builds
+	buildMutex sync.Mutex   // serialises bundling, reconfiguring and switching builds, since builds share cached Files
+	names      []string
+	moduleSets map[string]*ModuleSet
+}
+
+// CreateBuildSet loads the build description file of each build, and creates a ModuleSet for it
+func CreateBuildSet(ws *source.Workspace, builds map[string]*config.RuntimeConfig) (*BuildSet, error) {
+	fileCache := source.NewFileCache()
+	buildSet := &BuildSet{
+		workspace:  ws.WithFileCache(fileCache),
+		fileCache:  fileCache,
+		moduleSets: make(map[string]*ModuleSet, len(builds)),
+	}
+
+	for name := range builds {
+		buildSet.names = append(buildSet.names, name)
+	}
+	sort.Strings(buildSet.names)
+
+	for _, name := range buildSet.names {
+		set, err := buildSet.loadModuleSet(name, builds[name])
+		if err != nil {
+			return nil, err
+		}
+		buildSet.moduleSets[name] = set
+	}
+
+	return buildSet, nil
+}
+
+// loadModuleSet loads the build description file of a build, and creates its ModuleSet
+func (buildSet *BuildSet) loadModuleSet(name string, runtimeConfig *config.RuntimeConfig) (*ModuleSet, error) {
+	moduleDescrs, err := config.LoadBuildDescriptionFile(runtimeConfig.BuildPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load build description file for '%s': %s", name, err)
+	}
+
+	normalisedModules := moduleDescrs.NormaliseModules(buildSet.workspace.RootPath())
+	if err := validateExcludes(normalisedModules); err != nil {
+		return nil, fmt.Errorf("Invalid build description file for '%s': %s", name, err)
+	}
+
+	return createModuleSetWithVariants(buildSet.workspace, normalisedModules, runtimeConfig), nil
+}
+
+// SetBuilds switches to a different selection of builds.  Builds that were already running keep their ModuleSet,
+// and the others are loaded, ready to be bundled by the next NotifyChanges.  The builds are left unchanged on error
+func (buildSet *BuildSet) SetBuilds(builds map[string]*config.RuntimeConfig) error {
+	buildSet.buildMutex.Lock()
+	defer buildSet.buildMutex.Unlock()
+
+	var names []string
+	for name := range builds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	moduleSets := make(map[string]*ModuleSet, len(builds))
+	for _, name := range names {
+		if set := buildSet.ModuleSet(name); set != nil {
+			moduleSets[name] = set
+			continue
+		}
+		set, err := buildSet.loadModuleSet(name, builds[name])
+		if err != nil {
+			return err
+		}
+		moduleSets[name] = set
+	}
+
+	buildSet.mutex.Lock()
+	defer buildSet.mutex.Unlock()
+	buildSet.names = names
+	buildSet.moduleSets = moduleSets
+	return nil
+}
+
+// Rebuild discards every cached file, so that the next NotifyChanges re-reads and rebundles all of the builds
+func (buildSet *BuildSet) Rebuild() {
+	buildSet.buildMutex.Lock()
+	defer buildSet.buildMutex.Unlock()
+
+	buildSet.fileCache.Clear()
+	for _, set := range buildSet.moduleSetsInOrder() {
+		set.Rebuild()
+	}
+}
+
+// orderedModuleSets gets the names of the builds, and the ModuleSet of each, in the same order as Names
+func (buildSet *BuildSet) orderedModuleSets() ([]string, []*ModuleSet) {
+	buildSet.mutex.RLock()
+	defer buildSet.mutex.RUnlock()
+
+	sets := make([]*ModuleSet, len(buildSet.names))
+	for i, name := range buildSet.names {
+		sets[i] = buildSet.moduleSets[name]
+	}
+	return append([]string(nil), buildSet.names...), sets
+}
+
+// moduleSetsInOrder gets the ModuleSet of each build, in the same order as Names
+func (buildSet *BuildSet) moduleSetsInOrder() []*ModuleSet {
+	_, sets := buildSet.orderedModuleSets()
+	return sets
+}
+
+// Names gets the names of the builds, in alphabetical order
+func (buildSet *BuildSet) Names() []string {
+	names, _ := buildSet.orderedModuleSets()
+	return names
+}
+
+// ModuleSet gets the ModuleSet for a build, or nil if there is no build with that name
+func (buildSet *BuildSet) ModuleSet(name string) *ModuleSet {
+	buildSet.mutex.RLock()
+	defer buildSet.mutex.RUnlock()
+	return buildSet.moduleSets[name]
+}
+
+// BaseHrefs gets the distinct base hrefs of the builds, in the same order as Names
+func (buildSet *BuildSet) BaseHrefs() []string {
+	var baseHrefs []string
+	seen := map[string]bool{}
+	for _, set := range buildSet.moduleSetsInOrder() {
+		baseHref := set.runtimeConfig.BaseHref
+		if !seen[baseHref] {
+			seen[baseHref] = true
+			baseHrefs = append(baseHrefs, baseHref)
+		}
+	}
+	return baseHrefs
+}
+
+// NotifyChanges invalidates any changed files, then passes the EventChangeset to every build's ModuleSet
+func (buildSet *BuildSet) NotifyChanges(changes *monitor.EventChangeset) {
+	buildSet.buildMutex.Lock()
+	defer buildSet.buildMutex.Unlock()
+
+	if changes != nil {
+		for _, change := range changes.Changes() {
+			buildSet.fileCache.Invalidate(change.AbsoluteFilepath())
+		}
+	}
+
+	for _, set := range buildSet.moduleSetsInOrder() {
+		set.NotifyChanges(changes)
+	}
+}
+
+// Reconfigure applies a changed build description (and RuntimeConfig) to one of the builds.
+// Returns the names of the modules that were rebuilt, and of those that were removed
+func (buildSet *BuildSet) Reconfigure(name string, moduleDescriptions []*config.NormalisedModuleDescription, runtimeConfig *config.RuntimeConfig) ([]string, []string, error) {
+	buildSet.buildMutex.Lock()
+	defer buildSet.buildMutex.Unlock()
+
+	set := buildSet.ModuleSet(name)
+	if set == nil {
+		return nil, nil, fmt.Errorf("Unknown build '%s'", name)
+	}
+	return set.Reconfigure(moduleDescriptions, runtimeConfig)
+}
+
+// FindFileByPath finds and returns a file by path name, from any of the builds
+func (buildSet *BuildSet) FindFileByPath(path string) *source.File {
+	for _, set := range buildSet.moduleSetsInOrder() {
+		if file := set.FindFileByPath(path); file != nil {
+			return file
+		}
+	}
+	return nil
+}
+
+// FilesIncluding finds the files whose contents inline a file (e.g. CSS files which @import it), from any of the builds
+func (buildSet *BuildSet) FilesIncluding(relativePath string) []*source.File {
+	var files []*source.File
+	for _, set := range buildSet.moduleSetsInOrder() {
+		files = append(files, set.FilesIncluding(relativePath)...)
+	}
+	return files
+}
+
+// BundlesContaining gets the URL paths of the bundles which include a file, from any of the builds
+func (buildSet *BuildSet) BundlesContaining(relativePath string) []string {
+	var bundles []string
+	for _, set := range buildSet.moduleSetsInOrder() {
+		bundles = append(bundles, set.BundlesContaining(relativePath)...)
+	}
+	return bundles
+}
+
+// GenerateHTTPHandlers combines the http.HandlerFunc's of every build
+func (buildSet *BuildSet) GenerateHTTPHandlers() map[string]http.HandlerFunc {
+	handlers := map[string]http.HandlerFunc{}
+	owners := map[string]string{}
+	names, sets := buildSet.orderedModuleSets()
+	for i, name := range names {
+		for url, handler := range sets[i].GenerateHTTPHandlers() {
+			if owner, found := owners[url]; found {
+				logging.Warn("duplicate-bundle", logging.Fields{"file": url, "build": name}, "%s is bundled by both the '%s' and '%s' builds; serving '%s'", url, owner, name, name)
+			}
+			handlers[url] = handler
+			owners[url] = name
+		}
+	}
+	return handlers
+}
@@ -0,0 +1,201 @@
+package bundle
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/monitor"
+	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/rjeczalik/notify"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateBuildSet(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	buildPath := testutil.MakeSubdirectoryTree(workspacePath, "build")
+	testutil.WriteTextFile(buildPath, "systemjs_build_app.json", `{"modules": [{ "name": "app/main" }], "base": ""}`)
+	testutil.WriteTextFile(buildPath, "systemjs_build_controlpanel.json", `{"modules": [{ "name": "controlpanel/main" }], "base": ""}`)
+	appPath := testutil.MakeSubdirectoryTree(workspacePath, "app")
+	controlPanelPath := testutil.MakeSubdirectoryTree(workspacePath, "controlpanel")
+	commonPath := testutil.MakeSubdirectoryTree(workspacePath, "common")
+	testutil.WriteTextFile(appPath, "main.js", `System.register(["../common/util"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(controlPanelPath, "main.js", `System.register(["../common/util"], function (exports_1, context_1) {`)
+	utilFilepath := testutil.WriteTextFile(commonPath, "util.js", `System.register([], function (exports_1, context_1) {`)
+
+	builds := map[string]*config.RuntimeConfig{
+		"app":          config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_app.json"), "app"),
+		"controlpanel": config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_controlpanel"), "controlpanel"),
+	}
+	buildSet, err := CreateBuildSet(source.NewWorkspace(workspacePath), builds)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"app", "controlpanel"}, buildSet.Names())
+	assert.Equal(t, []string{"app", "controlpanel"}, buildSet.BaseHrefs())
+
+	// files used by both builds are shared
+	appUtil := buildSet.ModuleSet("app").getModule("app/main").fileset.Get("common/util")
+	controlPanelUtil := buildSet.ModuleSet("controlpanel").getModule("controlpanel/main").fileset.Get("common/util")
+	assert.NotNil(t, appUtil)
+	assert.True(t, appUtil == controlPanelUtil)
+
+	buildSet.NotifyChanges(nil)
+	assert.True(t, appUtil.Loaded())
+	handlers := buildSet.GenerateHTTPHandlers()
+	assert.Contains(t, handlers, "/app/main.js")
+	assert.Contains(t, handlers, "/controlpanel/main.js")
+
+	changes := monitor.NewEventChangeset()
+	changes.Add(notify.Write, utilFilepath)
+	buildSet.NotifyChanges(changes)
+	assert.True(t, appUtil.Loaded())
+	assert.False(t, changes.SkipHotReload())
+}
+
+func TestCreateBuildSetMissingBuildFile(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+
+	builds := map[string]*config.RuntimeConfig{
+		"app": config.NewRuntimeConfig(filepath.Join(workspacePath, "missing.json"), "app"),
+	}
+	_, err := CreateBuildSet(source.NewWorkspace(workspacePath), builds)
+	assert.NotNil(t, err)
+}
+
+func TestSetBuilds(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	buildPath := testutil.MakeSubdirectoryTree(workspacePath, "build")
+	testutil.WriteTextFile(buildPath, "systemjs_build_app.json", `{"modules": [{ "name": "app/main" }], "base": ""}`)
+	testutil.WriteTextFile(buildPath, "systemjs_build_controlpanel.json", `{"modules": [{ "name": "controlpanel/main" }], "base": ""}`)
+	testutil.WriteTextFile(testutil.MakeSubdirectoryTree(workspacePath, "app"), "main.js", `System.register([], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(testutil.MakeSubdirectoryTree(workspacePath, "controlpanel"), "main.js", `System.register([], function (exports_1, context_1) {`)
+
+	app := config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_app.json"), "app")
+	controlPanel := config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_controlpanel.json"), "controlpanel")
+	buildSet, err := CreateBuildSet(source.NewWorkspace(workspacePath), map[string]*config.RuntimeConfig{"app": app})
+	assert.Nil(t, err)
+	appSet := buildSet.ModuleSet("app")
+
+	err = buildSet.SetBuilds(map[string]*config.RuntimeConfig{"app": app, "controlpanel": controlPanel})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"app", "controlpanel"}, buildSet.Names())
+	assert.True(t, appSet == buildSet.ModuleSet("app"), "a build that keeps running keeps its ModuleSet")
+	buildSet.NotifyChanges(nil)
+	assert.Contains(t, buildSet.GenerateHTTPHandlers(), "/controlpanel/main.js")
+
+	err = buildSet.SetBuilds(map[string]*config.RuntimeConfig{"controlpanel": controlPanel})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"controlpanel"}, buildSet.Names())
+	assert.NotContains(t, buildSet.GenerateHTTPHandlers(), "/app/main.js")
+
+	missing := config.NewRuntimeConfig(filepath.Join(buildPath, "missing.json"), "missing")
+	err = buildSet.SetBuilds(map[string]*config.RuntimeConfig{"missing": missing})
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"controlpanel"}, buildSet.Names())
+}
+
+func TestRebuild(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	buildPath := testutil.MakeSubdirectoryTree(workspacePath, "build")
+	testutil.WriteTextFile(buildPath, "systemjs_build_app.json", `{"modules": [{ "name": "app/main" }], "base": ""}`)
+	appPath := testutil.MakeSubdirectoryTree(workspacePath, "app")
+	testutil.WriteTextFile(appPath, "main.js", "System.register([], function (exports_1, context_1) {\n    var before;\n});")
+
+	builds := map[string]*config.RuntimeConfig{
+		"app": config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_app.json"), "app"),
+	}
+	buildSet, err := CreateBuildSet(source.NewWorkspace(workspacePath), builds)
+	assert.Nil(t, err)
+	buildSet.NotifyChanges(nil)
+
+	// a change the monitor missed is only picked up by a rebuild
+	testutil.WriteTextFile(appPath, "main.js", "System.register([], function (exports_1, context_1) {\n    var after;\n});")
+	buildSet.NotifyChanges(nil)
+	assert.Contains(t, serveBundle(buildSet, "/app/main.js"), "before")
+
+	buildSet.Rebuild()
+	buildSet.NotifyChanges(nil)
+	assert.Contains(t, serveBundle(buildSet, "/app/main.js"), "after")
+}
+
+func TestConcurrentReconfigureAndNotifyChanges(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	buildPath := testutil.MakeSubdirectoryTree(workspacePath, "build")
+	testutil.WriteTextFile(buildPath, "systemjs_build_app.json", `{"modules": [{ "name": "app/main" }], "base": ""}`)
+	testutil.WriteTextFile(buildPath, "systemjs_build_controlpanel.json", `{"modules": [{ "name": "controlpanel/main" }], "base": ""}`)
+	appPath := testutil.MakeSubdirectoryTree(workspacePath, "app")
+	controlPanelPath := testutil.MakeSubdirectoryTree(workspacePath, "controlpanel")
+	commonPath := testutil.MakeSubdirectoryTree(workspacePath, "common")
+	testutil.WriteTextFile(appPath, "main.js", `System.register(["../common/util"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(controlPanelPath, "main.js", `System.register(["../common/util"], function (exports_1, context_1) {`)
+	utilFilepath := testutil.WriteTextFile(commonPath, "util.js", `System.register([], function (exports_1, context_1) {`)
+
+	builds := map[string]*config.RuntimeConfig{
+		"app":          config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_app.json"), "app"),
+		"controlpanel": config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_controlpanel.json"), "controlpanel"),
+	}
+	buildSet, err := CreateBuildSet(source.NewWorkspace(workspacePath), builds)
+	assert.Nil(t, err)
+	buildSet.NotifyChanges(nil)
+
+	// e.g. swarm.json is reloaded while a change to a shared file is being bundled (run with -race)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			descr, _ := config.LoadBuildDescriptionString(`{"modules": [{ "name": "controlpanel/main" }], "base": ""}`)
+			runtimeConfig := config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_controlpanel.json"), fmt.Sprintf("controlpanel%d", i))
+			_, _, err := buildSet.Reconfigure("controlpanel", descr.NormaliseModules(workspacePath), runtimeConfig)
+			assert.Nil(t, err)
+		}
+		done <- true
+	}()
+	for i := 0; i < 100; i++ {
+		changes := monitor.NewEventChangeset()
+		changes.Add(notify.Write, utilFilepath)
+		buildSet.NotifyChanges(changes)
+	}
+	<-done
+	assert.Contains(t, serveBundle(buildSet, "/app/main.js"), "common/util")
+}
+
+func serveBundle(buildSet *BuildSet, url string) string {
+	recorder := httptest.NewRecorder()
+	buildSet.GenerateHTTPHandlers()[url](recorder, httptest.NewRequest("GET", url, nil))
+	return recorder.Body.String()
+}
+
+func TestBundlesContaining(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	buildPath := testutil.MakeSubdirectoryTree(workspacePath, "build")
+	testutil.WriteTextFile(buildPath, "systemjs_build_app.json", `{"modules": [{ "name": "app/main" }], "base": ""}`)
+	testutil.WriteTextFile(buildPath, "systemjs_build_controlpanel.json", `{"modules": [{ "name": "controlpanel/main" }], "base": ""}`)
+	testutil.WriteTextFile(testutil.MakeSubdirectoryTree(workspacePath, "app"), "main.js", `System.register(["../common/util"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(testutil.MakeSubdirectoryTree(workspacePath, "controlpanel"), "main.js", `System.register([], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(testutil.MakeSubdirectoryTree(workspacePath, "common"), "util.js", `System.register([], function (exports_1, context_1) {`)
+
+	builds := map[string]*config.RuntimeConfig{
+		"app":          config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_app.json"), "app"),
+		"controlpanel": config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_controlpanel.json"), "controlpanel"),
+	}
+	buildSet, err := CreateBuildSet(source.NewWorkspace(workspacePath), builds)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"/app/main.js"}, buildSet.BundlesContaining("common/util.js"))
+	assert.Equal(t, []string{"/app/main.js"}, buildSet.BundlesContaining("common/util"))
+	assert.Equal(t, []string{"/controlpanel/main.js"}, buildSet.BundlesContaining("controlpanel/main.js"))
+	assert.Nil(t, buildSet.BundlesContaining("index.html"))
+}
@@ -1,13 +1,14 @@
 package bundle
 
 import (
-	"fmt"
-	"log"
 	"path"
 	"github.com/mrcrowl/swarm/config"
 	"github.com/mrcrowl/swarm/dep"
+	"github.com/mrcrowl/swarm/logging"
 	"github.com/mrcrowl/swarm/monitor"
 	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/util"
+	"time"
 )
 
 // Module is a container for managing part of a build
@@ -41,6 +42,15 @@ func (mod *Module) GetFileByPath(path string) *source.File {
 	return mod.fileset.Get(path)
 }
 
+// contains tests whether the module's bundle includes a file, by its root-relative path (.js files are usually
+// known without their extension)
+func (mod *Module) contains(relativePath string) bool {
+	if mod.fileset.Get(relativePath) != nil {
+		return true
+	}
+	return path.Ext(relativePath) == ".js" && mod.fileset.Get(util.RemoveExtension(relativePath)) != nil
+}
+
 // Name gets the name of the module
 func (mod *Module) Name() string {
 	return mod.description.Name
@@ -48,7 +58,15 @@ func (mod *Module) Name() string {
 
 // SourceMapName gets the name to associate with this module's source map
 func (mod *Module) SourceMapName() string {
-	return path.Base(mod.Name()) + ".js.map"
+	return path.Base(mod.Name()) + mod.variantSuffix() + ".js.map"
+}
+
+// variantSuffix distinguishes the urls of a variant's bundles from the default build, e.g. app/main@fr-mobile.js
+func (mod *Module) variantSuffix() string {
+	if variantName := mod.runtimeConfig.VariantName(); variantName != "" {
+		return "@" + variantName
+	}
+	return ""
 }
 
 func (mod *Module) dirty() bool {
@@ -75,29 +93,70 @@ func (mod *Module) excludedFilesets() []*source.FileSet {
 
 func (mod *Module) buildInitialFileSet() {
 	excludedFilesets := mod.excludedFilesets()
-	fileset := dep.BuildFileSet(mod.fileset.Workspace(), mod.PrimaryEntryPoint(), excludedFilesets, mod.runtimeConfig.ImportPathInterpolationValues())
+	conditions := mod.importConditions()
+	fileset := dep.BuildFileSet(mod.fileset.Workspace(), mod.PrimaryEntryPoint(), excludedFilesets, conditions)
 	for _, entryPoint := range mod.entryPoints {
-		dep.UpdateFileset(fileset, entryPoint, excludedFilesets, mod.runtimeConfig.ImportPathInterpolationValues())
+		dep.UpdateFileset(fileset, entryPoint, excludedFilesets, conditions)
 	}
 	mod.fileset = fileset
 }
 
-// absorbChanges absorbs an EventChangeset, triggering artefacts to be recompiled, when necessary
-func (mod *Module) absorbChanges(changes *monitor.EventChangeset) {
+// absorbChanges absorbs an EventChangeset, triggering artefacts to be recompiled, when necessary.
+// evictedIDs are files that were dropped by previously updated modules, which this module may now need to include.
+// Returns the IDs of any files that are no longer reachable from this module's entry points
+func (mod *Module) absorbChanges(changes *monitor.EventChangeset, evictedIDs []string, interpolationChanged bool) []string {
 	excludedFilesets := mod.excludedFilesets()
+	conditions := mod.importConditions()
 	ws := mod.fileset.Workspace()
-	for _, entryPoint := range changes.Changes() {
-		entryPointRelativePath, ok := ws.ToRelativePath(entryPoint.AbsoluteFilepath())
-		if ok {
-			dep.UpdateFileset(mod.fileset, entryPointRelativePath, excludedFilesets, mod.runtimeConfig.ImportPathInterpolationValues())
+	if interpolationChanged {
+		dep.RefreshInterpolatedFiles(mod.fileset, excludedFilesets, conditions)
+	}
+
+	for _, change := range changes.Changes() {
+		relativePath, ok := ws.ToRelativePath(change.AbsoluteFilepath())
+		if !ok {
+			continue
+		}
+
+		if change.Removed() {
+			dep.RemoveFromFileset(mod.fileset, relativePath)
+		} else {
+			dep.UpdateFileset(mod.fileset, relativePath, excludedFilesets, conditions)
 		}
 	}
+
+	for _, evictedID := range evictedIDs {
+		dep.UpdateFileset(mod.fileset, evictedID, excludedFilesets, conditions)
+	}
+
+	if !mod.dirty() {
+		return nil
+	}
+	return mod.fileset.RemoveUnreachable(mod.rootIDs())
+}
+
+// importConditions gets the values that conditional imports are resolved against
+func (mod *Module) importConditions() *source.ImportConditions {
+	return source.NewImportConditions(mod.runtimeConfig.ImportPathInterpolationValues(), mod.runtimeConfig.ImportPathVariants())
+}
+
+// rootIDs gets the IDs of the files from which all others in the module are reachable
+func (mod *Module) rootIDs() []string {
+	return append([]string{mod.PrimaryEntryPoint()}, mod.entryPoints...)
 }
 
 func (mod *Module) generateBundle() {
+	start := time.Now()
 	mod.bundledJavascript, mod.bundledSourcemap = mod.bundler.Bundle(mod.fileset, mod.runtimeConfig, mod.PrimaryEntryPoint())
 	mod.fileset.ClearDirty()
-	fmt.Printf("   Bundled: /%s.js (%d files)\n", mod.PrimaryEntryPoint(), mod.fileset.Count())
+	bundlePath := mod.bundlePath()
+	fields := logging.Fields{"module": mod.Name(), "file": bundlePath, "files": mod.fileset.Count(), "duration": time.Since(start)}
+	logging.Info("bundled", fields, "   Bundled: %s (%d files)", bundlePath, mod.fileset.Count())
+}
+
+// bundlePath gets the URL path that the module's bundle is served at, e.g. /app/src/main.js
+func (mod *Module) bundlePath() string {
+	return "/" + mod.PrimaryEntryPoint() + mod.variantSuffix() + ".js"
 }
 
 func (mod *Module) links() []string {
@@ -112,7 +171,8 @@ func (mod *Module) attachExcludedModules(set *ModuleSet) {
 	for _, excl := range mod.description.Exclude {
 		excludedModule := set.getModule(excl)
 		if excludedModule == nil {
-			log.Panicf("attachExcludedModules: excluded module '%s' not found", excl)
+			logging.Error("unknown-exclude", logging.Fields{"module": mod.description.Name}, "Module '%s' excludes unknown module '%s' (ignored)", mod.description.Name, excl)
+			continue
 		}
 		mod.excludedModules = append(mod.excludedModules, excludedModule)
 	}
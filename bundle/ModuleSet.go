@@ -3,34 +3,63 @@ package bundle
 import (
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"strings"
+	"reflect"
 	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/events"
+	"github.com/mrcrowl/swarm/logging"
 	"github.com/mrcrowl/swarm/monitor"
 	"github.com/mrcrowl/swarm/source"
 	"sync"
+	"time"
 )
 
 // ModuleSet is
 type ModuleSet struct {
 	modules       []*Module
 	mutex         *sync.Mutex
+	workspace     *source.Workspace
 	runtimeConfig *config.RuntimeConfig
+	fileCache     *source.FileCache // nil if the cache is owned by a BuildSet
+	variants      []*ModuleSet // one per combination of the interpolation matrix
 }
 
-// CreateModuleSet creates a ModuleSet from a list of NormalisedModuleDescriptions
+// CreateModuleSet creates a ModuleSet from a list of NormalisedModuleDescriptions.
+// If the build has an interpolation matrix, a variant ModuleSet is also created for every combination
 func CreateModuleSet(ws *source.Workspace, moduleDescriptions []*config.NormalisedModuleDescription, runtimeConfig *config.RuntimeConfig) *ModuleSet {
+	// files shared between variants are only parsed once
+	fileCache := source.NewFileCache()
+	set := createModuleSetWithVariants(ws.WithFileCache(fileCache), moduleDescriptions, runtimeConfig)
+	set.fileCache = fileCache
+	return set
+}
+
+// createModuleSetWithVariants creates a ModuleSet, plus its variants, using a Workspace that caches files.
+// Whoever owns the Workspace's FileCache is responsible for invalidating it
+func createModuleSetWithVariants(ws *source.Workspace, moduleDescriptions []*config.NormalisedModuleDescription, runtimeConfig *config.RuntimeConfig) *ModuleSet {
+	set := createModuleSet(ws, moduleDescriptions, runtimeConfig)
+	for _, combination := range runtimeConfig.InterpolationSources().MatrixCombinations() {
+		variantConfig := runtimeConfig.NewVariant(combination)
+		logging.Info("variant", logging.Fields{"variant": variantConfig.VariantName()}, "   Variant: %s", variantConfig.VariantName())
+		set.variants = append(set.variants, createModuleSet(ws, moduleDescriptions, variantConfig))
+	}
+	return set
+}
+
+func createModuleSet(ws *source.Workspace, moduleDescriptions []*config.NormalisedModuleDescription, runtimeConfig *config.RuntimeConfig) *ModuleSet {
+	ws = ws.ForBuild(runtimeConfig)
 	modules := make([]*Module, len(moduleDescriptions))
-	runtimeConfig.SetPathInterpolationValues(ws.ReadInterpolationValues(runtimeConfig))
 	for i, descr := range moduleDescriptions {
 		modules[i] = NewModule(ws, descr, runtimeConfig)
 	}
 
 	set := &ModuleSet{
-		modules: modules,
-		mutex:   &sync.Mutex{},
+		modules:       modules,
+		mutex:         &sync.Mutex{},
+		workspace:     ws,
+		runtimeConfig: runtimeConfig,
 	}
+	set.refreshInterpolationValues()
 
 	for _, mod := range set.modules {
 		mod.attachExcludedModules(set)
@@ -49,40 +78,260 @@ func CreateModuleSet(ws *source.Workspace, moduleDescriptions []*config.Normalis
 func (set *ModuleSet) NotifyChanges(changes *monitor.EventChangeset) {
 	set.mutex.Lock()
 	if changes != nil {
+		if set.fileCache != nil {
+			for _, change := range changes.Changes() {
+				set.fileCache.Invalidate(change.AbsoluteFilepath())
+			}
+		}
+
+		interpolationChanged := set.touchesInterpolationSources(changes) && set.refreshInterpolationValues()
+
+		// modules are sorted so that excluded modules come first, and files they evict are passed along
+		var evictedIDs []string
 		for _, mod := range set.modules {
-			mod.absorbChanges(changes)
+			evictedIDs = append(evictedIDs, mod.absorbChanges(changes, evictedIDs, interpolationChanged)...)
 		}
 	}
 
 	// TODO: could this be parallelised?
 	for _, mod := range set.modules {
 		if mod.dirty() {
+			start := time.Now()
 			mod.generateBundle()
+			events.Publish(&events.Event{
+				Type:       events.ModuleBundled,
+				Modules:    []string{mod.Name()},
+				Bundle:     mod.bundlePath(),
+				FileCount:  mod.fileset.Count(),
+				Size:       len(mod.bundledJavascript),
+				DurationMs: events.Duration(time.Since(start)),
+			})
 			if changes != nil {
 				changes.FlagDidBundle()
 			}
 		}
 	}
+	variants := set.variants
+	set.mutex.Unlock()
+
+	for _, variant := range variants {
+		variant.NotifyChanges(changes)
+	}
+}
+
+// Reconfigure applies a changed build description (and RuntimeConfig) to a running ModuleSet, e.g. after swarm.json
+// or a build description file is edited.  Modules that are unchanged keep their FileSets, so only new or changed
+// modules (and modules which exclude them) are rebuilt.  Returns the names of the modules that were rebuilt, and of
+// those that were removed
+func (set *ModuleSet) Reconfigure(moduleDescriptions []*config.NormalisedModuleDescription, runtimeConfig *config.RuntimeConfig) ([]string, []string, error) {
+	if err := validateExcludes(moduleDescriptions); err != nil {
+		return nil, nil, err
+	}
+
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	configChanged := !sameRuntimeConfig(set.runtimeConfig, runtimeConfig)
+	if configChanged {
+		set.runtimeConfig = runtimeConfig
+		set.workspace = set.workspace.ForBuild(runtimeConfig)
+		set.refreshInterpolationValues()
+	}
+
+	existingModules := make(map[string]*Module, len(set.modules))
+	for _, mod := range set.modules {
+		existingModules[mod.Name()] = mod
+	}
+
+	changed := map[string]bool{}
+	modules := make([]*Module, len(moduleDescriptions))
+	for i, descr := range moduleDescriptions {
+		if mod, found := existingModules[descr.Name]; found && !configChanged && reflect.DeepEqual(mod.description, descr) {
+			mod.excludedModules = nil
+			modules[i] = mod
+		} else {
+			modules[i] = NewModule(set.workspace, descr, set.runtimeConfig)
+			changed[descr.Name] = true
+		}
+	}
+
+	names := make(map[string]bool, len(moduleDescriptions))
+	for _, descr := range moduleDescriptions {
+		names[descr.Name] = true
+	}
+	var removedNames []string
+	for _, mod := range set.modules {
+		if !names[mod.Name()] {
+			removedNames = append(removedNames, mod.Name())
+		}
+	}
+
+	set.modules = modules
+	for _, mod := range set.modules {
+		mod.attachExcludedModules(set)
+	}
+	set.sort()
+
+	// modules are sorted so that excluded modules are rebuilt before the modules which exclude them
+	var rebuiltNames []string
+	for _, mod := range set.modules {
+		for _, excl := range mod.excludedModules {
+			if changed[excl.Name()] {
+				changed[mod.Name()] = true
+			}
+		}
+
+		if changed[mod.Name()] {
+			mod.buildInitialFileSet()
+			mod.generateBundle()
+			rebuiltNames = append(rebuiltNames, mod.Name())
+		}
+	}
+
+	if configChanged {
+		set.variants = nil
+		for _, combination := range runtimeConfig.InterpolationSources().MatrixCombinations() {
+			variant := createModuleSet(set.workspace, moduleDescriptions, runtimeConfig.NewVariant(combination))
+			variant.NotifyChanges(nil)
+			set.variants = append(set.variants, variant)
+		}
+	} else {
+		for _, variant := range set.variants {
+			if _, _, err := variant.Reconfigure(moduleDescriptions, variant.runtimeConfig); err != nil {
+				return rebuiltNames, removedNames, fmt.Errorf("%s (in variant '%s')", err, variant.runtimeConfig.VariantName())
+			}
+		}
+	}
+
+	return rebuiltNames, removedNames, nil
+}
+
+// Rebuild re-reads every module's files from scratch, so that the next NotifyChanges rebundles them all
+func (set *ModuleSet) Rebuild() {
+	set.mutex.Lock()
+	for _, mod := range set.modules {
+		mod.buildInitialFileSet() // excluded modules come first, so their FileSets are rebuilt before they're needed
+	}
+	variants := set.variants
 	set.mutex.Unlock()
+
+	for _, variant := range variants {
+		variant.Rebuild()
+	}
+}
+
+// sameRuntimeConfig tests whether two RuntimeConfigs would produce the same bundles
+func sameRuntimeConfig(a *config.RuntimeConfig, b *config.RuntimeConfig) bool {
+	return a.BuildPath == b.BuildPath && a.BaseHref == b.BaseHref && reflect.DeepEqual(a.Interpolation, b.Interpolation) && reflect.DeepEqual(a.CSS, b.CSS)
+}
+
+// validateExcludes checks that every excluded module exists, since a running ModuleSet can't recover from one that doesn't
+func validateExcludes(moduleDescriptions []*config.NormalisedModuleDescription) error {
+	names := make(map[string]bool, len(moduleDescriptions))
+	for _, descr := range moduleDescriptions {
+		names[descr.Name] = true
+	}
+	for _, descr := range moduleDescriptions {
+		for _, excl := range descr.Exclude {
+			if !names[excl] {
+				return fmt.Errorf("Module '%s' excludes unknown module '%s'", descr.Name, excl)
+			}
+		}
+	}
+	return nil
+}
+
+// Variants gets the names of the variants built from the interpolation matrix
+func (set *ModuleSet) Variants() []string {
+	names := make([]string, len(set.variants))
+	for i, variant := range set.variants {
+		names[i] = variant.runtimeConfig.VariantName()
+	}
+	return names
+}
+
+// refreshInterpolationValues (re)reads the values for #{...} imports, returning true if they changed
+func (set *ModuleSet) refreshInterpolationValues() bool {
+	values, err := set.workspace.ReadInterpolationValues(set.runtimeConfig)
+	if err != nil {
+		logging.Error("interpolation-values", logging.Fields{"error": err}, "Failed to read interpolation values: %s", err)
+	}
+
+	changed := !reflect.DeepEqual(values, set.runtimeConfig.ImportPathInterpolationValues())
+	set.runtimeConfig.SetPathInterpolationValues(values)
+	return changed
+}
+
+// touchesInterpolationSources tests whether any of the files that interpolation values are read from have changed
+func (set *ModuleSet) touchesInterpolationSources(changes *monitor.EventChangeset) bool {
+	sources := set.runtimeConfig.InterpolationSources()
+	for _, change := range changes.Changes() {
+		if relativePath, ok := set.workspace.ToRelativePath(change.AbsoluteFilepath()); ok && sources.IsSource(relativePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// BundlesContaining gets the URL paths of the bundles (including those of variants) which include a file, by its
+// root-relative path
+func (set *ModuleSet) BundlesContaining(relativePath string) []string {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	var bundles []string
+	for _, mod := range set.modules {
+		if mod.contains(relativePath) {
+			bundles = append(bundles, mod.bundlePath())
+		}
+	}
+	for _, variant := range set.variants {
+		bundles = append(bundles, variant.BundlesContaining(relativePath)...)
+	}
+	return bundles
 }
 
 // FindFileByPath finds and returns a file by path name
 func (set *ModuleSet) FindFileByPath(path string) *source.File {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
 	for _, mod := range set.modules {
 		if file := mod.GetFileByPath(path); file != nil {
 			return file
 		}
 	}
+	for _, variant := range set.variants {
+		if file := variant.FindFileByPath(path); file != nil {
+			return file
+		}
+	}
 	return nil
 }
 
+// FilesIncluding finds the files whose contents inline a file, by its root-relative path, e.g. CSS files which
+// @import it
+func (set *ModuleSet) FilesIncluding(relativePath string) []*source.File {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	var files []*source.File
+	for _, mod := range set.modules {
+		files = append(files, mod.fileset.IncludersOf(relativePath)...)
+	}
+	for _, variant := range set.variants {
+		files = append(files, variant.FilesIncluding(relativePath)...)
+	}
+	return files
+}
+
+// getModule finds a module by name, or nil if there isn't one
 func (set *ModuleSet) getModule(name string) *Module {
 	for _, mod := range set.modules {
 		if mod.description.Name == name {
 			return mod
 		}
 	}
-	log.Panicf("getModule: could not find module with name '%s'", name)
 	return nil
 }
 
@@ -115,6 +364,9 @@ func (set *ModuleSet) sort() {
 
 // GenerateHTTPHandlers creates http.HandlerFunc's that will return the bundled javascript
 func (set *ModuleSet) GenerateHTTPHandlers() map[string]http.HandlerFunc {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
 	createJSHandler := func(module *Module) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			io.WriteString(w, module.bundledJavascript)
@@ -124,18 +376,22 @@ func (set *ModuleSet) GenerateHTTPHandlers() map[string]http.HandlerFunc {
 
 	createMapHandler := func(module *Module) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			sourceMap := module.bundledSourcemap
-			sourceMap = strings.Replace(sourceMap, `["BaseController.ts"]`, `["ui/base/BaseController.ts"]`, 1)
-			io.WriteString(w, sourceMap)
+			io.WriteString(w, module.bundledSourcemap)
 		}
 	}
 
 	handlers := map[string]http.HandlerFunc{}
 	for _, module := range set.modules {
-		entryPoint := module.PrimaryEntryPoint()
-		handlers["/"+entryPoint+".js"] = createJSHandler(module)
+		bundlePath := "/" + module.PrimaryEntryPoint() + module.variantSuffix()
+		handlers[bundlePath+".js"] = createJSHandler(module)
 		if set.runtimeConfig.SourceMapsEnabled() {
-			handlers["/"+entryPoint+".js.map"] = createMapHandler(module)
+			handlers[bundlePath+".js.map"] = createMapHandler(module)
+		}
+	}
+
+	for _, variant := range set.variants {
+		for url, handler := range variant.GenerateHTTPHandlers() {
+			handlers[url] = handler
 		}
 	}
 	return handlers
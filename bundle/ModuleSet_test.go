@@ -4,8 +4,10 @@ import (
 	"testing"
 
 	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/monitor"
 	"github.com/mrcrowl/swarm/source"
 	"github.com/mrcrowl/swarm/testutil"
+	"github.com/rjeczalik/notify"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -62,3 +64,166 @@ func TestCreateModuleSet(t *testing.T) {
 // 	set := CreateModuleSet(createWorkspace(), descr.NormaliseModules("c:\\wf\\lp\\web\\App"), nil)
 // 	assert.Equal(t, "controlPanel/ControlPanel", set.names()[0], "controlPanel/ControlPanel should be the first module")
 // }
+
+const buildDescrSharedJSON = `{
+	"modules": [
+		{ "name": "first" },
+		{ "name": "second", "exclude": ["first"] }
+	],
+	"base": ""
+}`
+
+func TestNotifyChangesMovesEvictedFiles(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	firstFilepath := testutil.WriteTextFile(workspacePath, "first.js", `System.register(["./shared"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "second.js", `System.register(["./shared"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "shared.js", `System.register([], function (exports_1, context_1) {`)
+
+	descr, _ := config.LoadBuildDescriptionString(buildDescrSharedJSON)
+	set := CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), config.NewRuntimeConfig("", ""))
+	first, second := set.getModule("first"), set.getModule("second")
+	assert.True(t, first.fileset.Contains("shared"))
+	assert.False(t, second.fileset.Contains("shared"))
+
+	testutil.WriteTextFile(workspacePath, "first.js", `System.register([], function (exports_1, context_1) {`)
+	changes := monitor.NewEventChangeset()
+	changes.Add(notify.Write, firstFilepath)
+	set.NotifyChanges(changes)
+
+	assert.False(t, first.fileset.Contains("shared"))
+	assert.True(t, second.fileset.Contains("shared"))
+}
+
+func TestNotifyChangesReinterpolatesImports(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	configFilepath := testutil.WriteTextFile(workspacePath, "Config.js", `Config.SUFFIX = ".mobile";`)
+	testutil.WriteTextFile(workspacePath, "first.js", `System.register(["./page#{Config|Config.SUFFIX}"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "page.mobile.js", `System.register([], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "page.desktop.js", `System.register([], function (exports_1, context_1) {`)
+
+	descr, _ := config.LoadBuildDescriptionString(`{"modules": [{ "name": "first" }], "base": ""}`)
+	set := CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), config.NewRuntimeConfig("", ""))
+	first := set.getModule("first")
+	assert.True(t, first.fileset.Contains("page.mobile"))
+
+	testutil.WriteTextFile(workspacePath, "Config.js", `Config.SUFFIX = ".desktop";`)
+	changes := monitor.NewEventChangeset()
+	changes.Add(notify.Write, configFilepath)
+	set.NotifyChanges(changes)
+
+	assert.False(t, first.fileset.Contains("page.mobile"))
+	assert.True(t, first.fileset.Contains("page.desktop"))
+}
+
+func TestCreateModuleSetWithMatrix(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", `Config.LOCALE = "en";`)
+	testutil.WriteTextFile(workspacePath, "first.js", `System.register(["./strings.#{Config|Config.LOCALE}", "./shared"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "strings.en.js", `System.register([], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "strings.fr.js", `System.register([], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "strings.de.js", `System.register([], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "shared.js", `System.register([], function (exports_1, context_1) {`)
+
+	descr, _ := config.LoadBuildDescriptionString(`{"modules": [{ "name": "first" }], "base": ""}`)
+	runtimeConfig := config.NewRuntimeConfig("", "")
+	runtimeConfig.Interpolation = &config.InterpolationConfig{
+		JS:     []string{"Config.js"},
+		Matrix: map[string][]string{"Config.LOCALE": {"fr", "de"}},
+	}
+	set := CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), runtimeConfig)
+	assert.Equal(t, []string{"fr", "de"}, set.Variants())
+
+	assert.True(t, set.getModule("first").fileset.Contains("strings.en"))
+	assert.True(t, set.variants[0].getModule("first").fileset.Contains("strings.fr"))
+	assert.False(t, set.variants[0].getModule("first").fileset.Contains("strings.en"))
+	assert.True(t, set.variants[1].getModule("first").fileset.Contains("strings.de"))
+
+	// shared files are the same instance in every variant
+	shared := set.getModule("first").fileset.Get("shared")
+	assert.True(t, shared == set.variants[0].getModule("first").fileset.Get("shared"))
+	assert.True(t, shared == set.variants[1].getModule("first").fileset.Get("shared"))
+
+	set.NotifyChanges(nil)
+	handlers := set.GenerateHTTPHandlers()
+	for _, url := range []string{"/first.js", "/first.js.map", "/first@fr.js", "/first@fr.js.map", "/first@de.js"} {
+		assert.Contains(t, handlers, url)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	testutil.WriteTextFile(workspacePath, "first.js", `System.register(["./shared"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "second.js", `System.register(["./shared"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "third.js", `System.register([], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "shared.js", `System.register([], function (exports_1, context_1) {`)
+
+	runtimeConfig := config.NewRuntimeConfig("", "")
+	descr, _ := config.LoadBuildDescriptionString(`{"modules": [{ "name": "first" }, { "name": "second" }], "base": ""}`)
+	set := CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), runtimeConfig)
+	first := set.getModule("first")
+	assert.True(t, set.getModule("second").fileset.Contains("shared"))
+
+	// second now excludes first, and third is added
+	descr, _ = config.LoadBuildDescriptionString(`{"modules": [{ "name": "first" }, { "name": "second", "exclude": ["first"] }, { "name": "third" }], "base": ""}`)
+	rebuilt, removed, err := set.Reconfigure(descr.NormaliseModules(workspacePath), runtimeConfig)
+	assert.Nil(t, err)
+	assert.Empty(t, removed)
+	assert.ElementsMatch(t, []string{"first", "second", "third"}, set.names())
+	assert.ElementsMatch(t, []string{"second", "third"}, rebuilt)
+	assert.True(t, first == set.getModule("first"), "unchanged modules should be kept")
+	assert.False(t, set.getModule("second").fileset.Contains("shared"))
+	assert.Contains(t, set.GenerateHTTPHandlers(), "/third.js")
+
+	// first is removed, so second no longer excludes it
+	descr, _ = config.LoadBuildDescriptionString(`{"modules": [{ "name": "second" }, { "name": "third" }], "base": ""}`)
+	rebuilt, removed, err = set.Reconfigure(descr.NormaliseModules(workspacePath), runtimeConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"second"}, rebuilt)
+	assert.Equal(t, []string{"first"}, removed)
+	assert.True(t, set.getModule("second").fileset.Contains("shared"))
+	assert.NotContains(t, set.GenerateHTTPHandlers(), "/first.js")
+}
+
+func TestReconfigureDanglingExclude(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "first.js", `System.register([], function (exports_1, context_1) {`)
+
+	runtimeConfig := config.NewRuntimeConfig("", "")
+	descr, _ := config.LoadBuildDescriptionString(`{"modules": [{ "name": "first" }], "base": ""}`)
+	set := CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), runtimeConfig)
+
+	descr, _ = config.LoadBuildDescriptionString(`{"modules": [{ "name": "first", "exclude": ["missing"] }], "base": ""}`)
+	_, _, err := set.Reconfigure(descr.NormaliseModules(workspacePath), runtimeConfig)
+	assert.EqualError(t, err, "Module 'first' excludes unknown module 'missing'")
+	assert.Equal(t, []string{"first"}, set.names())
+}
+
+func TestNotifyChangesRebundlesCSSImports(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	testutil.WriteTextFile(workspacePath, "first.js", `System.register(["./style.css"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(workspacePath, "style.css", `@import "partial.css";`)
+	partialFilepath := testutil.WriteTextFile(workspacePath, "partial.css", `a { color: red; }`)
+
+	descr, _ := config.LoadBuildDescriptionString(`{"modules": [{ "name": "first" }], "base": ""}`)
+	set := CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), config.NewRuntimeConfig("", ""))
+	set.NotifyChanges(nil)
+	first := set.getModule("first")
+	assert.Contains(t, first.bundledJavascript, "color: red")
+	assert.Len(t, set.FilesIncluding("partial.css"), 1)
+
+	testutil.WriteTextFile(workspacePath, "partial.css", `a { color: blue; }`)
+	changes := monitor.NewEventChangeset()
+	changes.Add(notify.Write, partialFilepath)
+	set.NotifyChanges(changes)
+
+	assert.Contains(t, first.bundledJavascript, "color: blue")
+}
@@ -30,9 +30,7 @@ type NormalisedModuleDescription struct {
 
 // LoadBuildDescriptionFile loads a JSON build configuration file
 func LoadBuildDescriptionFile(buildFilepath string) (*BuildDescription, error) {
-	if filepath.Ext(buildFilepath) == "" {
-		buildFilepath += ".json"
-	}
+	buildFilepath = BuildDescriptionFilepath(buildFilepath)
 
 	buildBytes, e := ioutil.ReadFile(buildFilepath)
 	if e != nil {
@@ -41,9 +39,20 @@ func LoadBuildDescriptionFile(buildFilepath string) (*BuildDescription, error) {
 
 	jsonString := string(buildBytes)
 	description, err := LoadBuildDescriptionString(jsonString)
+	if err == nil {
+		warnAboutProblems(ValidateBuildDescription(buildFilepath, buildBytes, ""))
+	}
 	return description, err
 }
 
+// BuildDescriptionFilepath gets the filepath that a build path refers to, which is assumed to be .json if it has no extension
+func BuildDescriptionFilepath(buildPath string) string {
+	if filepath.Ext(buildPath) == "" {
+		return buildPath + ".json"
+	}
+	return buildPath
+}
+
 // LoadBuildDescriptionString loads a JSON string
 func LoadBuildDescriptionString(buildFileString string) (*BuildDescription, error) {
 	var description *BuildDescription
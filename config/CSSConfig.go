@@ -0,0 +1,26 @@
+package config
+
+// CSSConfig describes the optional steps of a build's CSS pipeline, e.g. for production bundles.
+// @import inlining and url() resolution always happen
+type CSSConfig struct {
+	Minify     bool `json:"minify"`     // strip comments and insignificant whitespace
+	Autoprefix bool `json:"autoprefix"` // add vendor-prefixed copies of properties that need them
+}
+
+// Key distinguishes the output of different CSS configurations, e.g. "minify+autoprefix"
+func (cc *CSSConfig) Key() string {
+	if cc == nil {
+		return ""
+	}
+	key := ""
+	if cc.Minify {
+		key += "minify"
+	}
+	if cc.Autoprefix {
+		if key != "" {
+			key += "+"
+		}
+		key += "autoprefix"
+	}
+	return key
+}
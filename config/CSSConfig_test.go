@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSSConfigKey(t *testing.T) {
+	cases := map[string]struct {
+		config   *CSSConfig
+		expected string
+	}{
+		"nil":        {nil, ""},
+		"none":       {&CSSConfig{}, ""},
+		"minify":     {&CSSConfig{Minify: true}, "minify"},
+		"autoprefix": {&CSSConfig{Autoprefix: true}, "autoprefix"},
+		"both":       {&CSSConfig{Minify: true, Autoprefix: true}, "minify+autoprefix"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.config.Key())
+		})
+	}
+}
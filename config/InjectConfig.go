@@ -0,0 +1,30 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// InjectConfig describes the tags the development server injects into each build's index.html
+type InjectConfig struct {
+	Head     []*InjectScript            `json:"head,omitempty"`   // scripts appended to <head>
+	Body     []*InjectScript            `json:"body,omitempty"`   // scripts appended to <body>, before the hot reload scripts
+	Config   map[string]json.RawMessage `json:"config,omitempty"` // global name --> JSON value, assigned to window in <head>
+	Preload  bool                       `json:"preload"`          // adds a <link rel="preload"> tag for every generated bundle
+	BaseHref bool                       `json:"baseHref"`         // sets (or adds) <base href> to match the build's base href
+}
+
+// InjectScript is a script to inject, either by URL or inline
+type InjectScript struct {
+	Src    string `json:"src,omitempty"`
+	Inline string `json:"inline,omitempty"`
+	Module bool   `json:"module,omitempty"`
+}
+
+// Validate checks that a script has either a src or an inline script, but not both
+func (script *InjectScript) Validate() error {
+	if script == nil || (script.Src == "") == (script.Inline == "") {
+		return errors.New("an inject script needs either a src or inline script")
+	}
+	return nil
+}
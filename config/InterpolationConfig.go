@@ -0,0 +1,107 @@
+package config
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const defaultInterpolationJS = "Config.js"
+
+// InterpolationConfig describes where the values for #{...} import path interpolation come from.
+// Paths are relative to the root of the workspace.  Later sources override earlier ones: JS, then JSON, then Values
+//
+// Matrix lists values for some keys, e.g. {"Config.LOCALE": ["en", "fr"]}, and a separate variant of the build is
+// bundled for every combination of them, alongside the default build.
+//
+// When AllVariants is set, every variant of a conditional import is bundled, so that it can be chosen at runtime:
+// #? imports are always included, and #{...} imports include the current value plus any listed in Variants
+type InterpolationConfig struct {
+	JS          []string            `json:"js"`          // files containing assignments, e.g. Config.MOBILE_RELEASE = true;
+	JSON        string              `json:"json"`        // a file containing a JSON object, e.g. {"Config": {"MOBILE_RELEASE": true}}
+	Values      map[string]string   `json:"values"`      // explicit key/value pairs, e.g. {"Config.MOBILE_RELEASE": "true"}
+	AllVariants bool                `json:"allVariants"` // bundle every variant of conditional imports
+	Variants    map[string][]string `json:"variants"`    // possible values of each key, e.g. {"Config.SUFFIX": [".mobile", ""]}
+	Matrix      map[string][]string `json:"matrix"`      // values to build a separate variant for, e.g. {"Config.LOCALE": ["en", "fr"]}
+}
+
+// DefaultInterpolationConfig reads interpolation values from Config.js, if it exists
+func DefaultInterpolationConfig() *InterpolationConfig {
+	return &InterpolationConfig{JS: []string{defaultInterpolationJS}}
+}
+
+// Filepaths gets the root-relative paths of the files that values are read from
+func (ic *InterpolationConfig) Filepaths() []string {
+	filepaths := append([]string(nil), ic.JS...)
+	if ic.JSON != "" {
+		filepaths = append(filepaths, ic.JSON)
+	}
+	return filepaths
+}
+
+// IsSource tests whether a root-relative path is one of the files that values are read from
+func (ic *InterpolationConfig) IsSource(relativePath string) bool {
+	normalisedPath := normaliseRelativePath(relativePath)
+	for _, filepath := range ic.Filepaths() {
+		if normaliseRelativePath(filepath) == normalisedPath {
+			return true
+		}
+	}
+	return false
+}
+
+// MatrixCombinations lists every combination of the values in the Matrix, or nil if there isn't one
+func (ic *InterpolationConfig) MatrixCombinations() []map[string]string {
+	keys := make([]string, 0, len(ic.Matrix))
+	for key, values := range ic.Matrix {
+		if len(values) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	combinations := []map[string]string{{}}
+	for _, key := range keys {
+		var expanded []map[string]string
+		for _, combination := range combinations {
+			for _, value := range ic.Matrix[key] {
+				extended := map[string]string{key: value}
+				for k, v := range combination {
+					extended[k] = v
+				}
+				expanded = append(expanded, extended)
+			}
+		}
+		combinations = expanded
+	}
+	return combinations
+}
+
+var reVariantNameUnsafe = regexp.MustCompile("[^A-Za-z0-9]+")
+
+// VariantName creates a url-safe name for a combination of values, e.g. {"Config.LOCALE": "fr", "Config.SUFFIX": ".mobile"} ==> fr-mobile
+func VariantName(combination map[string]string) string {
+	keys := make([]string, 0, len(combination))
+	for key := range combination {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		part := strings.Trim(reVariantNameUnsafe.ReplaceAllString(combination[key], "-"), "-")
+		if part == "" {
+			part = "none"
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, "-")
+}
+
+func normaliseRelativePath(relativePath string) string {
+	return path.Clean(strings.Replace(relativePath, "\\", "/", -1))
+}
@@ -1,12 +1,63 @@
 package config
 
+import "time"
+
+const (
+	// WatcherAuto uses native file system notifications, falling back to polling if they are unavailable
+	WatcherAuto = "auto"
+
+	// WatcherNotify uses native file system notifications only
+	WatcherNotify = "notify"
+
+	// WatcherPoll periodically stats every file in the workspace (e.g. for network drives, Docker volumes or WSL mounts)
+	WatcherPoll = "poll"
+)
+
+const defaultPollIntervalMillis uint = 1000
+
+var defaultMonitorIgnore = []string{".git/", "node_modules/"}
+
 // MonitorConfig describes the configuration of the file monitor
 type MonitorConfig struct {
-	Extensions     []string `json:"extensions"`
-	DebounceMillis uint     `json:"debounceMillis"`
+	Extensions         []string `json:"extensions"`
+	DebounceMillis     uint     `json:"debounceMillis"`
+	Watcher            string   `json:"watcher"`
+	PollIntervalMillis uint     `json:"pollIntervalMillis"`
+	Ignore             []string `json:"ignore"`  // gitignore-style patterns for paths to ignore
+	Include            []string `json:"include"` // gitignore-style patterns for paths to watch (default: everything)
 }
 
 // NewMonitorConfig creates a MonitorConfig
 func NewMonitorConfig(extensions []string, debounceMillis uint) *MonitorConfig {
-	return &MonitorConfig{extensions, debounceMillis}
+	return &MonitorConfig{extensions, debounceMillis, WatcherAuto, defaultPollIntervalMillis, defaultMonitorIgnore, nil}
+}
+
+// backfillWithDefaults fills in the settings missing from a monitor block, e.g. one that only lists extensions
+func (mc *MonitorConfig) backfillWithDefaults(defaults *MonitorConfig) {
+	if mc.Extensions == nil {
+		mc.Extensions = defaults.Extensions
+	}
+	if mc.DebounceMillis == 0 {
+		mc.DebounceMillis = defaults.DebounceMillis
+	}
+	if mc.Ignore == nil {
+		mc.Ignore = defaults.Ignore
+	}
+}
+
+// WatcherKind gets the kind of watcher to use, defaulting to WatcherAuto
+func (mc *MonitorConfig) WatcherKind() string {
+	if mc.Watcher == "" {
+		return WatcherAuto
+	}
+	return mc.Watcher
+}
+
+// PollInterval gets the interval between scans when polling for changes
+func (mc *MonitorConfig) PollInterval() time.Duration {
+	millis := mc.PollIntervalMillis
+	if millis == 0 {
+		millis = defaultPollIntervalMillis
+	}
+	return time.Millisecond * time.Duration(millis)
 }
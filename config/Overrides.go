@@ -0,0 +1,236 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const envVarPrefix = "SWARM_"
+
+// Sources of configuration values, lowest precedence first.  The source of a value from swarm.json is its filepath
+const (
+	SourceDefault = "default"
+	sourceEnvVar  = "environment variable "
+	sourceFlag    = "flag --"
+)
+
+// Overrides are the configuration values which take precedence over swarm.json: first SWARM_* environment
+// variables, then flags given on the command line
+type Overrides struct {
+	env   map[string]string // SWARM_* environment variable name --> value
+	flags map[string]string // flag name --> value, for flags that were explicitly set
+}
+
+// NewOverrides creates Overrides from a list of environment variables (as given by os.Environ) and the flags that
+// were explicitly set on the command line
+func NewOverrides(environ []string, flags map[string]string) *Overrides {
+	env := map[string]string{}
+	for _, keyValue := range environ {
+		if parts := strings.SplitN(keyValue, "=", 2); len(parts) == 2 && strings.HasPrefix(parts[0], envVarPrefix) {
+			env[parts[0]] = parts[1]
+		}
+	}
+
+	// paths given on the command line are relative to the working directory, rather than to swarm.json
+	for _, values := range []map[string]string{env, flags} {
+		for _, key := range []string{"root", "config", envVarPrefix + "ROOT", envVarPrefix + "CONFIG"} {
+			if value, found := values[key]; found && value != "" && !filepath.IsAbs(value) {
+				if absPath, err := filepath.Abs(value); err == nil {
+					values[key] = absPath
+				}
+			}
+		}
+	}
+	return &Overrides{env, flags}
+}
+
+// configPath gets the swarm.json filepath given by --config or SWARM_CONFIG, or "" if neither was given
+func (overrides *Overrides) configPath() string {
+	if overrides == nil {
+		return ""
+	}
+	if configPath, found := overrides.flags["config"]; found {
+		return configPath
+	}
+	return overrides.env[envVarPrefix+"CONFIG"]
+}
+
+// setting is a SwarmConfig value which can be overridden by an environment variable or flag, e.g. "base-href" can
+// be overridden by SWARM_BASE_HREF or --base-href
+type setting struct {
+	name string
+	get  func(config *SwarmConfig) string
+	set  func(config *SwarmConfig, value string) error
+}
+
+// envVarName gets the name of the environment variable that overrides a setting
+func (s *setting) envVarName() string {
+	return envVarPrefix + strings.ToUpper(strings.Replace(s.name, "-", "_", -1))
+}
+
+var settings = []*setting{
+	{
+		name: "root",
+		get:  func(config *SwarmConfig) string { return config.RootPath },
+		set:  func(config *SwarmConfig, value string) error { config.RootPath = value; return nil },
+	},
+	{
+		name: "build",
+		get:  func(config *SwarmConfig) string { return strings.Join(config.Build, ",") },
+		set:  func(config *SwarmConfig, value string) error { config.Build = splitList(value); return nil },
+	},
+	{
+		name: "base-href",
+		get: func(config *SwarmConfig) string {
+			var baseHrefs []string
+			for name, build := range config.Builds {
+				baseHrefs = append(baseHrefs, name+"="+build.BaseHref)
+			}
+			sort.Strings(baseHrefs)
+			return strings.Join(baseHrefs, ",")
+		},
+		set: func(config *SwarmConfig, value string) error {
+			for _, build := range config.Builds {
+				build.BaseHref = value
+			}
+			return nil
+		},
+	},
+	{
+		name: "port",
+		get:  func(config *SwarmConfig) string { return strconv.Itoa(int(config.Server.Port)) },
+		set: func(config *SwarmConfig, value string) error {
+			port, err := strconv.ParseUint(value, 10, 16)
+			config.Server.Port = uint16(port)
+			return err
+		},
+	},
+	{
+		name: "https",
+		get:  func(config *SwarmConfig) string { return strconv.FormatBool(config.Server.HTTPS) },
+		set: func(config *SwarmConfig, value string) (err error) {
+			config.Server.HTTPS, err = strconv.ParseBool(value)
+			return err
+		},
+	},
+	{
+		name: "open",
+		get:  func(config *SwarmConfig) string { return strconv.FormatBool(config.Server.Open) },
+		set: func(config *SwarmConfig, value string) (err error) {
+			config.Server.Open, err = strconv.ParseBool(value)
+			return err
+		},
+	},
+	{
+		name: "hot-reload",
+		get:  func(config *SwarmConfig) string { return strconv.FormatBool(config.Server.HotReload) },
+		set: func(config *SwarmConfig, value string) (err error) {
+			config.Server.HotReload, err = strconv.ParseBool(value)
+			return err
+		},
+	},
+	{
+		name: "debounce",
+		get:  func(config *SwarmConfig) string { return strconv.Itoa(int(config.Monitor.DebounceMillis)) },
+		set: func(config *SwarmConfig, value string) error {
+			millis, err := strconv.ParseUint(value, 10, 32)
+			config.Monitor.DebounceMillis = uint(millis)
+			return err
+		},
+	},
+	{
+		name: "watcher",
+		get:  func(config *SwarmConfig) string { return config.Monitor.WatcherKind() },
+		set: func(config *SwarmConfig, value string) error {
+			if value != WatcherAuto && value != WatcherNotify && value != WatcherPoll {
+				return fmt.Errorf("expected %s, %s or %s", WatcherAuto, WatcherNotify, WatcherPoll)
+			}
+			config.Monitor.Watcher = value
+			return nil
+		},
+	},
+	{
+		name: "poll-interval",
+		get:  func(config *SwarmConfig) string { return strconv.Itoa(int(config.Monitor.PollIntervalMillis)) },
+		set: func(config *SwarmConfig, value string) error {
+			millis, err := strconv.ParseUint(value, 10, 32)
+			config.Monitor.PollIntervalMillis = uint(millis)
+			return err
+		},
+	},
+	{
+		name: "extensions",
+		get:  func(config *SwarmConfig) string { return strings.Join(config.Monitor.Extensions, ",") },
+		set: func(config *SwarmConfig, value string) error {
+			config.Monitor.Extensions = splitList(value)
+			return nil
+		},
+	},
+}
+
+// splitList splits a comma-separated list, ignoring empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// applyLayers records where each setting's value came from (defaults or swarm.json), then applies any overrides
+func (config *SwarmConfig) applyLayers(defaults *SwarmConfig, swarmConfigFilepath string, overrides *Overrides) error {
+	config.sources = make(map[string]string, len(settings))
+	for _, setting := range settings {
+		config.sources[setting.name] = SourceDefault
+		if swarmConfigFilepath != "" && setting.get(config) != setting.get(defaults) {
+			config.sources[setting.name] = swarmConfigFilepath
+		}
+		if overrides == nil {
+			continue
+		}
+
+		if value, found := overrides.env[setting.envVarName()]; found {
+			if err := setting.set(config, value); err != nil {
+				return fmt.Errorf("Invalid %s '%s': %s", setting.envVarName(), value, err)
+			}
+			config.sources[setting.name] = sourceEnvVar + setting.envVarName()
+		}
+		if value, found := overrides.flags[setting.name]; found {
+			if err := setting.set(config, value); err != nil {
+				return fmt.Errorf("Invalid --%s '%s': %s", setting.name, value, err)
+			}
+			config.sources[setting.name] = sourceFlag + setting.name
+		}
+	}
+	return nil
+}
+
+// SettingValue is the effective value of an overridable setting, and where it came from
+type SettingValue struct {
+	Name   string
+	Value  string
+	Source string // SourceDefault, the filepath of swarm.json, or the environment variable or flag that set it
+}
+
+// Settings lists the effective value of every overridable setting
+func (config *SwarmConfig) Settings() []*SettingValue {
+	values := make([]*SettingValue, len(settings))
+	for i, setting := range settings {
+		source := config.sources[setting.name]
+		if source == "" {
+			source = SourceDefault
+		}
+		values[i] = &SettingValue{setting.name, setting.get(config), source}
+	}
+	return values
+}
+
+// IsOverridden tests whether a setting's value came from an environment variable or flag
+func (value *SettingValue) IsOverridden() bool {
+	return strings.HasPrefix(value.Source, sourceEnvVar) || strings.HasPrefix(value.Source, sourceFlag)
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOverrides(t *testing.T) {
+	overrides := NewOverrides([]string{"SWARM_PORT=9000", "PATH=/usr/bin", "SWARM_ROOT=/srv/a=b", "SWARM_BROKEN"}, nil)
+	assert.Equal(t, map[string]string{"SWARM_PORT": "9000", "SWARM_ROOT": "/srv/a=b"}, overrides.env)
+}
+
+func TestLayeredSwarmConfig(t *testing.T) {
+	workingDir, _ := os.Getwd()
+	const fileJSON = `{"root": "web", "server": {"port": 80, "open": false, "hotReload": true}, "monitor": {"debounceMillis": 300}}`
+
+	cases := map[string]struct {
+		environ  []string
+		flags    map[string]string
+		setting  string
+		expected string
+		source   string
+	}{
+		"default": {
+			setting:  "watcher",
+			expected: "auto",
+			source:   SourceDefault,
+		},
+		"file": {
+			setting:  "port",
+			expected: "80",
+			source:   "swarm.json",
+		},
+		"env-over-file": {
+			environ:  []string{"SWARM_PORT=9000"},
+			setting:  "port",
+			expected: "9000",
+			source:   "environment variable SWARM_PORT",
+		},
+		"flag-over-env": {
+			environ:  []string{"SWARM_PORT=9000"},
+			flags:    map[string]string{"port": "9001"},
+			setting:  "port",
+			expected: "9001",
+			source:   "flag --port",
+		},
+		"bool-env": {
+			environ:  []string{"SWARM_HOT_RELOAD=false"},
+			setting:  "hot-reload",
+			expected: "false",
+			source:   "environment variable SWARM_HOT_RELOAD",
+		},
+		"base-href-for-every-build": {
+			flags:    map[string]string{"base-href": "/"},
+			setting:  "base-href",
+			expected: "app=/,controlpanel=/",
+			source:   "flag --base-href",
+		},
+		"build": {
+			environ:  []string{"SWARM_BUILD=app, controlpanel"},
+			setting:  "build",
+			expected: "app,controlpanel",
+			source:   "environment variable SWARM_BUILD",
+		},
+		"root-is-relative-to-working-dir": {
+			environ:  []string{"SWARM_ROOT=other"},
+			setting:  "root",
+			expected: filepath.Join(workingDir, "other"),
+			source:   "environment variable SWARM_ROOT",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config, err := loadLayeredSwarmConfig([]byte(fileJSON), "swarm.json", "/work", NewOverrides(tc.environ, tc.flags))
+			assert.Nil(t, err)
+			for _, setting := range config.Settings() {
+				if setting.Name == tc.setting {
+					assert.Equal(t, tc.expected, setting.Value)
+					assert.Equal(t, tc.source, setting.Source)
+				}
+			}
+		})
+	}
+}
+
+func TestLayeredSwarmConfigRootMovesBuilds(t *testing.T) {
+	config, err := loadLayeredSwarmConfig(nil, "", "/work", NewOverrides([]string{"SWARM_ROOT=/srv/app"}, nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "/srv/app", config.RootPath)
+	assert.Equal(t, "/srv/app/build/systemjs_build_app.json", config.Builds["app"].BuildPath)
+}
+
+func TestLayeredSwarmConfigFilePortNotOverriddenByDefaultFlag(t *testing.T) {
+	config, err := loadLayeredSwarmConfig([]byte(`{"server": {"port": 80}}`), "swarm.json", "/work", NewOverrides(nil, map[string]string{}))
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(80), config.Server.Port)
+}
+
+func TestLayeredSwarmConfigInvalidOverride(t *testing.T) {
+	_, err := loadLayeredSwarmConfig(nil, "", "/work", NewOverrides([]string{"SWARM_PORT=http"}, nil))
+	assert.EqualError(t, err, `Invalid SWARM_PORT 'http': strconv.ParseUint: parsing "http": invalid syntax`)
+
+	_, err = loadLayeredSwarmConfig(nil, "", "/work", NewOverrides(nil, map[string]string{"watcher": "magic"}))
+	assert.EqualError(t, err, `Invalid --watcher 'magic': expected auto, notify or poll`)
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+// ProxyRoute forwards requests beneath a path prefix to an upstream server, e.g. "/api" --> http://localhost:5000.
+// Websocket upgrades are forwarded too
+type ProxyRoute struct {
+	Target          string            `json:"target"`          // upstream URL, e.g. http://localhost:5000/v2
+	StripPrefix     bool              `json:"stripPrefix"`     // removes the prefix before forwarding, e.g. /api/users ==> /users
+	ChangeOrigin    bool              `json:"changeOrigin"`    // sets the Host header to the upstream's host
+	Headers         map[string]string `json:"headers"`         // request headers to set ("" removes the header)
+	ResponseHeaders map[string]string `json:"responseHeaders"` // response headers to set ("" removes the header)
+	TimeoutMillis   uint              `json:"timeoutMillis"`   // how long to wait for the upstream to respond (0 = forever)
+	Insecure        bool              `json:"insecure"`        // skips verifying the upstream's certificate
+}
+
+// TargetURL parses the upstream URL, treating ws:// and wss:// as http:// and https://
+func (route *ProxyRoute) TargetURL() (*url.URL, error) {
+	target, err := url.Parse(route.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	switch target.Scheme {
+	case "ws":
+		target.Scheme = "http"
+	case "wss":
+		target.Scheme = "https"
+	}
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, errors.New("expected an absolute http(s) URL, e.g. http://localhost:5000")
+	}
+	return target, nil
+}
+
+// Timeout gets how long to wait for the upstream to respond, or 0 for no limit
+func (route *ProxyRoute) Timeout() time.Duration {
+	return time.Millisecond * time.Duration(route.TimeoutMillis)
+}
@@ -0,0 +1,21 @@
+package config
+
+import "regexp"
+
+// RewriteRule replaces text within the responses for matching paths, e.g. to adjust module paths in
+// systemjs.config.js, or source paths in a bundle's source map, for the development server
+type RewriteRule struct {
+	Path    string `json:"path"`    // gitignore-style pattern for the URL paths to rewrite, e.g. "systemjs.config.js" or "*.js.map"
+	Match   string `json:"match"`   // the text to replace (a regular expression if Regex is set)
+	Regex   bool   `json:"regex"`   // treats Match as a regular expression, so that Replace can refer to groups, e.g. $1
+	Replace string `json:"replace"` // the replacement text
+	Limit   int    `json:"limit"`   // the most replacements to make in each response (0 = no limit)
+}
+
+// CompileMatch compiles the pattern for a regex rule, returning nil for a literal rule
+func (rule *RewriteRule) CompileMatch() (*regexp.Regexp, error) {
+	if !rule.Regex {
+		return nil, nil
+	}
+	return regexp.Compile(rule.Match)
+}
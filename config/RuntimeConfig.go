@@ -3,14 +3,53 @@ package config
 // RuntimeConfig describes the expected state at runtime (currently, just what the base path will be)
 type RuntimeConfig struct {
 	// BaseHref gets the expected base path at runtime, e.g. <base href="app" /> ==> "app"
-	BuildPath               string `json:"path"`
-	BaseHref                string `json:"baseHref"`
+	BuildPath               string               `json:"path"`
+	BaseHref                string               `json:"baseHref"`
+	Interpolation           *InterpolationConfig `json:"interpolation"`
+	CSS                     *CSSConfig           `json:"css"`
 	pathInterpolationValues map[string]string
+	variantName             string
+	variantValues           map[string]string
 }
 
 // NewRuntimeConfig creates a RuntimeConfig
 func NewRuntimeConfig(buildPath string, baseHref string) *RuntimeConfig {
-	return &RuntimeConfig{buildPath, baseHref, map[string]string{}}
+	return &RuntimeConfig{buildPath, baseHref, nil, nil, map[string]string{}, "", nil}
+}
+
+// NewVariant creates a copy of a RuntimeConfig for one combination of the interpolation matrix
+func (rtc *RuntimeConfig) NewVariant(combination map[string]string) *RuntimeConfig {
+	variant := *rtc
+	variant.pathInterpolationValues = map[string]string{}
+	variant.variantName = VariantName(combination)
+	variant.variantValues = combination
+	return &variant
+}
+
+// VariantName gets the name of the variant this RuntimeConfig is for, or "" for the default build
+func (rtc *RuntimeConfig) VariantName() string {
+	return rtc.variantName
+}
+
+// VariantValues gets the interpolation values that override all others for this variant, or nil for the default build
+func (rtc *RuntimeConfig) VariantValues() map[string]string {
+	return rtc.variantValues
+}
+
+// InterpolationSources gets where import path interpolation values are read from
+func (rtc *RuntimeConfig) InterpolationSources() *InterpolationConfig {
+	if rtc.Interpolation == nil {
+		return DefaultInterpolationConfig()
+	}
+	return rtc.Interpolation
+}
+
+// CSSOptions gets the optional steps of the CSS pipeline (by default, none)
+func (rtc *RuntimeConfig) CSSOptions() *CSSConfig {
+	if rtc.CSS == nil {
+		return &CSSConfig{}
+	}
+	return rtc.CSS
 }
 
 // SourceMapsEnabled ...
@@ -27,3 +66,16 @@ func (rtc *RuntimeConfig) SetPathInterpolationValues(values map[string]string) {
 func (rtc *RuntimeConfig) ImportPathInterpolationValues() map[string]string {
 	return rtc.pathInterpolationValues
 }
+
+// ImportPathVariants returns the possible values of each interpolation key, if every variant of a conditional import
+// should be bundled, otherwise nil
+func (rtc *RuntimeConfig) ImportPathVariants() map[string][]string {
+	sources := rtc.InterpolationSources()
+	if !sources.AllVariants {
+		return nil
+	}
+	if sources.Variants == nil {
+		return map[string][]string{}
+	}
+	return sources.Variants
+}
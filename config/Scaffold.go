@@ -0,0 +1,86 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const buildDescriptionGlob = "build/systemjs_build_*.json"
+
+// scaffoldSearchDepth is how many levels of subdirectories are searched for build description files
+const scaffoldSearchDepth = 2
+
+// scaffoldConfig is the subset of swarm.json written by ScaffoldSwarmConfig
+type scaffoldConfig struct {
+	RootPath string                    `json:"root"`
+	Builds   map[string]*scaffoldBuild `json:"builds"`
+	Server   *ServerConfig             `json:"server"`
+}
+
+type scaffoldBuild struct {
+	BuildPath string `json:"path"`
+	BaseHref  string `json:"baseHref"`
+}
+
+// ScaffoldSwarmConfig creates the contents of a swarm.json file for a directory, with a build for each
+// build/systemjs_build_*.json file found within it (or its subdirectories, which then become the root)
+func ScaffoldSwarmConfig(dir string) ([]byte, error) {
+	rootPath, buildFilepaths := findBuildDescriptions(dir)
+	if len(buildFilepaths) == 0 {
+		return nil, errors.New("No " + buildDescriptionGlob + " files found in " + dir)
+	}
+
+	relativeRootPath, err := filepath.Rel(dir, rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	builds := map[string]*scaffoldBuild{}
+	for _, buildFilepath := range buildFilepaths {
+		filename := filepath.Base(buildFilepath)
+		name := strings.TrimSuffix(strings.TrimPrefix(filename, "systemjs_build_"), ".json")
+		builds[name] = &scaffoldBuild{"build/" + filename, name}
+	}
+
+	return json.MarshalIndent(&scaffoldConfig{
+		RootPath: filepath.ToSlash(relativeRootPath),
+		Builds:   builds,
+		Server:   NewServerConfig(defaultServerPort, true, true),
+	}, "", "  ")
+}
+
+// findBuildDescriptions finds the shallowest directory beneath dir containing build description files
+func findBuildDescriptions(dir string) (string, []string) {
+	candidates := []string{dir}
+	for depth := 0; depth <= scaffoldSearchDepth; depth++ {
+		var subdirs []string
+		for _, candidate := range candidates {
+			if matches, _ := filepath.Glob(filepath.Join(candidate, buildDescriptionGlob)); len(matches) > 0 {
+				sort.Strings(matches)
+				return candidate, matches
+			}
+			subdirs = append(subdirs, listSubdirectories(candidate)...)
+		}
+		candidates = subdirs
+	}
+	return "", nil
+}
+
+func listSubdirectories(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var subdirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && entry.Name() != "node_modules" {
+			subdirs = append(subdirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return subdirs
+}
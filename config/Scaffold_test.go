@@ -0,0 +1,34 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaffoldSwarmConfig(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+	buildDir := testutil.MakeSubdirectoryTree(tempDir, "web/App/build")
+	testutil.WriteTextFile(buildDir, "systemjs_build_app.json", `{}`)
+	testutil.WriteTextFile(buildDir, "systemjs_build_controlpanel.json", `{}`)
+	testutil.WriteTextFile(buildDir, "other.json", `{}`)
+
+	contents, err := ScaffoldSwarmConfig(tempDir)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"root": "web/App",
+		"builds": {
+			"app": {"path": "build/systemjs_build_app.json", "baseHref": "app"},
+			"controlpanel": {"path": "build/systemjs_build_controlpanel.json", "baseHref": "controlpanel"}
+		},
+		"server": {"port": 8096, "open": true, "hotReload": true}
+	}`, string(contents))
+	assert.Empty(t, ValidateSwarmConfig("swarm.json", contents))
+
+	emptyDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(emptyDir)
+	_, err = ScaffoldSwarmConfig(emptyDir)
+	assert.EqualError(t, err, "No build/systemjs_build_*.json files found in "+emptyDir)
+}
@@ -5,9 +5,30 @@ type ServerConfig struct {
 	Port      uint16 `json:"port"`
 	Open      bool   `json:"open"`
 	HotReload bool   `json:"hotReload"`
+	HTTPS     bool   `json:"https,omitempty"`
+	CertFile  string `json:"certFile,omitempty"` // PEM certificate for HTTPS (default: a generated, self-signed localhost certificate)
+	KeyFile   string `json:"keyFile,omitempty"`  // PEM private key for CertFile
+
+	Proxy           map[string]*ProxyRoute `json:"proxy,omitempty"` // path prefix --> upstream, e.g. "/api"
+	HistoryFallback *HistoryFallbackConfig `json:"historyFallback,omitempty"`
+	Rewrite         []*RewriteRule         `json:"rewrite,omitempty"` // applied in order
+	Inject          *InjectConfig          `json:"inject,omitempty"`
+}
+
+// HistoryFallbackConfig serves index.html for unknown paths beneath a build's base href, so that a single page app
+// using the history API can route deep links like /app/students/42.  Only requests for HTML are rewritten, and never
+// for paths with a file extension, so that missing assets still 404
+type HistoryFallbackConfig struct {
+	Enabled bool     `json:"enabled"`
+	Exclude []string `json:"exclude"` // gitignore-style patterns, relative to the base href, for paths that should 404
+}
+
+// IsHistoryFallbackEnabled tests whether unknown paths should be served index.html
+func (sc *ServerConfig) IsHistoryFallbackEnabled() bool {
+	return sc.HistoryFallback != nil && sc.HistoryFallback.Enabled
 }
 
 // NewServerConfig creates a new ServerConfig
 func NewServerConfig(port uint16, open bool, enableHotReload bool) *ServerConfig {
-	return &ServerConfig{port, open, enableHotReload}
+	return &ServerConfig{Port: port, Open: open, HotReload: enableHotReload}
 }
@@ -18,15 +18,18 @@ const defaultRootPathMacOSAndLinux = "%s/web/App" // <-- %s will be replaced wit
 const defaultServerPort uint16 = 8096
 
 var defaultMonitorExtensions = []string{".js", ".html", ".css", ".json"}
-var defaultBuilds = map[string]*RuntimeConfig{
-	"app": NewRuntimeConfig(
-		"build/systemjs_build_app.json",
-		"app",
-	),
-	"controlpanel": NewRuntimeConfig(
-		"build/systemjs_build_controlpanel.json",
-		"controlpanel",
-	),
+
+func defaultBuilds() map[string]*RuntimeConfig {
+	return map[string]*RuntimeConfig{
+		"app": NewRuntimeConfig(
+			"build/systemjs_build_app.json",
+			"app",
+		),
+		"controlpanel": NewRuntimeConfig(
+			"build/systemjs_build_controlpanel.json",
+			"controlpanel",
+		),
+	}
 }
 
 // SwarmConfig is the root configuration file
@@ -34,7 +37,13 @@ type SwarmConfig struct {
 	RootPath string                    `json:"root"`
 	Monitor  *MonitorConfig            `json:"monitor"`
 	Builds   map[string]*RuntimeConfig `json:"builds"`
+	Build    []string                  `json:"build"` // the builds to run, unless chosen on the command line
 	Server   *ServerConfig             `json:"server"`
+
+	sources             map[string]string // setting name --> where its value came from
+	swarmConfigFilepath string            // "" if there's no swarm.json
+	startDir            string            // where swarm.json was searched for
+	overrides           *Overrides
 }
 
 func (config *SwarmConfig) expandAndNormalisePaths(cwd string) {
@@ -50,11 +59,16 @@ func (config *SwarmConfig) expandAndNormalisePaths(cwd string) {
 	for _, b := range config.Builds {
 		b.BuildPath = norm(config.RootPath, b.BuildPath)
 	}
+	if config.Server != nil {
+		for _, certPath := range []*string{&config.Server.CertFile, &config.Server.KeyFile} {
+			if *certPath != "" {
+				*certPath = norm(cwd, *certPath)
+			}
+		}
+	}
 }
 
-func (config *SwarmConfig) backfillWithDefaults(cwd string) {
-	defaults := DefaultSwarmConfig(cwd)
-
+func (config *SwarmConfig) backfillWithDefaults(defaults *SwarmConfig) {
 	if config.RootPath == "" {
 		config.RootPath = defaults.RootPath
 	}
@@ -65,6 +79,8 @@ func (config *SwarmConfig) backfillWithDefaults(cwd string) {
 
 	if config.Monitor == nil {
 		config.Monitor = defaults.Monitor
+	} else {
+		config.Monitor.backfillWithDefaults(defaults.Monitor)
 	}
 
 	if config.Server == nil {
@@ -72,27 +88,93 @@ func (config *SwarmConfig) backfillWithDefaults(cwd string) {
 	}
 }
 
-// TryLoadSwarmConfigFromCWD tries to load a swarm.json configuration from the current working directory
-func TryLoadSwarmConfigFromCWD(portFlag *uint16) (*SwarmConfig, error) {
+// SwarmConfigFilepathInCWD gets the path of the swarm.json file in the current working directory (which may not exist)
+func SwarmConfigFilepathInCWD() string {
 	cwd, err := os.Getwd()
 	if err != nil {
 		log.Fatalf("Failed to get CWD: %s", err)
 	}
-	swarmjsonFilepath := filepath.Join(cwd, swarmConfigDefaultFilename)
-	if _, err := os.Stat(swarmjsonFilepath); err != nil {
-		return DefaultSwarmConfig(cwd), nil
+	return filepath.Join(cwd, swarmConfigDefaultFilename)
+}
+
+// FindSwarmConfigFilepath searches a directory, then each of its parents, for a swarm.json file
+func FindSwarmConfigFilepath(startDir string) (string, bool) {
+	dir := startDir
+	for {
+		candidate := filepath.Join(dir, swarmConfigDefaultFilename)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// LocateSwarmConfig gets the swarm.json file to use: the one given by --config (or SWARM_CONFIG), or else the first
+// one found in startDir or its parents.  Returns "" if there isn't one
+func LocateSwarmConfig(startDir string, overrides *Overrides) (string, error) {
+	configPath := overrides.configPath()
+	if configPath == "" {
+		swarmConfigFilepath, _ := FindSwarmConfigFilepath(startDir)
+		return swarmConfigFilepath, nil
+	}
+
+	if !filepath.IsAbs(configPath) {
+		configPath = filepath.Join(startDir, configPath)
+	}
+	if info, err := os.Stat(configPath); err == nil && info.IsDir() {
+		configPath = filepath.Join(configPath, swarmConfigDefaultFilename)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return "", errors.New("Config file not found: " + configPath)
+	}
+	return configPath, nil
+}
+
+// DiscoverSwarmConfig loads the swarm.json file found by LocateSwarmConfig, starting from the current working
+// directory, then applies any overrides from environment variables and flags.  Paths within swarm.json are relative
+// to the directory that it's in
+func DiscoverSwarmConfig(overrides *Overrides) (*SwarmConfig, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
 	}
-	config, err := LoadSwarmConfig(swarmjsonFilepath, cwd)
+	return discoverSwarmConfig(cwd, overrides)
+}
+
+func discoverSwarmConfig(startDir string, overrides *Overrides) (*SwarmConfig, error) {
+	swarmConfigFilepath, err := LocateSwarmConfig(startDir, overrides)
 	if err != nil {
 		return nil, err
 	}
 
-	if portFlag != nil { // flag overrides
-		config.Server.Port = *portFlag
+	var config *SwarmConfig
+	if swarmConfigFilepath == "" {
+		config, err = loadLayeredSwarmConfig(nil, "", startDir, overrides)
+	} else {
+		config, err = loadSwarmConfigFile(swarmConfigFilepath, filepath.Dir(swarmConfigFilepath), overrides)
 	}
+	if err != nil {
+		return nil, err
+	}
+	config.startDir = startDir
 	return config, nil
 }
 
+// Reload discovers and loads the configuration again, with the same overrides
+func (config *SwarmConfig) Reload() (*SwarmConfig, error) {
+	return discoverSwarmConfig(config.startDir, config.overrides)
+}
+
+// SwarmConfigFilepath gets the path of the swarm.json file that the configuration was loaded from, or "" if none
+func (config *SwarmConfig) SwarmConfigFilepath() string {
+	return config.swarmConfigFilepath
+}
+
 func getDefaultRootPath() string {
 	usr, _ := user.Current()
 
@@ -105,18 +187,27 @@ func getDefaultRootPath() string {
 
 // DefaultSwarmConfig loads the default swarm.json configuration file
 func DefaultSwarmConfig(cwd string) *SwarmConfig {
-	config := &SwarmConfig{
+	config := defaultSwarmConfig()
+	config.expandAndNormalisePaths(cwd)
+	return config
+}
+
+// defaultSwarmConfig creates the default configuration, before its paths are expanded
+func defaultSwarmConfig() *SwarmConfig {
+	return &SwarmConfig{
 		RootPath: getDefaultRootPath(),
 		Monitor:  NewMonitorConfig(defaultMonitorExtensions, 150),
-		Builds:   defaultBuilds,
+		Builds:   defaultBuilds(),
 		Server:   NewServerConfig(defaultServerPort, true, true),
 	}
-	config.expandAndNormalisePaths(cwd)
-	return config
 }
 
 // LoadSwarmConfig loads a swarm.json configuration file
 func LoadSwarmConfig(swarmConfigFilepath string, cwd string) (*SwarmConfig, error) {
+	return loadSwarmConfigFile(swarmConfigFilepath, cwd, nil)
+}
+
+func loadSwarmConfigFile(swarmConfigFilepath string, cwd string, overrides *Overrides) (*SwarmConfig, error) {
 	if filepath.Ext(swarmConfigFilepath) == "" {
 		swarmConfigFilepath += ".json"
 	}
@@ -126,19 +217,45 @@ func LoadSwarmConfig(swarmConfigFilepath string, cwd string) (*SwarmConfig, erro
 		return nil, errors.New("Invalid swarm config file: " + swarmConfigFilepath)
 	}
 
-	jsonString := string(buildBytes)
-	config, err := LoadSwarmConfigString(jsonString, cwd)
+	config, err := loadLayeredSwarmConfig(buildBytes, swarmConfigFilepath, cwd, overrides)
+	if err == nil {
+		warnAboutProblems(ValidateSwarmConfig(swarmConfigFilepath, buildBytes))
+	}
 	return config, err
 }
 
 // LoadSwarmConfigString loads a swarm.json file from a string
 func LoadSwarmConfigString(swarmConfigJSON string, cwd string) (*SwarmConfig, error) {
-	var config *SwarmConfig
-	err := json.Unmarshal([]byte(swarmConfigJSON), &config)
-	if err != nil {
-		return nil, errors.New("Invalid JSON in swarm config file: " + err.Error())
+	return loadLayeredSwarmConfig([]byte(swarmConfigJSON), swarmConfigDefaultFilename, cwd, nil)
+}
+
+// loadLayeredSwarmConfig loads a configuration from its layers: defaults, then the contents of swarm.json (if any),
+// then environment variables and flags
+func loadLayeredSwarmConfig(swarmConfigJSON []byte, swarmConfigFilepath string, cwd string, overrides *Overrides) (*SwarmConfig, error) {
+	config := &SwarmConfig{}
+	if swarmConfigJSON != nil {
+		if err := json.Unmarshal(swarmConfigJSON, config); err != nil {
+			return nil, errors.New("Invalid JSON in swarm config file: " + err.Error())
+		}
 	}
-	config.backfillWithDefaults(cwd)
+
+	defaults := defaultSwarmConfig()
+	config.backfillWithDefaults(defaults)
+	if err := config.applyLayers(defaults, swarmConfigFilepath, overrides); err != nil {
+		return nil, err
+	}
+	config.swarmConfigFilepath = swarmConfigFilepath
+	config.overrides = overrides
 	config.expandAndNormalisePaths(cwd)
 	return config, nil
 }
+
+// BuildName finds the name of a build within the config, or "" if it isn't one of the config's builds
+func (config *SwarmConfig) BuildName(runtimeConfig *RuntimeConfig) string {
+	for name, build := range config.Builds {
+		if build == runtimeConfig {
+			return name
+		}
+	}
+	return ""
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -108,14 +109,124 @@ func TestNormalisePaths(t *testing.T) {
 	assert.Equal(t, "c:\\building", config.Builds["one"].BuildPath)
 }
 
-func TestTryLoadSwarmConfigFromCWD(t *testing.T) {
+func TestPartialMonitorConfig(t *testing.T) {
+	cases := map[string]struct {
+		json     string
+		ignore   []string
+		debounce uint
+	}{
+		"extensions only": {`{"monitor": {"extensions": [".ts"]}}`, []string{".git/", "node_modules/"}, 150},
+		"explicit ignore": {`{"monitor": {"extensions": [".ts"], "ignore": ["dist/"], "debounceMillis": 50}}`, []string{"dist/"}, 50},
+		"nothing ignored": {`{"monitor": {"ignore": []}}`, []string{}, 150},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config, err := LoadSwarmConfigString(tc.json, "")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.ignore, config.Monitor.Ignore)
+			assert.Equal(t, tc.debounce, config.Monitor.DebounceMillis)
+			assert.NotEmpty(t, config.Monitor.Extensions)
+		})
+	}
+}
+
+func TestDiscoverSwarmConfig(t *testing.T) {
 	temppath := testutil.CreateTempDir()
 	defer testutil.RemoveTempDir(temppath)
 	testutil.WriteTextFile(temppath, swarmConfigDefaultFilename, swarmConfigJSONComplete)
 	os.Chdir(temppath)
-	_, err := TryLoadSwarmConfigFromCWD(nil)
+	_, err := DiscoverSwarmConfig(nil)
 	assert.Nil(t, err)
-	var port = uint16(1234)
-	conf, _ := TryLoadSwarmConfigFromCWD(&port)
+	conf, _ := DiscoverSwarmConfig(NewOverrides(nil, map[string]string{"port": "1234"}))
 	assert.Equal(t, uint16(1234), conf.Server.Port)
 }
+
+func TestDiscoverSwarmConfigInParent(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	swarmConfigFilepath := testutil.WriteTextFile(temppath, swarmConfigDefaultFilename, `{"root": "web/App"}`)
+	nestedDir := testutil.MakeSubdirectoryTree(temppath, "web/App/src/components")
+
+	conf, err := discoverSwarmConfig(nestedDir, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, swarmConfigFilepath, conf.SwarmConfigFilepath())
+	assert.Equal(t, filepath.Join(temppath, "web", "App"), conf.RootPath, "root should be relative to swarm.json")
+	assert.Equal(t, filepath.Join(temppath, "web", "App", "build", "systemjs_build_app.json"), conf.Builds["app"].BuildPath)
+}
+
+func TestLocateSwarmConfig(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	inCWD := testutil.WriteTextFile(temppath, swarmConfigDefaultFilename, `{}`)
+	configDir := testutil.MakeSubdirectoryTree(temppath, "config")
+	elsewhere := testutil.WriteTextFile(configDir, "swarm.dev.json", `{}`)
+	inConfigDir := testutil.WriteTextFile(configDir, swarmConfigDefaultFilename, `{}`)
+	emptyDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(emptyDir)
+
+	cases := map[string]struct {
+		startDir string
+		flags    map[string]string
+		environ  []string
+		expected string
+		err      string
+	}{
+		"cwd":          {startDir: temppath, expected: inCWD},
+		"none":         {startDir: emptyDir, expected: ""},
+		"flag-file":    {startDir: temppath, flags: map[string]string{"config": elsewhere}, expected: elsewhere},
+		"flag-dir":     {startDir: temppath, flags: map[string]string{"config": configDir}, expected: inConfigDir},
+		"env":          {startDir: temppath, environ: []string{"SWARM_CONFIG=" + elsewhere}, expected: elsewhere},
+		"flag-missing": {startDir: temppath, flags: map[string]string{"config": filepath.Join(temppath, "nope.json")}, err: "Config file not found: " + filepath.Join(temppath, "nope.json")},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := LocateSwarmConfig(tc.startDir, NewOverrides(tc.environ, tc.flags))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestInterpolationSources(t *testing.T) {
+	value, err := LoadSwarmConfigString(`{
+		"builds": {
+			"app": {
+				"path": "build/systemjs_build_app.json",
+				"baseHref": "app",
+				"interpolation": { "js": ["Config.js"], "json": "build/values.json", "values": { "Config.LOCALE": "fr" } }
+			},
+			"plain": { "path": "build/systemjs_build_plain.json", "baseHref": "plain" }
+		}
+	}`, "")
+	assert.Nil(t, err)
+
+	sources := value.Builds["app"].InterpolationSources()
+	assert.True(t, sources.IsSource("build/values.json"))
+	assert.True(t, sources.IsSource("./Config.js"))
+	assert.False(t, sources.IsSource("app/Config.js"))
+	assert.Equal(t, "fr", sources.Values["Config.LOCALE"])
+
+	defaults := value.Builds["plain"].InterpolationSources()
+	assert.Equal(t, []string{"Config.js"}, defaults.Filepaths())
+}
+
+func TestMatrixCombinations(t *testing.T) {
+	interpolation := &InterpolationConfig{Matrix: map[string][]string{
+		"Config.SUFFIX": {".mobile", ""},
+		"Config.LOCALE": {"en", "fr"},
+		"Config.EMPTY":  {},
+	}}
+	combinations := interpolation.MatrixCombinations()
+	names := make([]string, len(combinations))
+	for i, combination := range combinations {
+		names[i] = VariantName(combination)
+	}
+	assert.Equal(t, []string{"en-mobile", "en-none", "fr-mobile", "fr-none"}, names)
+	assert.Equal(t, map[string]string{"Config.LOCALE": "fr", "Config.SUFFIX": ".mobile"}, combinations[2])
+
+	assert.Nil(t, DefaultInterpolationConfig().MatrixCombinations())
+}
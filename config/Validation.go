@@ -0,0 +1,442 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mrcrowl/swarm/logging"
+)
+
+// ConfigProblem is a problem found while validating a configuration file
+type ConfigProblem struct {
+	Filepath string
+	Line     int // 1-based, or 0 if the problem isn't at a particular position
+	Column   int // 1-based
+	Message  string
+}
+
+func (problem *ConfigProblem) String() string {
+	if problem.Line == 0 {
+		return fmt.Sprintf("%s: %s", problem.Filepath, problem.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", problem.Filepath, problem.Line, problem.Column, problem.Message)
+}
+
+// jsonDocument records where each value in a JSON file starts, keyed by a path like modules[2].exclude[0]
+type jsonDocument struct {
+	filepath string
+	contents []byte
+	offsets  map[string]int64
+}
+
+// problemAt creates a ConfigProblem at the position of the value at a path (or the start of the file, if it's missing)
+func (doc *jsonDocument) problemAt(valuePath string, format string, args ...interface{}) *ConfigProblem {
+	return doc.problemAtOffset(doc.offsets[valuePath], format, args...)
+}
+
+func (doc *jsonDocument) problemAtOffset(offset int64, format string, args ...interface{}) *ConfigProblem {
+	line, column := 1, 1
+	for _, b := range doc.contents[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return &ConfigProblem{doc.filepath, line, column, fmt.Sprintf(format, args...)}
+}
+
+// parseJSONDocument walks a JSON file, recording the position of every value and reporting any syntax errors,
+// or keys which don't match a field of the type the file will be unmarshalled into
+func parseJSONDocument(filepath string, contents []byte, typ reflect.Type) (*jsonDocument, []*ConfigProblem) {
+	walker := &jsonWalker{
+		doc:     &jsonDocument{filepath, contents, map[string]int64{}},
+		decoder: json.NewDecoder(bytes.NewReader(contents)),
+	}
+
+	if err := walker.walkValue("", typ); err != nil {
+		return walker.doc, append(walker.problems, walker.syntaxProblem(err))
+	}
+	if _, err := walker.decoder.Token(); err != io.EOF {
+		return walker.doc, append(walker.problems, walker.doc.problemAtOffset(walker.decoder.InputOffset(), "unexpected content after the end of the JSON"))
+	}
+
+	var value interface{}
+	if typ != nil {
+		value = reflect.New(typ).Interface()
+	}
+	if err := json.Unmarshal(contents, &value); err != nil {
+		return walker.doc, append(walker.problems, walker.syntaxProblem(err))
+	}
+	return walker.doc, walker.problems
+}
+
+type jsonWalker struct {
+	doc      *jsonDocument
+	decoder  *json.Decoder
+	problems []*ConfigProblem
+}
+
+// nextToken reads a token, and the offset that it starts at
+func (walker *jsonWalker) nextToken() (json.Token, int64, error) {
+	start := walker.decoder.InputOffset()
+	for start < int64(len(walker.doc.contents)) && strings.IndexByte(" \t\r\n:,", walker.doc.contents[start]) >= 0 {
+		start++
+	}
+	token, err := walker.decoder.Token()
+	return token, start, err
+}
+
+func (walker *jsonWalker) walkValue(valuePath string, typ reflect.Type) error {
+	token, start, err := walker.nextToken()
+	if err != nil {
+		return err
+	}
+	walker.doc.offsets[valuePath] = start
+
+	switch token {
+	case json.Delim('{'):
+		for walker.decoder.More() {
+			keyToken, keyStart, err := walker.nextToken()
+			if err != nil {
+				return err
+			}
+			key := keyToken.(string)
+			fieldType, known := jsonFieldType(typ, key)
+			if !known {
+				walker.problems = append(walker.problems, walker.doc.problemAtOffset(keyStart, "unknown field %q%s", key, suggestField(typ, key)))
+			}
+			if err := walker.walkValue(joinValuePath(valuePath, key), fieldType); err != nil {
+				return err
+			}
+		}
+		_, _, err = walker.nextToken() // }
+		return err
+
+	case json.Delim('['):
+		for i := 0; walker.decoder.More(); i++ {
+			if err := walker.walkValue(fmt.Sprintf("%s[%d]", valuePath, i), jsonElemType(typ)); err != nil {
+				return err
+			}
+		}
+		_, _, err = walker.nextToken() // ]
+		return err
+	}
+
+	return nil
+}
+
+func (walker *jsonWalker) syntaxProblem(err error) *ConfigProblem {
+	switch typedErr := err.(type) {
+	case *json.SyntaxError:
+		offset := typedErr.Offset
+		if offset > 0 && offset < int64(len(walker.doc.contents)) {
+			offset-- // the offending character has already been read
+		}
+		return walker.doc.problemAtOffset(offset, "invalid JSON: %s", typedErr)
+	case *json.UnmarshalTypeError:
+		offset, found := walker.doc.offsets[typedErr.Field]
+		if !found {
+			offset = typedErr.Offset
+		}
+		return walker.doc.problemAtOffset(offset, "%q should be a %s, not a %s", typedErr.Field, typedErr.Type, typedErr.Value)
+	}
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return walker.doc.problemAtOffset(int64(len(walker.doc.contents)), "invalid JSON: unexpected end of file")
+	}
+	return walker.doc.problemAtOffset(walker.decoder.InputOffset(), "invalid JSON: %s", err)
+}
+
+func joinValuePath(valuePath string, key string) string {
+	if valuePath == "" {
+		return key
+	}
+	return valuePath + "." + key
+}
+
+// jsonFields maps the JSON names of a struct's fields to their types
+func jsonFields(typ reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for name, fieldType := range jsonFields(field.Type) {
+				fields[name] = fieldType
+			}
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// jsonFieldType finds the type of the value for a key, where typ is the type of the object (or nil if unknown)
+func jsonFieldType(typ reflect.Type, key string) (reflect.Type, bool) {
+	typ = derefType(typ)
+	if typ == nil {
+		return nil, true
+	}
+
+	switch typ.Kind() {
+	case reflect.Map:
+		return typ.Elem(), true
+	case reflect.Struct:
+		for name, fieldType := range jsonFields(typ) {
+			if strings.EqualFold(name, key) { // like encoding/json
+				return fieldType, true
+			}
+		}
+		return nil, false
+	}
+	return nil, true
+}
+
+func jsonElemType(typ reflect.Type) reflect.Type {
+	typ = derefType(typ)
+	if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+		return typ.Elem()
+	}
+	return nil
+}
+
+func derefType(typ reflect.Type) reflect.Type {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// suggestField suggests a similarly spelled field for an unknown key, e.g. "exlcude" ==> "exclude"
+func suggestField(typ reflect.Type, key string) string {
+	typ = derefType(typ)
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return ""
+	}
+
+	var names []string
+	for name := range jsonFields(typ) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if editDistance(strings.ToLower(name), strings.ToLower(key)) <= 2 {
+			return fmt.Sprintf(" (did you mean %q?)", name)
+		}
+	}
+	return fmt.Sprintf(" (expected one of: %s)", strings.Join(names, ", "))
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// warnAboutProblems prints problems that don't prevent a configuration file from loading, e.g. unknown fields
+func warnAboutProblems(problems []*ConfigProblem) {
+	for _, problem := range problems {
+		logging.Warn("config-problem", logging.Fields{"file": problem.Filepath, "line": problem.Line, "column": problem.Column}, "%s", problem)
+	}
+}
+
+// ValidateSwarmConfig checks the contents of a swarm.json file for invalid JSON, unknown fields, invalid rewrite
+// rules, invalid inject scripts and invalid proxy targets
+func ValidateSwarmConfig(swarmConfigFilepath string, contents []byte) []*ConfigProblem {
+	_, problems := validateSwarmConfig(swarmConfigFilepath, contents)
+	return problems
+}
+
+func validateSwarmConfig(swarmConfigFilepath string, contents []byte) (*jsonDocument, []*ConfigProblem) {
+	doc, problems := parseJSONDocument(swarmConfigFilepath, contents, reflect.TypeOf(SwarmConfig{}))
+
+	var config SwarmConfig
+	if err := json.Unmarshal(contents, &config); err != nil || config.Server == nil {
+		return doc, problems
+	}
+
+	for i, rule := range config.Server.Rewrite {
+		rulePath := fmt.Sprintf("server.rewrite[%d]", i)
+		if rule == nil || rule.Path == "" || rule.Match == "" {
+			problems = append(problems, doc.problemAt(rulePath, "rewrite rule needs a path and match"))
+		} else if _, err := rule.CompileMatch(); err != nil {
+			problems = append(problems, doc.problemAt(rulePath+".match", "invalid rewrite regex: %s", err))
+		}
+	}
+
+	if inject := config.Server.Inject; inject != nil {
+		for i, script := range inject.Head {
+			if err := script.Validate(); err != nil {
+				problems = append(problems, doc.problemAt(fmt.Sprintf("server.inject.head[%d]", i), "%s", err))
+			}
+		}
+		for i, script := range inject.Body {
+			if err := script.Validate(); err != nil {
+				problems = append(problems, doc.problemAt(fmt.Sprintf("server.inject.body[%d]", i), "%s", err))
+			}
+		}
+	}
+
+	var prefixes []string
+	for prefix := range config.Server.Proxy {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(prefix, "/") {
+			problems = append(problems, doc.problemAt("server.proxy."+prefix, "proxy prefix %q should start with /", prefix))
+		}
+		if route := config.Server.Proxy[prefix]; route != nil {
+			if _, err := route.TargetURL(); err != nil {
+				problems = append(problems, doc.problemAt("server.proxy."+prefix+".target", "invalid proxy target %q: %s", route.Target, err))
+			}
+		}
+	}
+	return doc, problems
+}
+
+// ValidateBuildDescription checks the contents of a build description file for invalid JSON, unknown fields,
+// duplicate module names and dangling excludes.  If rootPath isn't "", it also checks that every module and
+// include exists within it
+func ValidateBuildDescription(buildFilepath string, contents []byte, rootPath string) []*ConfigProblem {
+	doc, problems := parseJSONDocument(buildFilepath, contents, reflect.TypeOf(BuildDescription{}))
+	if len(problems) > 0 {
+		return problems
+	}
+
+	var build BuildDescription
+	json.Unmarshal(contents, &build)
+
+	firstDefinitions := map[string]string{}
+	for i, module := range build.Modules {
+		modulePath := fmt.Sprintf("modules[%d]", i)
+		if module == nil || module.Name == "" {
+			problems = append(problems, doc.problemAt(modulePath, "module has no name"))
+			continue
+		}
+
+		if firstPath, found := firstDefinitions[module.Name]; found {
+			first := doc.problemAt(firstPath, "")
+			problems = append(problems, doc.problemAt(modulePath+".name", "duplicate module name %q (first defined at line %d)", module.Name, first.Line))
+		} else {
+			firstDefinitions[module.Name] = modulePath + ".name"
+		}
+
+		if rootPath != "" && !moduleFileExists(rootPath, path.Join(build.Base, module.Name)) {
+			problems = append(problems, doc.problemAt(modulePath+".name", "module %q not found", module.Name))
+		}
+		for j, include := range module.Include {
+			if rootPath != "" && !moduleFileExists(rootPath, path.Join(build.Base, include)) {
+				problems = append(problems, doc.problemAt(fmt.Sprintf("%s.include[%d]", modulePath, j), "include %q not found", include))
+			}
+		}
+	}
+
+	for i, module := range build.Modules {
+		if module == nil {
+			continue
+		}
+		for j, exclude := range module.Exclude {
+			if _, found := firstDefinitions[exclude]; !found {
+				problems = append(problems, doc.problemAt(fmt.Sprintf("modules[%d].exclude[%d]", i, j), "exclude %q doesn't match any module", exclude))
+			}
+		}
+	}
+
+	return problems
+}
+
+// moduleFileExists tests whether a root-relative module path exists, e.g. as a .js file
+func moduleFileExists(rootPath string, relativePath string) bool {
+	for _, ext := range []string{"", ".js"} {
+		if info, err := os.Stat(filepath.Join(rootPath, relativePath+ext)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckConfig validates a swarm.json file (if it exists) and the build description file of each of its builds.
+// Returns the problems found, and the filepaths that were checked
+func CheckConfig(swarmConfigFilepath string, cwd string) ([]*ConfigProblem, []string) {
+	var problems []*ConfigProblem
+	var checkedFilepaths []string
+	var doc *jsonDocument
+	swarmConfig := DefaultSwarmConfig(cwd)
+
+	if contents, err := ioutil.ReadFile(swarmConfigFilepath); err == nil {
+		checkedFilepaths = append(checkedFilepaths, swarmConfigFilepath)
+		doc, problems = validateSwarmConfig(swarmConfigFilepath, contents)
+		if swarmConfig, err = LoadSwarmConfigString(string(contents), cwd); err != nil {
+			return problems, checkedFilepaths
+		}
+	}
+
+	var buildNames []string
+	for name := range swarmConfig.Builds {
+		buildNames = append(buildNames, name)
+	}
+	sort.Strings(buildNames)
+
+	for _, name := range buildNames {
+		buildFilepath := BuildDescriptionFilepath(swarmConfig.Builds[name].BuildPath)
+		contents, err := ioutil.ReadFile(buildFilepath)
+		if err != nil {
+			message := fmt.Sprintf("build description file for %q not found: %s", name, buildFilepath)
+			if doc != nil {
+				problems = append(problems, doc.problemAt("builds."+name+".path", "%s", message))
+			} else {
+				problems = append(problems, &ConfigProblem{Filepath: swarmConfigFilepath, Message: message})
+			}
+			continue
+		}
+
+		checkedFilepaths = append(checkedFilepaths, buildFilepath)
+		problems = append(problems, ValidateBuildDescription(buildFilepath, contents, swarmConfig.RootPath)...)
+	}
+
+	return problems, checkedFilepaths
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func problemStrings(problems []*ConfigProblem) []string {
+	strs := make([]string, len(problems))
+	for i, problem := range problems {
+		strs[i] = problem.String()
+	}
+	return strs
+}
+
+func TestValidateSwarmConfig(t *testing.T) {
+	cases := map[string]struct {
+		json     string
+		expected []string
+	}{
+		"valid": {
+			json:     `{"root": ".", "builds": {"app": {"path": "build/app", "baseHref": "app"}}, "server": {"port": 8080}}`,
+			expected: []string{},
+		},
+		"unknown-top-level": {
+			json:     "{\n  \"root\": \".\",\n  \"sever\": {}\n}",
+			expected: []string{`swarm.json:3:3: unknown field "sever" (did you mean "server"?)`},
+		},
+		"unknown-nested": {
+			json:     "{\"builds\": {\"app\": {\"path\": \"a\", \"bseHref\": \"app\"}}}",
+			expected: []string{`swarm.json:1:34: unknown field "bseHref" (did you mean "baseHref"?)`},
+		},
+		"unknown-no-suggestion": {
+			json:     `{"builds": {"app": {"colour": "red"}}}`,
+			expected: []string{`swarm.json:1:21: unknown field "colour" (expected one of: baseHref, css, interpolation, path)`},
+		},
+		"case-insensitive": {
+			json:     `{"Root": "."}`,
+			expected: []string{},
+		},
+		"free-form-values": {
+			json:     `{"builds": {"app": {"interpolation": {"values": {"anything.goes": "1"}}}}}`,
+			expected: []string{},
+		},
+		"wrong-type": {
+			json:     "{\n\"server\": {\"port\": \"80\"}}",
+			expected: []string{`swarm.json:2:20: "server.port" should be a uint16, not a string`},
+		},
+		"syntax-error": {
+			json:     "{\n  \"root\": \".\",,\n}",
+			expected: []string{`swarm.json:2:15: invalid JSON: invalid character ',' looking for beginning of value`},
+		},
+		"invalid-proxy": {
+			json: "{\"server\": {\"proxy\": {\n  \"/api\": {\"target\": \"localhost:5000\"},\n  \"ws\": {\"target\": \"ws://localhost:5001\"}\n}}}",
+			expected: []string{
+				`swarm.json:2:22: invalid proxy target "localhost:5000": expected an absolute http(s) URL, e.g. http://localhost:5000`,
+				`swarm.json:3:9: proxy prefix "ws" should start with /`,
+			},
+		},
+		"invalid-rewrite": {
+			json: "{\"server\": {\"rewrite\": [\n  {\"path\": \"*.js\", \"match\": \"(\", \"regex\": true},\n  {\"match\": \"x\"}\n]}}",
+			expected: []string{
+				"swarm.json:2:29: invalid rewrite regex: error parsing regexp: missing closing ): `(`",
+				`swarm.json:3:3: rewrite rule needs a path and match`,
+			},
+		},
+		"invalid-inject": {
+			json: "{\"server\": {\"inject\": {\n  \"head\": [{\"src\": \"/a.js\"}, {}],\n  \"body\": [{\"src\": \"/b.js\", \"inline\": \"b();\"}]\n}}}",
+			expected: []string{
+				`swarm.json:2:30: an inject script needs either a src or inline script`,
+				`swarm.json:3:12: an inject script needs either a src or inline script`,
+			},
+		},
+		"truncated": {
+			json:     "{\"root\": \".\"",
+			expected: []string{`swarm.json:1:13: invalid JSON: unexpected end of JSON input`},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual := problemStrings(ValidateSwarmConfig("swarm.json", []byte(tc.json)))
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestValidateBuildDescription(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+	srcDir := testutil.MakeSubdirectoryTree(tempDir, "app/src")
+	testutil.WriteTextFile(srcDir, "main.js", "")
+	testutil.WriteTextFile(srcDir, "lib.js", "")
+
+	cases := map[string]struct {
+		json     string
+		expected []string
+	}{
+		"valid": {
+			json:     `{"base": "app", "modules": [{"name": "src/main", "exclude": ["src/lib"]}, {"name": "src/lib", "include": ["src/main.js"]}]}`,
+			expected: []string{},
+		},
+		"misspelt-exclude": {
+			json:     "{\"modules\": [\n  {\"name\": \"app/src/main\", \"exlcude\": []}\n]}",
+			expected: []string{`build.json:2:28: unknown field "exlcude" (did you mean "exclude"?)`},
+		},
+		"duplicate-module": {
+			json:     "{\"modules\": [\n  {\"name\": \"app/src/main\"},\n  {\"name\": \"app/src/main\"}\n]}",
+			expected: []string{`build.json:3:12: duplicate module name "app/src/main" (first defined at line 2)`},
+		},
+		"dangling-exclude": {
+			json:     "{\"modules\": [\n  {\"name\": \"app/src/main\", \"exclude\": [\"app/src/lbi\"]}\n]}",
+			expected: []string{`build.json:2:40: exclude "app/src/lbi" doesn't match any module`},
+		},
+		"missing-files": {
+			json: "{\"modules\": [\n  {\"name\": \"app/src/nope\", \"include\": [\"app/src/lib\", \"app/src/gone\"]}\n]}",
+			expected: []string{
+				`build.json:2:12: module "app/src/nope" not found`,
+				`build.json:2:55: include "app/src/gone" not found`,
+			},
+		},
+		"unnamed-module": {
+			json:     "{\"modules\": [{}]}",
+			expected: []string{`build.json:1:14: module has no name`},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual := problemStrings(ValidateBuildDescription("build.json", []byte(tc.json), tempDir))
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+	buildDir := testutil.MakeSubdirectoryTree(tempDir, "build")
+	testutil.WriteTextFile(tempDir, "main.js", "")
+	buildFilepath := testutil.WriteTextFile(buildDir, "app.json", `{"modules": [{"name": "main", "exclude": ["other"]}]}`)
+	swarmConfigFilepath := testutil.WriteTextFile(tempDir, "swarm.json", "{\n  \"root\": \".\",\n  \"builds\": {\n    \"app\": {\"path\": \"build/app\"},\n    \"missing\": {\"path\": \"build/missing\"}\n  }\n}")
+
+	problems, checkedFilepaths := CheckConfig(swarmConfigFilepath, tempDir)
+	assert.Equal(t, []string{swarmConfigFilepath, buildFilepath}, checkedFilepaths)
+	assert.Equal(t, []string{
+		buildFilepath + `:1:43: exclude "other" doesn't match any module`,
+		swarmConfigFilepath + `:5:25: build description file for "missing" not found: ` + filepath.Join(tempDir, "build", "missing.json"),
+	}, problemStrings(problems))
+}
@@ -1,9 +1,9 @@
 package dep
 
 import (
-	"fmt"
 	"path"
 	"strings"
+	"github.com/mrcrowl/swarm/logging"
 	"github.com/mrcrowl/swarm/source"
 	"github.com/mrcrowl/swarm/util"
 )
@@ -13,49 +13,99 @@ func BuildFileSet(
 	workspace *source.Workspace,
 	entryFileRelativePath string,
 	excludedFilesets []*source.FileSet,
-	interpolationValues map[string]string,
+	conditions *source.ImportConditions,
 ) *source.FileSet {
-	imports, links := followDependencyChain(workspace, entryFileRelativePath, excludedFilesets, interpolationValues)
+	imports, links := followDependencyChain(workspace, entryFileRelativePath, excludedFilesets, conditions)
 	fileset := source.NewFileSet(imports, links, workspace)
 
 	return fileset
 }
 
 // UpdateFileset adds dependencies for an entry file to a FileSet
-func UpdateFileset(fileset *source.FileSet, modifiedFileRelativePath string, excludedFilesets []*source.FileSet, interpolationValues map[string]string) {
-	// assume a file has been touched/changed, so:
-	//
-	// 1. invalidate it's content
-
-	didRemoveJSSuffix := false
-	fileID := modifiedFileRelativePath
-	if path.Ext(modifiedFileRelativePath) == ".js" {
-		fileID = util.RemoveExtension(modifiedFileRelativePath)
-		didRemoveJSSuffix = true
+func UpdateFileset(fileset *source.FileSet, modifiedFileRelativePath string, excludedFilesets []*source.FileSet, conditions *source.ImportConditions) {
+	refreshIncluders(fileset, modifiedFileRelativePath)
+
+	// assume a file has been touched/changed, so refresh it
+	if file := findFile(fileset, modifiedFileRelativePath); file != nil {
+		refreshFile(fileset, file, excludedFilesets, conditions)
+		return
 	}
 
-	file := fileset.Get(fileID)
-	if file == nil && didRemoveJSSuffix {
-		// maybe we're importing a .js file into a .ts file
-		fileID = fileID + ".js"
-		file = fileset.Get(fileID)
+	// ...or, if it's new to the FileSet, revisit any files that tried to import it before it existed
+	for _, fileID := range candidateFileIDs(modifiedFileRelativePath) {
+		for _, dependentID := range fileset.UnresolvedDependents(fileID) {
+			if dependent := fileset.Get(dependentID); dependent != nil {
+				refreshFile(fileset, dependent, excludedFilesets, conditions)
+			}
+		}
+	}
+}
+
+// RemoveFromFileset removes a deleted file (and its links) from a FileSet
+func RemoveFromFileset(fileset *source.FileSet, deletedFileRelativePath string) bool {
+	refreshIncluders(fileset, deletedFileRelativePath)
+	if file := findFile(fileset, deletedFileRelativePath); file != nil {
+		return fileset.Remove(file.ID)
 	}
+	return false
+}
 
-	if file != nil {
+// RefreshInterpolatedFiles refreshes every file in a FileSet with conditional imports, e.g. when the interpolation values change
+func RefreshInterpolatedFiles(fileset *source.FileSet, excludedFilesets []*source.FileSet, conditions *source.ImportConditions) {
+	for _, file := range fileset.Files() {
+		if line, err := util.ReadFirstLine(file.Filepath); err == nil && source.ContainsCondition(line) {
+			refreshFile(fileset, file, excludedFilesets, conditions)
+		}
+	}
+}
+
+func refreshFile(fileset *source.FileSet, file *source.File, excludedFilesets []*source.FileSet, conditions *source.ImportConditions) {
+	// 1. invalidate it's content (Files shared through a FileCache are invalidated by its owner, so they're only parsed once)
+	if !fileset.Workspace().CachesFiles() {
 		file.UnloadContents()
+	}
+	fileset.MarkDirty()
+
+	// 2. update the dependencies (but include "fileset" in the exclusions, so we don't follow paths we already know about)
+	imports, links := followDependencyChain(fileset.Workspace(), file.ID, append(excludedFilesets, fileset), conditions)
+	fileset.Ingest(imports, links, true)
+}
+
+// refreshIncluders invalidates the contents of files which inline a changed file (e.g. CSS files which @import it),
+// since it isn't one of their dependencies
+func refreshIncluders(fileset *source.FileSet, changedFileRelativePath string) {
+	for _, file := range fileset.IncludersOf(changedFileRelativePath) {
+		if !fileset.Workspace().CachesFiles() {
+			file.UnloadContents()
+		}
 		fileset.MarkDirty()
+	}
+}
 
-		// 2. update the dependencies (but include "fileset" in the exclusions, so we don't follow paths we already know about)
-		imports, links := followDependencyChain(fileset.Workspace(), fileID, append(excludedFilesets, fileset), interpolationValues)
-		fileset.Ingest(imports, links, true)
+// findFile finds the File in a FileSet for a root-relative filepath
+func findFile(fileset *source.FileSet, relativePath string) *source.File {
+	for _, fileID := range candidateFileIDs(relativePath) {
+		if file := fileset.Get(fileID); file != nil {
+			return file
+		}
 	}
+	return nil
+}
+
+// candidateFileIDs lists the IDs a file might be known by, e.g. a .js file may be imported with or without its suffix
+func candidateFileIDs(relativePath string) []string {
+	if path.Ext(relativePath) == ".js" {
+		// maybe we're importing a .js file into a .ts file
+		return []string{util.RemoveExtension(relativePath), relativePath}
+	}
+	return []string{relativePath}
 }
 
 func followDependencyChain(
 	workspace *source.Workspace,
 	entryFileRelativePath string,
 	excludedFilesets []*source.FileSet, /* may be nil */
-	interpolationValues map[string]string,
+	conditions *source.ImportConditions,
 ) ([]*source.Import, []*source.DependencyLink) {
 	queue := newImportQueue()
 	links := make([]*source.DependencyLink, 0, 2048)
@@ -81,13 +131,12 @@ func followDependencyChain(
 
 		importPath := imp.Path()
 		if file, err = workspace.ReadSourceFile(imp); err != nil {
-			fmt.Println("MISSING: " + importPath)
-			// println("Could not find " + rootRelativeDepPath)
+			logging.Warn("missing-import", logging.Fields{"file": importPath}, "Missing import: %s", importPath)
 			return
 		}
 
 		var dependencyIDs []string
-		for _, dep := range readDependencies(file, interpolationValues) {
+		for _, dep := range readDependencies(file, conditions) {
 			if dep.IsSolo {
 				continue
 			}
@@ -101,10 +150,9 @@ func followDependencyChain(
 			dependencyIDs = append(dependencyIDs, depRootRelative.Path())
 		}
 
-		if len(dependencyIDs) > 0 {
-			link := source.NewDependencyLink(importPath, dependencyIDs)
-			links = append(links, link)
-		}
+		// always link, even with no dependencies, so that imports which were removed are forgotten
+		link := source.NewDependencyLink(importPath, dependencyIDs)
+		links = append(links, link)
 	}
 
 	for queue.nonEmpty() {
@@ -116,7 +164,7 @@ func followDependencyChain(
 	return queue.outputImports(), links
 }
 
-func readDependencies(file *source.File, interpValues map[string]string) []*source.Import {
+func readDependencies(file *source.File, conditions *source.ImportConditions) []*source.Import {
 	var line string
 	var err error
 	if line, err = util.ReadFirstLine(file.Filepath); err != nil {
@@ -127,8 +175,12 @@ func readDependencies(file *source.File, interpValues map[string]string) []*sour
 	if dependencies, ok := source.ParseRegisterDependencies(line, true); ok {
 		filteredDeps = make([]*source.Import, 0, len(dependencies))
 		for _, dependencyImportPath := range dependencies {
-			dependencyImport := source.NewImportWithInterpolation(dependencyImportPath, interpValues)
-			filteredDeps = append(filteredDeps, dependencyImport)
+			dependencyImports, err := source.NewImportsWithConditions(dependencyImportPath, conditions)
+			if err != nil {
+				logging.Error("invalid-import", logging.Fields{"file": file.ID, "error": err}, "%s (in %s)", err, file.ID)
+				continue
+			}
+			filteredDeps = append(filteredDeps, dependencyImports...)
 		}
 	}
 
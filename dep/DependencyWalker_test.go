@@ -1,6 +1,8 @@
 package dep
 
 import (
+	"strings"
+
 	"github.com/mrcrowl/swarm/source"
 	"github.com/mrcrowl/swarm/testutil"
 	"testing"
@@ -10,7 +12,7 @@ import (
 
 func TestFollowDependencyGraph(t *testing.T) {
 	ws := source.NewWorkspace("C:\\WF\\LP\\web\\App")
-	followDependencyChain(ws, "app\\src\\ep\\App.js", nil, map[string]string{})
+	followDependencyChain(ws, "app\\src\\ep\\App.js", nil, nil)
 }
 
 const jsFileWithCommentsBeforeSystemRegister = `// the dependency above is required by evaluateVariables() method
@@ -27,6 +29,94 @@ func TestReadDependencies(t *testing.T) {
 	imp := source.NewImport("./VariableEvaluator.js")
 	file, err := ws.ReadSourceFile(imp)
 	assert.Nil(t, err)
-	dependencies := readDependencies(file, map[string]string{})
+	dependencies := readDependencies(file, nil)
 	assert.Len(t, dependencies, 3)
 }
+
+func writeSystemJSFile(folderPath string, filename string, dependencies ...string) string {
+	quoted := make([]string, len(dependencies))
+	for i, dep := range dependencies {
+		quoted[i] = "\"" + dep + "\""
+	}
+	registerLine := "System.register([" + strings.Join(quoted, ", ") + "], function (exports_1, context_1) {"
+	return testutil.WriteTextFile(folderPath, filename, registerLine+"\n});")
+}
+
+func TestRemoveFromFileset(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	writeSystemJSFile(temppath, "entry.js", "./abcd", "./efgh.css")
+	writeSystemJSFile(temppath, "abcd.js", "./ijkl")
+	writeSystemJSFile(temppath, "ijkl.js")
+	testutil.WriteTextFile(temppath, "efgh.css", "body {}")
+
+	ws := source.NewWorkspace(temppath)
+	fileset := BuildFileSet(ws, "entry", nil, nil)
+	assert.Equal(t, 4, fileset.Count())
+
+	assert.True(t, RemoveFromFileset(fileset, "abcd.js"))
+	assert.True(t, RemoveFromFileset(fileset, "efgh.css"))
+	assert.False(t, RemoveFromFileset(fileset, "nothing.js"))
+	fileset.RemoveUnreachable([]string{"entry"})
+	assert.Equal(t, 1, fileset.Count())
+}
+
+func TestUpdateFilesetPicksUpCreatedFile(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	writeSystemJSFile(temppath, "entry.js", "./abcd")
+
+	ws := source.NewWorkspace(temppath)
+	fileset := BuildFileSet(ws, "entry", nil, nil)
+	assert.Equal(t, 1, fileset.Count())
+
+	writeSystemJSFile(temppath, "abcd.js", "./efgh")
+	writeSystemJSFile(temppath, "efgh.js")
+	fileset.ClearDirty()
+	UpdateFileset(fileset, "abcd.js", nil, nil)
+	assert.True(t, fileset.Dirty())
+	assert.True(t, fileset.Contains("abcd"))
+	assert.True(t, fileset.Contains("efgh"))
+}
+
+func TestUpdateFilesetForgetsRemovedImports(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	writeSystemJSFile(temppath, "entry.js", "./abcd")
+	writeSystemJSFile(temppath, "abcd.js", "./efgh", "./ijkl")
+	writeSystemJSFile(temppath, "efgh.js", "./ijkl")
+	writeSystemJSFile(temppath, "ijkl.js")
+
+	ws := source.NewWorkspace(temppath)
+	fileset := BuildFileSet(ws, "entry", nil, nil)
+	assert.Equal(t, 4, fileset.Count())
+
+	writeSystemJSFile(temppath, "abcd.js")
+	UpdateFileset(fileset, "abcd.js", nil, nil)
+	evicted := fileset.RemoveUnreachable([]string{"entry"})
+	assert.ElementsMatch(t, []string{"efgh", "ijkl"}, evicted)
+	assert.Equal(t, 2, fileset.Count())
+}
+
+func TestBuildFileSetWithAllVariants(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	writeSystemJSFile(temppath, "entry.js", "./page#{Config|SUFFIX}", "./debug#?Config|DEBUG")
+	writeSystemJSFile(temppath, "page.mobile.js")
+	writeSystemJSFile(temppath, "page.desktop.js")
+	writeSystemJSFile(temppath, "debug.js")
+
+	ws := source.NewWorkspace(temppath)
+	values := map[string]string{"Config.SUFFIX": ".mobile", "Config.DEBUG": "false"}
+
+	fileset := BuildFileSet(ws, "entry", nil, source.NewImportConditions(values, nil))
+	assert.True(t, fileset.Contains("page.mobile"))
+	assert.False(t, fileset.Contains("page.desktop"))
+	assert.False(t, fileset.Contains("debug"))
+
+	variants := map[string][]string{"Config.SUFFIX": {".mobile", ".desktop"}}
+	fileset = BuildFileSet(ws, "entry", nil, source.NewImportConditions(values, variants))
+	assert.True(t, fileset.Contains("page.mobile"))
+	assert.True(t, fileset.Contains("page.desktop"))
+	assert.True(t, fileset.Contains("debug"))
+}
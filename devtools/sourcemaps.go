@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/util"
 )
 
 // PlayMappings loops through the mappings to calculate a "delta" that occurs
@@ -143,35 +144,6 @@ func parseLineString(lineString string) *line {
 	return &line{segments}
 }
 
-const base64Map = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
-
-func byteToInt(b byte) int {
-	switch {
-	case b >= 'A' && b <= 'Z':
-		return int(b - 'A')
-	case b >= 'a' && b <= 'z':
-		return int(b - 'a' + 26)
-	case b >= '0' && b <= '9':
-		return int(b - '0' + 52)
-	case b == '+':
-		return 62
-	case b == '/':
-		return 63
-	case b == '=':
-		return 64
-	default:
-		panic(fmt.Sprintf("byteToInt received byte out of range: %c", b))
-	}
-}
-
-func intToByte(i int) byte {
-	if i >= 0 && i <= 64 {
-		return base64Map[i]
-	}
-
-	panic(fmt.Sprintf("intToByte received int out of range: %d", i))
-}
-
 // decode decodes a base-64 VLQ string to a strongly-typed segment
 func decodeSegment(s string) source.Segment {
 	values := decode(s)
@@ -188,37 +160,11 @@ func decodeSegment(s string) source.Segment {
 
 // decode decodes a base-64 VLQ string to a list of integers
 func decode(s string) []int {
-	result := make([]int, 0, 4)
-	shift := uint(0)
-	value := 0
-
-	for _, b := range s {
-		integer := byteToInt(byte(b))
-
-		hasContinuationBit := (integer & 32) > 0
-
-		integer &= 31
-		value += integer << shift
-
-		if hasContinuationBit {
-			shift += 5
-		} else {
-			shouldNegate := (value & 1) > 0
-			value >>= 1
-
-			if shouldNegate {
-				result = append(result, -value)
-			} else {
-				result = append(result, value)
-			}
-
-			// reset
-			value = 0
-			shift = 0
-		}
+	values, err := util.DecodeVLQ(s)
+	if err != nil {
+		panic(err.Error())
 	}
-
-	return result
+	return values
 }
 
 // encode encodes a list of numbers to a VLQ string
@@ -230,36 +176,5 @@ func encodeSegment(seg source.Segment) string {
 
 // encode encodes a list of numbers to a VLQ string
 func encode(values []int) string {
-	result := make([]byte, 0, 8)
-	for _, n := range values {
-		result = append(result, encodeInteger(n)...)
-	}
-	return string(result)
-}
-
-func encodeInteger(n int) []byte {
-	result := make([]byte, 0, 8)
-
-	if n < 0 {
-		n = (-n << 1) | 1
-	} else {
-		n <<= 1
-	}
-
-	for {
-		clamped := n & 31
-		n >>= 5
-
-		if n > 0 {
-			clamped |= 32
-		}
-
-		result = append(result, intToByte(clamped))
-
-		if n <= 0 {
-			break
-		}
-	}
-
-	return result
+	return util.EncodeVLQ(values)
 }
@@ -0,0 +1,125 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Types of build lifecycle events
+const (
+	// BuildStart is published when a rebuild begins, with the changed files
+	BuildStart = "build-start"
+	// ModuleBundled is published each time a module is (re)bundled, with how long it took
+	ModuleBundled = "module-bundled"
+	// BuildEnd is published when a rebuild finishes, with the modules that were bundled and any errors
+	BuildEnd = "build-end"
+)
+
+// Event is a build lifecycle event, for editor and tool integrations
+type Event struct {
+	Type           string    `json:"type"`
+	Time           time.Time `json:"time"`
+	Build          int       `json:"build"`             // numbers each rebuild (from 1), and is shared by all of its events
+	Files          []string  `json:"files,omitempty"`   // the changed files, relative to the workspace root
+	Modules        []string  `json:"modules,omitempty"` // the modules that were bundled
+	Bundle         string    `json:"bundle,omitempty"`  // the URL path of a bundle, e.g. /app/src/main.js
+	FileCount      int       `json:"fileCount,omitempty"`
+	Size           int       `json:"size,omitempty"` // bytes of bundled javascript
+	DurationMs     float64   `json:"durationMs"`
+	Errors         []string  `json:"errors,omitempty"`
+	Warnings       []string  `json:"warnings,omitempty"`
+	MissingImports []string  `json:"missingImports,omitempty"`
+}
+
+// Duration converts a time.Duration to the milliseconds of an event
+func Duration(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// subscriptionBuffer is how many events a slow subscriber can fall behind by, before events are dropped
+const subscriptionBuffer = 64
+
+// Bus delivers events to every subscriber
+type Bus struct {
+	mutex         sync.Mutex
+	subscriptions map[*Subscription]bool
+	build         int
+	modules       []string // bundled since the current build started
+	now           func() time.Time
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subscriptions: map[*Subscription]bool{}, now: time.Now}
+}
+
+// Subscription receives the events published to a bus, until it's closed
+type Subscription struct {
+	C   <-chan *Event
+	c   chan *Event
+	bus *Bus
+}
+
+// Subscribe creates a subscription to every event published from now on
+func (bus *Bus) Subscribe() *Subscription {
+	c := make(chan *Event, subscriptionBuffer)
+	sub := &Subscription{C: c, c: c, bus: bus}
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.subscriptions[sub] = true
+	return sub
+}
+
+// Close stops the subscription's events
+func (sub *Subscription) Close() {
+	sub.bus.mutex.Lock()
+	defer sub.bus.mutex.Unlock()
+	if sub.bus.subscriptions[sub] {
+		delete(sub.bus.subscriptions, sub)
+		close(sub.c)
+	}
+}
+
+// Publish stamps an event with the time and build number, then delivers it to every subscriber without waiting.
+// A BuildStart event begins a new build, and a BuildEnd event lists the modules bundled since, if it has none
+func (bus *Bus) Publish(event *Event) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	switch event.Type {
+	case BuildStart:
+		bus.build++
+		bus.modules = nil
+	case ModuleBundled:
+		bus.modules = append(bus.modules, event.Modules...)
+	case BuildEnd:
+		if event.Modules == nil {
+			event.Modules = bus.modules
+		}
+		bus.modules = nil
+	}
+	event.Build = bus.build
+	if event.Time.IsZero() {
+		event.Time = bus.now()
+	}
+
+	for sub := range bus.subscriptions {
+		select {
+		case sub.c <- event:
+		default: // the subscriber has fallen behind
+		}
+	}
+}
+
+var std = NewBus()
+
+// Default gets the bus used by the package-level functions
+func Default() *Bus {
+	return std
+}
+
+// Publish publishes an event to the default bus
+func Publish(event *Event) {
+	std.Publish(event)
+}
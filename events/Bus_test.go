@@ -0,0 +1,58 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBus() *Bus {
+	bus := NewBus()
+	bus.now = func() time.Time { return time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC) }
+	return bus
+}
+
+func TestPublish(t *testing.T) {
+	bus := newTestBus()
+	sub := bus.Subscribe()
+	defer sub.Close()
+
+	bus.Publish(&Event{Type: BuildStart, Files: []string{"app/src/main.ts"}})
+	bus.Publish(&Event{Type: ModuleBundled, Modules: []string{"app/src/main"}, DurationMs: 12})
+	bus.Publish(&Event{Type: ModuleBundled, Modules: []string{"app/src/lib"}, DurationMs: 3})
+	bus.Publish(&Event{Type: BuildEnd, Errors: []string{"Missing import: app/x"}})
+	bus.Publish(&Event{Type: BuildStart})
+
+	var received []*Event
+	for i := 0; i < 5; i++ {
+		received = append(received, <-sub.C)
+	}
+	assert.Equal(t, []int{1, 1, 1, 1, 2}, []int{received[0].Build, received[1].Build, received[2].Build, received[3].Build, received[4].Build})
+	assert.Equal(t, []string{"app/src/main", "app/src/lib"}, received[3].Modules)
+	assert.Equal(t, []string{"Missing import: app/x"}, received[3].Errors)
+	assert.Equal(t, time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC), received[0].Time)
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	bus := newTestBus()
+	sub := bus.Subscribe()
+	sub.Close()
+	sub.Close()
+
+	bus.Publish(&Event{Type: BuildStart})
+	_, ok := <-sub.C
+	assert.False(t, ok)
+}
+
+func TestSlowSubscriberDropsEvents(t *testing.T) {
+	bus := newTestBus()
+	sub := bus.Subscribe()
+	defer sub.Close()
+
+	for i := 0; i < subscriptionBuffer+10; i++ {
+		bus.Publish(&Event{Type: BuildStart})
+	}
+	assert.Equal(t, subscriptionBuffer, len(sub.C))
+	assert.Equal(t, 1, (<-sub.C).Build)
+}
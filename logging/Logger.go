@@ -0,0 +1,326 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+const (
+	// LevelDebug is for detail that's only useful when diagnosing swarm itself, e.g. each changed file
+	LevelDebug Level = iota
+	// LevelInfo is for progress, e.g. each bundled module
+	LevelInfo
+	// LevelWarn is for problems that swarm can work around
+	LevelWarn
+	// LevelSummary is for the outcome of each rebuild, which is still shown in quiet mode
+	LevelSummary
+	// LevelError is for failures
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "summary", "error"}
+
+func (level Level) String() string {
+	if level < LevelDebug || level > LevelError {
+		return fmt.Sprintf("level(%d)", int(level))
+	}
+	return levelNames[level]
+}
+
+// ParseLevel gets the level with a name, e.g. "warn"
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("Invalid log level '%s' (expected one of: %s)", name, strings.Join(levelNames, ", "))
+}
+
+// Format is how log entries are written
+type Format int
+
+const (
+	// FormatText writes each entry's message on a line, prefixed with ERROR: or WARNING: where needed
+	FormatText Format = iota
+	// FormatJSON writes each entry as a JSON object on a line, with its event, message and fields
+	FormatJSON
+)
+
+// ParseFormat gets the format with a name, i.e. "text" or "json"
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return FormatText, errors.New("Invalid log format '" + name + "' (expected text or json)")
+}
+
+// Fields are the structured data of a log entry, e.g. module, file, duration.  time.Duration values are written
+// as milliseconds, and errors as their message
+type Fields map[string]interface{}
+
+// reservedKeys are written for every entry in JSON format, so fields can't use them
+var reservedKeys = map[string]bool{"time": true, "level": true, "event": true, "msg": true}
+
+// Logger writes log entries at or above a level
+type Logger struct {
+	mutex     sync.Mutex
+	out       io.Writer
+	level     Level
+	format    Format
+	now       func() time.Time
+	recorders map[*recorder]bool
+}
+
+// Entry is a log entry, as recorded by Record
+type Entry struct {
+	Level   Level
+	Event   string
+	Message string
+	Fields  Fields
+}
+
+type recorder struct {
+	level   Level
+	entries []*Entry
+}
+
+// NewLogger creates a logger that writes to out
+func NewLogger(out io.Writer, level Level, format Format) *Logger {
+	return &Logger{out: out, level: level, format: format, now: time.Now, recorders: map[*recorder]bool{}}
+}
+
+// Configure changes the level and format of the logger
+func (logger *Logger) Configure(level Level, format Format) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	logger.level = level
+	logger.format = format
+}
+
+// SetOutput changes where entries are written, e.g. so that a full-screen UI can show them itself
+func (logger *Logger) SetOutput(out io.Writer) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	logger.out = out
+}
+
+// Enabled tests whether entries at a level will be written
+func (logger *Logger) Enabled(level Level) bool {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	return level >= logger.level
+}
+
+// IsJSON tests whether entries are written as JSON
+func (logger *Logger) IsJSON() bool {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	return logger.format == FormatJSON
+}
+
+// Log writes an entry, if its level is enabled.  The event names what happened (e.g. "bundled"), for tooling
+func (logger *Logger) Log(level Level, event string, fields Fields, format string, args ...interface{}) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	if level < logger.level && !logger.isRecording(level) {
+		return
+	}
+
+	message := fmt.Sprintf(format, args...)
+	for rec := range logger.recorders {
+		if level >= rec.level {
+			rec.entries = append(rec.entries, &Entry{level, event, message, fields})
+		}
+	}
+	if level < logger.level {
+		return
+	}
+
+	if logger.format == FormatJSON {
+		io.WriteString(logger.out, formatJSONEntry(logger.now(), level, event, message, fields))
+	} else {
+		io.WriteString(logger.out, formatTextEntry(level, message))
+	}
+}
+
+// Record starts collecting the entries at or above a level, whether or not they're written, e.g. the errors
+// during a rebuild.  The returned function stops recording, and gets the entries
+func (logger *Logger) Record(level Level) func() []*Entry {
+	rec := &recorder{level: level}
+	logger.mutex.Lock()
+	logger.recorders[rec] = true
+	logger.mutex.Unlock()
+
+	return func() []*Entry {
+		logger.mutex.Lock()
+		defer logger.mutex.Unlock()
+		delete(logger.recorders, rec)
+		return rec.entries
+	}
+}
+
+func (logger *Logger) isRecording(level Level) bool {
+	for rec := range logger.recorders {
+		if level >= rec.level {
+			return true
+		}
+	}
+	return false
+}
+
+// Debug writes an entry at LevelDebug
+func (logger *Logger) Debug(event string, fields Fields, format string, args ...interface{}) {
+	logger.Log(LevelDebug, event, fields, format, args...)
+}
+
+// Info writes an entry at LevelInfo
+func (logger *Logger) Info(event string, fields Fields, format string, args ...interface{}) {
+	logger.Log(LevelInfo, event, fields, format, args...)
+}
+
+// Warn writes an entry at LevelWarn
+func (logger *Logger) Warn(event string, fields Fields, format string, args ...interface{}) {
+	logger.Log(LevelWarn, event, fields, format, args...)
+}
+
+// Summary writes an entry at LevelSummary
+func (logger *Logger) Summary(event string, fields Fields, format string, args ...interface{}) {
+	logger.Log(LevelSummary, event, fields, format, args...)
+}
+
+// Error writes an entry at LevelError
+func (logger *Logger) Error(event string, fields Fields, format string, args ...interface{}) {
+	logger.Log(LevelError, event, fields, format, args...)
+}
+
+// Writer adapts the logger to an io.Writer, writing each line as an entry, e.g. for the standard library's log
+func (logger *Logger) Writer(level Level, event string) io.Writer {
+	return &lineWriter{logger, level, event}
+}
+
+type lineWriter struct {
+	logger *Logger
+	level  Level
+	event  string
+}
+
+func (writer *lineWriter) Write(data []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		writer.logger.Log(writer.level, writer.event, nil, "%s", line)
+	}
+	return len(data), nil
+}
+
+func formatTextEntry(level Level, message string) string {
+	switch level {
+	case LevelError:
+		return "ERROR: " + message + "\n"
+	case LevelWarn:
+		return "WARNING: " + message + "\n"
+	}
+	return message + "\n"
+}
+
+func formatJSONEntry(now time.Time, level Level, event string, message string, fields Fields) string {
+	var sb strings.Builder
+	sb.WriteString(`{"time":`)
+	sb.Write(marshalValue(now.Format(time.RFC3339Nano)))
+	sb.WriteString(`,"level":`)
+	sb.Write(marshalValue(level.String()))
+	sb.WriteString(`,"event":`)
+	sb.Write(marshalValue(event))
+	sb.WriteString(`,"msg":`)
+	sb.Write(marshalValue(message))
+
+	var keys []string
+	for key := range fields {
+		if !reservedKeys[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		sb.WriteString(",")
+		sb.Write(marshalValue(key))
+		sb.WriteString(":")
+		sb.Write(marshalValue(fields[key]))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func marshalValue(value interface{}) []byte {
+	switch typedValue := value.(type) {
+	case time.Duration:
+		value = float64(typedValue) / float64(time.Millisecond)
+	case error:
+		value = typedValue.Error()
+	}
+
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		bytes, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return bytes
+}
+
+var std = NewLogger(os.Stdout, LevelInfo, FormatText)
+
+// Default gets the logger used by the package-level functions
+func Default() *Logger {
+	return std
+}
+
+// Configure changes the level and format of the default logger
+func Configure(level Level, format Format) {
+	std.Configure(level, format)
+}
+
+// SetOutput changes where the default logger writes entries
+func SetOutput(out io.Writer) {
+	std.SetOutput(out)
+}
+
+// IsJSON tests whether the default logger writes entries as JSON
+func IsJSON() bool {
+	return std.IsJSON()
+}
+
+// Debug writes an entry to the default logger at LevelDebug
+func Debug(event string, fields Fields, format string, args ...interface{}) {
+	std.Log(LevelDebug, event, fields, format, args...)
+}
+
+// Info writes an entry to the default logger at LevelInfo
+func Info(event string, fields Fields, format string, args ...interface{}) {
+	std.Log(LevelInfo, event, fields, format, args...)
+}
+
+// Warn writes an entry to the default logger at LevelWarn
+func Warn(event string, fields Fields, format string, args ...interface{}) {
+	std.Log(LevelWarn, event, fields, format, args...)
+}
+
+// Summary writes an entry to the default logger at LevelSummary
+func Summary(event string, fields Fields, format string, args ...interface{}) {
+	std.Log(LevelSummary, event, fields, format, args...)
+}
+
+// Error writes an entry to the default logger at LevelError
+func Error(event string, fields Fields, format string, args ...interface{}) {
+	std.Log(LevelError, event, fields, format, args...)
+}
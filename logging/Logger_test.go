@@ -0,0 +1,125 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogger(level Level, format Format) (*Logger, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	logger := NewLogger(out, level, format)
+	logger.now = func() time.Time { return time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC) }
+	return logger, out
+}
+
+func TestTextFormat(t *testing.T) {
+	logger, out := newTestLogger(LevelDebug, FormatText)
+	logger.Debug("change", Fields{"file": "a.js"}, "...and %s", "a.js")
+	logger.Info("bundled", nil, "   Bundled: %s", "/app/main.js")
+	logger.Warn("missing-import", nil, "Missing import: %s", "app/x")
+	logger.Summary("rebuild", nil, "...done in %s", time.Second)
+	logger.Error("proxy", nil, "Failed to proxy")
+
+	expected := "...and a.js\n" +
+		"   Bundled: /app/main.js\n" +
+		"WARNING: Missing import: app/x\n" +
+		"...done in 1s\n" +
+		"ERROR: Failed to proxy\n"
+	assert.Equal(t, expected, out.String())
+}
+
+func TestJSONFormat(t *testing.T) {
+	logger, out := newTestLogger(LevelInfo, FormatJSON)
+	logger.Info("bundled", Fields{"module": "app/main", "files": 12, "duration": 1500 * time.Microsecond, "msg": "ignored"}, "Bundled: %s", "/app/main.js")
+	logger.Error("proxy", Fields{"error": errors.New("connection refused")}, "Failed to proxy")
+
+	expected := `{"time":"2018-03-04T05:06:07Z","level":"info","event":"bundled","msg":"Bundled: /app/main.js","duration":1.5,"files":12,"module":"app/main"}` + "\n" +
+		`{"time":"2018-03-04T05:06:07Z","level":"error","event":"proxy","msg":"Failed to proxy","error":"connection refused"}` + "\n"
+	assert.Equal(t, expected, out.String())
+}
+
+func TestLevels(t *testing.T) {
+	cases := map[string]struct {
+		level    Level
+		expected string
+	}{
+		"debug":   {LevelDebug, "d\ni\nWARNING: w\ns\nERROR: e\n"},
+		"info":    {LevelInfo, "i\nWARNING: w\ns\nERROR: e\n"},
+		"warn":    {LevelWarn, "WARNING: w\ns\nERROR: e\n"},
+		"summary": {LevelSummary, "s\nERROR: e\n"},
+		"error":   {LevelError, "ERROR: e\n"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			logger, out := newTestLogger(tc.level, FormatText)
+			logger.Debug("", nil, "d")
+			logger.Info("", nil, "i")
+			logger.Warn("", nil, "w")
+			logger.Summary("", nil, "s")
+			logger.Error("", nil, "e")
+			assert.Equal(t, tc.expected, out.String())
+			assert.Equal(t, tc.level == LevelDebug, logger.Enabled(LevelDebug))
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("WARN")
+	assert.Nil(t, err)
+	assert.Equal(t, LevelWarn, level)
+	assert.Equal(t, "warn", level.String())
+
+	_, err = ParseLevel("loud")
+	assert.EqualError(t, err, "Invalid log level 'loud' (expected one of: debug, info, warn, summary, error)")
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("json")
+	assert.Nil(t, err)
+	assert.Equal(t, FormatJSON, format)
+
+	_, err = ParseFormat("xml")
+	assert.NotNil(t, err)
+}
+
+func TestWriter(t *testing.T) {
+	logger, out := newTestLogger(LevelInfo, FormatJSON)
+	writer := logger.Writer(LevelWarn, "log")
+	writer.Write([]byte("first\nsecond\n"))
+
+	expected := `{"time":"2018-03-04T05:06:07Z","level":"warn","event":"log","msg":"first"}` + "\n" +
+		`{"time":"2018-03-04T05:06:07Z","level":"warn","event":"log","msg":"second"}` + "\n"
+	assert.Equal(t, expected, out.String())
+}
+
+func TestSetOutput(t *testing.T) {
+	logger, out := newTestLogger(LevelInfo, FormatText)
+	logger.Info("", nil, "before")
+	captured := &bytes.Buffer{}
+	logger.SetOutput(captured)
+	logger.Info("", nil, "after")
+
+	assert.Equal(t, "before\n", out.String())
+	assert.Equal(t, "after\n", captured.String())
+}
+
+func TestRecord(t *testing.T) {
+	logger, out := newTestLogger(LevelError, FormatText)
+	logger.Warn("before", nil, "not recorded")
+	stop := logger.Record(LevelWarn)
+	logger.Info("ignored", nil, "too minor")
+	logger.Warn("missing-import", Fields{"file": "app/x"}, "Missing import: %s", "app/x")
+	logger.Error("proxy", nil, "Failed to proxy")
+	entries := stop()
+	logger.Error("after", nil, "not recorded")
+
+	assert.Equal(t, []*Entry{
+		{LevelWarn, "missing-import", "Missing import: app/x", Fields{"file": "app/x"}},
+		{LevelError, "proxy", "Failed to proxy", nil},
+	}, entries)
+	assert.Equal(t, "ERROR: Failed to proxy\nERROR: not recorded\n", out.String())
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
-	"fmt"
+	"log"
 	"os"
+	"time"
 
 	"github.com/mrcrowl/swarm/bundle"
 	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/logging"
 	"github.com/mrcrowl/swarm/monitor"
 	"github.com/mrcrowl/swarm/source"
 	"github.com/mrcrowl/swarm/ui"
@@ -18,52 +20,163 @@ import (
 
 const localver = "1.0.11"
 
-var portFlag = flag.Uint16P("port", "p", uint16(8096), "Web server port number")
+// settings flags override swarm.json (and SWARM_* environment variables), but only when given explicitly
+func init() {
+	flag.StringP("config", "c", "", "Path of the swarm.json file (default: search this directory and its parents)")
+	flag.StringP("root", "r", "", "Root path of the workspace")
+	flag.StringP("build", "b", "", "Comma-separated builds to run (instead of choosing from a menu)")
+	flag.String("base-href", "", "Base href for every build")
+	flag.Uint16P("port", "p", uint16(8096), "Web server port number")
+	flag.Bool("https", false, "Serves over HTTPS (with a generated certificate, unless certFile and keyFile are configured)")
+	flag.Bool("open", true, "Opens a browser once started")
+	flag.Bool("hot-reload", true, "Reloads the browser when files change")
+	flag.Uint("debounce", 150, "Milliseconds to wait for further changes before rebuilding")
+	flag.String("watcher", "auto", "How to watch for changes: auto, notify or poll")
+	flag.Uint("poll-interval", 1000, "Milliseconds between scans, when polling for changes")
+	flag.String("extensions", "", "Comma-separated file extensions to watch")
+}
+
 var helpFlag = flag.BoolP("help", "h", false, "Shows the usage")
+var logFormatFlag = flag.String("log-format", "text", "How to write the log: text, or json (one object per line)")
+var logLevelFlag = flag.String("log-level", "info", "The least severe log entries to show: debug, info, warn, summary or error")
+var quietFlag = flag.BoolP("quiet", "q", false, "Only shows errors and rebuild summaries (same as --log-level=summary)")
+var dashboardFlag = flag.BoolP("dashboard", "d", false, "Shows a full-screen dashboard instead of the scrolling log")
+
+// explicitFlags gets the values of the settings flags that were given on the command line
+func explicitFlags() map[string]string {
+	flags := map[string]string{}
+	flag.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+	return flags
+}
+
+// configureLogging applies the logging flags
+func configureLogging() error {
+	format, err := logging.ParseFormat(*logFormatFlag)
+	if err != nil {
+		return err
+	}
+	level, err := logging.ParseLevel(*logLevelFlag)
+	if err != nil {
+		return err
+	}
+	if *quietFlag && level < logging.LevelSummary {
+		level = logging.LevelSummary
+	}
+
+	logging.Configure(level, format)
+	log.SetFlags(0)
+	log.SetOutput(logging.Default().Writer(logging.LevelInfo, "log"))
+	return nil
+}
 
 func main() {
-	ui.PrintTitle(localver)
 	ui.CheckHelp(helpFlag)
+	err := configureLogging()
+	util.ExitIfError(err, "%s", err)
+	if !logging.IsJSON() && !*quietFlag && !*dashboardFlag {
+		ui.PrintTitle(localver)
+	}
+	overrides := config.NewOverrides(os.Environ(), explicitFlags())
+	ui.RunCommand(flag.Args(), overrides)
 
 	if didUpdate, _ := version.AutoUpdate(localver); didUpdate {
-		fmt.Println("updated. Please restart!")
+		logging.Summary("updated", nil, "updated. Please restart!")
 		os.Exit(0)
 	}
 
 	// configuration
-	swarmConfig, err := config.TryLoadSwarmConfigFromCWD(portFlag)
+	swarmConfig, err := config.DiscoverSwarmConfig(overrides)
 	util.ExitIfError(err, "Failed to load swarm.json file: %s", err)
-	runtimeConfig := ui.ChooseBuild(swarmConfig.Builds, flag.Args())
-	moduleDescrs, err := config.LoadBuildDescriptionFile(runtimeConfig.BuildPath)
-	util.ExitIfError(err, "Failed to load build description file: '%s'", runtimeConfig.BuildPath)
+	ui.PrintConfigSummary(swarmConfig)
+	buildNames := flag.Args()
+	if len(buildNames) == 0 {
+		buildNames = swarmConfig.Build
+	}
+	var builds map[string]*config.RuntimeConfig
+	chosen := true
+	if *dashboardFlag {
+		builds, chosen = ui.ChooseBuildsLater(swarmConfig.Builds, buildNames) // the dashboard has its own build picker
+	} else {
+		builds = ui.ChooseBuilds(swarmConfig.Builds, buildNames)
+	}
 
 	// workspace
 	ws := source.NewWorkspace(swarmConfig.RootPath)
-	normalisedModules := moduleDescrs.NormaliseModules(ws.RootPath())
-	moduleSet := bundle.CreateModuleSet(ws, normalisedModules, runtimeConfig)
+	buildSet, err := bundle.CreateBuildSet(ws, builds)
+	util.ExitIfError(err, "Failed to create builds: %s", err)
 
 	// web server
-	handlers := moduleSet.GenerateHTTPHandlers()
-	serverOptions := web.CreateServerOptions(swarmConfig.RootPath, swarmConfig.Server, handlers, runtimeConfig.BaseHref)
+	handlers := buildSet.GenerateHTTPHandlers()
+	serverOptions := web.CreateServerOptions(swarmConfig.RootPath, swarmConfig.Server, handlers, buildSet.BaseHrefs()...)
+	err = serverOptions.ResolveCertificate()
+	util.ExitIfError(err, "Failed to create a certificate for HTTPS: %s", err)
 	server := web.CreateServer(serverOptions)
-	hotReloader := web.NewHotReloader(server, ws, moduleSet)
+	hotReloader := web.NewHotReloader(server, ws, buildSet)
+	configReloader := web.NewConfigReloader(server, ws, buildSet, swarmConfig)
 
 	// monitor
-	mon := monitor.NewMonitor(ws, swarmConfig.Monitor)
-	mon.RegisterCallback(moduleSet.NotifyChanges)
+	mon, err := monitor.NewMonitor(ws, swarmConfig.Monitor)
+	util.ExitIfError(err, "Failed to watch workspace: %s", err)
+	mon.RegisterCallback(buildSet.NotifyChanges)
 	mon.RegisterCallback(hotReloader.NotifyReload)
-	fmt.Print("Performing initial build...")
+	var dashboard *ui.Dashboard
+	if *dashboardFlag {
+		dashboard = createDashboard(swarmConfig, buildSet, server, mon, configReloader, !chosen)
+	}
+	logging.Info("initial-build", nil, "Performing initial build...")
+	start := time.Now()
 	mon.TriggerManually()
+	logging.Summary("initial-build", logging.Fields{"duration": time.Since(start)}, "...done in %s", time.Since(start))
 
 	go server.Start()
 	go mon.NotifyOnChanges()
-	fmt.Printf("Listening on %s\n", server.URL())
+	go configReloader.Watch()
+	for _, url := range server.URLs() {
+		logging.Info("listening", logging.Fields{"url": url}, "Listening on %s", url)
+	}
 	if swarmConfig.Server.Open {
 		util.OpenBrowser(server.URL())
 	}
 
 	// sleep
-	util.WaitForCtrlC()
+	if dashboard == nil {
+		util.WaitForCtrlC()
+	} else if err := dashboard.Run(); err != nil {
+		logging.Error("dashboard", logging.Fields{"error": err}, "Failed to show the dashboard: %s", err)
+		util.WaitForCtrlC()
+	}
+	configReloader.Stop()
 	server.Stop()
 	mon.Stop()
 }
+
+// createDashboard creates the full-screen dashboard, with shortcuts to rebuild, switch builds and open the browser
+func createDashboard(swarmConfig *config.SwarmConfig, buildSet *bundle.BuildSet, server *web.Server, mon *monitor.Monitor, configReloader *web.ConfigReloader, chooseBuilds bool) *ui.Dashboard {
+	return ui.NewDashboard(ui.DashboardOptions{
+		Version:      localver,
+		URL:          server.URL(),
+		Builds:       ui.BuildNames(swarmConfig.Builds),
+		Running:      buildSet.Names(),
+		ChooseBuilds: chooseBuilds,
+		Clients:      server.ClientCount,
+		Actions: ui.DashboardActions{
+			Rebuild: func() {
+				mon.TriggerAfter(buildSet.Rebuild)
+			},
+			SwitchBuilds: func(names []string) {
+				mon.TriggerAfter(func() {
+					if err := configReloader.SwitchBuilds(names); err != nil {
+						logging.Error("switch-builds", logging.Fields{"error": err}, "Failed to switch builds: %s", err)
+						return
+					}
+					buildSet.Rebuild() // so that the dashboard hears about every module of the new builds
+				})
+			},
+			OpenBrowser: func() {
+				util.OpenBrowser(server.URL())
+			},
+		},
+	})
+}
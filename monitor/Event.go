@@ -1,6 +1,10 @@
 package monitor
 
-import "github.com/rjeczalik/notify"
+import (
+	"os"
+
+	"github.com/rjeczalik/notify"
+)
 
 // Event represents a file-level change
 type Event struct {
@@ -17,3 +21,17 @@ func NewEvent(path string, event notify.Event) *Event {
 func (e *Event) AbsoluteFilepath() string {
 	return e.path
 }
+
+// Removed gets whether this Event left the file missing from disk (e.g. it was deleted, or renamed away)
+func (e *Event) Removed() bool {
+	switch e.event {
+	case notify.Remove, notify.Rename:
+		return !fileExists(e.path)
+	}
+	return false
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
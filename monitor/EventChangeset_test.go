@@ -1,8 +1,10 @@
 package monitor
 
 import (
+	"path/filepath"
 	"testing"
 
+	"github.com/mrcrowl/swarm/testutil"
 	"github.com/rjeczalik/notify"
 
 	"github.com/stretchr/testify/assert"
@@ -85,3 +87,16 @@ func TestHasHTMLAndCSSChanges(t *testing.T) {
 	assert.ElementsMatch(t, []string{".html", ".css"}, sut.AffectedFileExts())
 	assert.False(t, sut.HasSingleExt(".css"))
 }
+
+func TestEventRemoved(t *testing.T) {
+	dir := testutil.CreateTempDirWithPrefix("TestEventRemoved")
+	defer testutil.RemoveTempDir(dir)
+	existing := testutil.WriteTextFile(dir, "existing.js", "")
+	missing := filepath.Join(dir, "missing.js")
+
+	assert.True(t, NewEvent(missing, notify.Remove).Removed())
+	assert.True(t, NewEvent(missing, notify.Rename).Removed())
+	assert.False(t, NewEvent(existing, notify.Rename).Removed())
+	assert.False(t, NewEvent(existing, notify.Remove).Removed())
+	assert.False(t, NewEvent(existing, notify.Write).Removed())
+}
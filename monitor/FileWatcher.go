@@ -0,0 +1,117 @@
+package monitor
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// FileWatcher polls a few specific files (e.g. swarm.json and build description files), which may live outside
+// the workspace, and calls back when any of them are created, modified or deleted
+type FileWatcher struct {
+	interval        time.Duration
+	stats           map[string]fileStat // absolute filepath --> stat (absent for missing files)
+	changeCallbacks []func(changedFilepaths []string)
+	mutex           *sync.Mutex
+	stop            chan bool
+}
+
+// NewFileWatcher creates a FileWatcher for a list of absolute filepaths
+func NewFileWatcher(filepaths []string, interval time.Duration) *FileWatcher {
+	fw := &FileWatcher{
+		interval: interval,
+		mutex:    &sync.Mutex{},
+	}
+	fw.SetFilepaths(filepaths)
+	return fw
+}
+
+// SetFilepaths replaces the files being watched, e.g. after a configuration change points at a different file
+func (fw *FileWatcher) SetFilepaths(filepaths []string) {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	stats := make(map[string]fileStat, len(filepaths))
+	for _, filepath := range filepaths {
+		if stat, ok := fw.stats[filepath]; ok {
+			stats[filepath] = stat
+		} else {
+			stats[filepath] = statFile(filepath)
+		}
+	}
+	fw.stats = stats
+}
+
+// RegisterCallback adds a function to be called with the files that changed
+func (fw *FileWatcher) RegisterCallback(fn func(changedFilepaths []string)) {
+	fw.mutex.Lock()
+	fw.changeCallbacks = append(fw.changeCallbacks, fn)
+	fw.mutex.Unlock()
+}
+
+// Watch polls for changes until stopped (blocking)
+func (fw *FileWatcher) Watch() {
+	fw.mutex.Lock()
+	fw.stop = make(chan bool)
+	stop := fw.stop
+	fw.mutex.Unlock()
+
+	ticker := time.NewTicker(fw.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if changedFilepaths := fw.poll(); len(changedFilepaths) > 0 {
+				fw.triggerCallbacks(changedFilepaths)
+			}
+
+		case <-stop:
+			return
+		}
+	}
+}
+
+// poll stats every file, returning those that have changed since the last poll
+func (fw *FileWatcher) poll() []string {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+
+	var changedFilepaths []string
+	for filepath, previous := range fw.stats {
+		current := statFile(filepath)
+		if !current.same(previous) {
+			changedFilepaths = append(changedFilepaths, filepath)
+			fw.stats[filepath] = current
+		}
+	}
+	return changedFilepaths
+}
+
+func (fw *FileWatcher) triggerCallbacks(changedFilepaths []string) {
+	fw.mutex.Lock()
+	callbacks := append([]func([]string){}, fw.changeCallbacks...)
+	fw.mutex.Unlock()
+
+	for _, callback := range callbacks {
+		callback(changedFilepaths)
+	}
+}
+
+// Stop ends polling
+func (fw *FileWatcher) Stop() {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+	if fw.stop != nil {
+		close(fw.stop)
+		fw.stop = nil
+	}
+}
+
+// statFile gets the stat of a file, with a size of -1 if it doesn't exist
+func statFile(filepath string) fileStat {
+	info, err := os.Stat(filepath)
+	if err != nil {
+		return fileStat{size: -1}
+	}
+	return fileStat{info.Size(), info.ModTime()}
+}
@@ -0,0 +1,50 @@
+package monitor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileWatcherPoll(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	swarmJSONFilepath := testutil.WriteTextFile(temppath, "swarm.json", "{}")
+	buildFilepath := filepath.Join(temppath, "build.json")
+
+	fw := NewFileWatcher([]string{swarmJSONFilepath, buildFilepath}, time.Second)
+	assert.Empty(t, fw.poll())
+
+	testutil.WriteTextFile(temppath, "swarm.json", `{"root": "."}`)
+	testutil.WriteTextFile(temppath, "build.json", "{}")
+	assert.ElementsMatch(t, []string{swarmJSONFilepath, buildFilepath}, fw.poll())
+	assert.Empty(t, fw.poll())
+
+	fw.SetFilepaths([]string{buildFilepath})
+	testutil.WriteTextFile(temppath, "swarm.json", `{"root": "..."}`)
+	assert.Empty(t, fw.poll())
+}
+
+func TestFileWatcherCallback(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	swarmJSONFilepath := testutil.WriteTextFile(temppath, "swarm.json", "{}")
+
+	changed := make(chan []string, 1)
+	fw := NewFileWatcher([]string{swarmJSONFilepath}, 20*time.Millisecond)
+	fw.RegisterCallback(func(changedFilepaths []string) { changed <- changedFilepaths })
+	go fw.Watch()
+	defer fw.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	testutil.WriteTextFile(temppath, "swarm.json", `{"root": "."}`)
+	select {
+	case changedFilepaths := <-changed:
+		assert.Equal(t, []string{swarmJSONFilepath}, changedFilepaths)
+	case <-time.After(2 * time.Second):
+		t.Fatal("FileWatcher didn't call back")
+	}
+}
@@ -1,10 +1,10 @@
 package monitor
 
 import (
-	"fmt"
-	"log"
 	"path/filepath"
 	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/events"
+	"github.com/mrcrowl/swarm/logging"
 	"github.com/mrcrowl/swarm/source"
 	"sync"
 	"time"
@@ -15,37 +15,80 @@ import (
 // FilterFn is the shape of a function that can used as a filter for a Monitor
 type FilterFn func(notify.Event, string) bool
 
+// SkipDirFn is the shape of a function that decides whether a directory should be skipped entirely
+type SkipDirFn func(absoluteDirpath string) bool
+
+// swarmIgnoreFilename is a file in the root of the workspace containing additional gitignore-style patterns
+const swarmIgnoreFilename = ".swarmignore"
+
 // Monitor is used to recursively watch for file changes within a workspace
 type Monitor struct {
 	workspace        *source.Workspace
+	watcher          Watcher
 	channel          chan notify.EventInfo
 	filter           FilterFn
+	ignore           *PathMatcher
 	debounceDuration time.Duration
 	changeCallbacks  []func(changes *EventChangeset)
 	callbackMutex    *sync.Mutex
 }
 
 // NewMonitor creates a new Monitor
-func NewMonitor(workspace *source.Workspace, config *config.MonitorConfig) *Monitor {
-	channel := make(chan notify.EventInfo, 2048)
+func NewMonitor(workspace *source.Workspace, config *config.MonitorConfig) (*Monitor, error) {
+	ignore := NewPathMatcher(config.Ignore)
+	if patterns, err := ReadPathPatternFile(filepath.Join(workspace.RootPath(), swarmIgnoreFilename)); err == nil {
+		ignore.Append(patterns)
+	}
+
+	mon := &Monitor{
+		workspace:        workspace,
+		channel:          make(chan notify.EventInfo, 2048),
+		ignore:           ignore,
+		debounceDuration: time.Millisecond * time.Duration(config.DebounceMillis),
+		callbackMutex:    &sync.Mutex{},
+	}
+	mon.filter = mon.createFilterFn(config.Extensions, NewPathMatcher(config.Include))
+
+	watcher, err := NewWatcher(config, mon.skipDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Watch(workspace.RootPath(), mon.channel); err != nil {
+		return nil, err
+	}
+	mon.watcher = watcher
+
+	return mon, nil
+}
+
+// createFilterFn creates a filter which accepts files with one of the extensions that aren't
+// ignored, and (when there are include patterns) are explicitly included
+func (mon *Monitor) createFilterFn(extensions []string, include *PathMatcher) FilterFn {
+	extensionFilter := createExtensionFilterFn(extensions)
+	return func(event notify.Event, path string) bool {
+		if !extensionFilter(event, path) {
+			return false
+		}
+
+		relativePath, ok := mon.workspace.ToRelativePath(path)
+		if !ok {
+			return true
+		}
+
+		if mon.ignore.Matches(relativePath) {
+			return false
+		}
 
-	rootPathRecursive := filepath.Join(workspace.RootPath(), "./...")
-	if err := notify.Watch(rootPathRecursive, channel, (notify.Write | notify.Remove)); err != nil {
-		log.Fatal(err)
+		return include.Empty() || include.Matches(relativePath)
 	}
+}
 
-	filter := createExtensionFilterFn(config.Extensions)
-	debounceDuration := time.Millisecond * time.Duration(config.DebounceMillis)
-	callbackMutex := &sync.Mutex{}
-
-	return &Monitor{
-		workspace,
-		channel,
-		filter,
-		debounceDuration,
-		nil,
-		callbackMutex,
+// skipDir tests whether a directory is ignored
+func (mon *Monitor) skipDir(absoluteDirpath string) bool {
+	if relativePath, ok := mon.workspace.ToRelativePath(absoluteDirpath); ok && relativePath != "" {
+		return mon.ignore.MatchesDir(relativePath)
 	}
+	return false
 }
 
 func createExtensionFilterFn(extensions []string) FilterFn {
@@ -70,26 +113,68 @@ func (mon *Monitor) RegisterCallback(callback func(changes *EventChangeset)) {
 
 // TriggerManually is used to manually trigger the NotifyOnChanges event
 func (mon *Monitor) TriggerManually() {
-	mon.triggerCallbacks(nil, time.Now(), true)
+	mon.TriggerAfter(nil)
+}
+
+// TriggerAfter manually triggers the NotifyOnChanges event, after calling prepare (if not nil).  prepare is called
+// while no other callbacks are running, so it can safely change what the callbacks will do, e.g. to force a rebuild
+func (mon *Monitor) TriggerAfter(prepare func()) {
+	mon.triggerCallbacks(nil, time.Now(), true, prepare)
 }
 
-func (mon *Monitor) triggerCallbacks(changeset *EventChangeset, start time.Time, silent bool) {
+func (mon *Monitor) triggerCallbacks(changeset *EventChangeset, start time.Time, silent bool, prepare func()) {
 	mon.callbackMutex.Lock()
+	if prepare != nil {
+		prepare()
+	}
+
+	files := mon.relativeFilepaths(changeset)
+	events.Publish(&events.Event{Type: events.BuildStart, Files: files})
+	stopRecording := logging.Default().Record(logging.LevelWarn)
 
-	fmt.Println("")
 	for _, callback := range mon.changeCallbacks {
 		callback(changeset)
 	}
 
+	elapsed := time.Since(start)
+	buildEnd := &events.Event{Type: events.BuildEnd, Files: files, DurationMs: events.Duration(elapsed)}
+	for _, entry := range stopRecording() {
+		if entry.Level >= logging.LevelError {
+			buildEnd.Errors = append(buildEnd.Errors, entry.Message)
+		} else {
+			buildEnd.Warnings = append(buildEnd.Warnings, entry.Message)
+		}
+		if file, ok := entry.Fields["file"].(string); ok && entry.Event == "missing-import" {
+			buildEnd.MissingImports = append(buildEnd.MissingImports, file)
+		}
+	}
+	events.Publish(buildEnd)
+
 	if !silent {
-		elapsed := time.Since(start)
-		fmt.Printf("...done in %s\n", elapsed)
+		logging.Summary("rebuild", logging.Fields{"duration": elapsed, "files": changeset.count()}, "...done in %s", elapsed)
 	}
 
 	mon.callbackMutex.Unlock()
 	// pprof.StopCPUProfile()
 }
 
+// relativeFilepaths gets the changed files in a changeset, relative to the workspace root
+func (mon *Monitor) relativeFilepaths(changeset *EventChangeset) []string {
+	if changeset == nil {
+		return nil
+	}
+
+	var files []string
+	for _, change := range changeset.Changes() {
+		if relativePath, ok := mon.workspace.ToRelativePath(change.AbsoluteFilepath()); ok {
+			files = append(files, relativePath)
+		} else {
+			files = append(files, change.AbsoluteFilepath())
+		}
+	}
+	return files
+}
+
 // NotifyOnChanges notifies when events occur (after debouncing)
 func (mon *Monitor) NotifyOnChanges() {
 	debounceTimer := time.NewTimer(notifyInterval)
@@ -108,9 +193,9 @@ func (mon *Monitor) NotifyOnChanges() {
 					start = time.Now()
 					// f, _ := os.Create("cpu.prof")
 					// pprof.StartCPUProfile(f)
-					fmt.Print("Change detected...")
+					logging.Info("change", logging.Fields{"file": path}, "Change detected...")
 				} else {
-					fmt.Print(".")
+					logging.Debug("change", logging.Fields{"file": path}, "...and %s", path)
 				}
 				changeset.Add(event, path)
 				debounceTimer.Reset(mon.debounceDuration)
@@ -119,11 +204,10 @@ func (mon *Monitor) NotifyOnChanges() {
 		case <-debounceTimer.C:
 			// debounce and fire callback
 			if changeset.nonEmpty() {
-				go mon.triggerCallbacks(changeset, start, false)
+				go mon.triggerCallbacks(changeset, start, false, nil)
 				changeset = NewEventChangeset()
 			} else {
-				fmt.Println("")
-				fmt.Println("...no changes")
+				logging.Info("no-changes", nil, "...no changes")
 			}
 		}
 	}
@@ -131,5 +215,5 @@ func (mon *Monitor) NotifyOnChanges() {
 
 // Stop cancels the recursive watcher
 func (mon *Monitor) Stop() {
-	notify.Stop(mon.channel)
+	mon.watcher.Stop()
 }
@@ -14,13 +14,16 @@ import (
 func TestMonitor(t *testing.T) {
 	dir := testutil.CreateTempDirWithPrefix("TestMonitor")
 	ws := source.NewWorkspace(dir)
-	mon := NewMonitor(ws, config.NewMonitorConfig([]string{".js"}, 150))
+	mon, err := NewMonitor(ws, config.NewMonitorConfig([]string{".js"}, 150))
+	assert.Nil(t, err)
 
 	notifyCount := 0
-	eventCount := 0
+	changedFiles := make(map[string]bool)
 	mon.RegisterCallback(func(ec *EventChangeset) {
 		notifyCount++
-		eventCount += ec.count()
+		for _, change := range ec.Changes() {
+			changedFiles[change.AbsoluteFilepath()] = true
+		}
 	})
 	go mon.NotifyOnChanges()
 
@@ -39,5 +42,5 @@ func TestMonitor(t *testing.T) {
 	assert.Equal(t, 0, notifyCount)
 	time.Sleep(1 * time.Second)
 	assert.Equal(t, 1, notifyCount)
-	assert.Equal(t, 9, eventCount) // 10 - 1 filtered
+	assert.Equal(t, 9, len(changedFiles)) // 10 - 1 filtered
 }
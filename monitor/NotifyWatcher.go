@@ -0,0 +1,36 @@
+package monitor
+
+import (
+	"path/filepath"
+
+	"github.com/rjeczalik/notify"
+)
+
+const notifyEvents = notify.Create | notify.Write | notify.Remove | notify.Rename
+
+// notifyWatcher receives events from the operating system's native file system notifications
+type notifyWatcher struct {
+	channel chan<- notify.EventInfo
+}
+
+func newNotifyWatcher() *notifyWatcher {
+	return &notifyWatcher{}
+}
+
+// Watch recursively watches rootPath, sending events to channel
+func (nw *notifyWatcher) Watch(rootPath string, channel chan<- notify.EventInfo) error {
+	rootPathRecursive := filepath.Join(rootPath, "./...")
+	if err := notify.Watch(rootPathRecursive, channel, notifyEvents); err != nil {
+		return err
+	}
+	nw.channel = channel
+	return nil
+}
+
+// Stop cancels the recursive watch
+func (nw *notifyWatcher) Stop() {
+	if nw.channel != nil {
+		notify.Stop(nw.channel)
+		nw.channel = nil
+	}
+}
@@ -0,0 +1,151 @@
+package monitor
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mrcrowl/swarm/util"
+)
+
+// PathMatcher matches root-relative paths against an ordered list of gitignore-style patterns.
+// As with .gitignore, the last matching pattern wins, "!" negates a pattern, a trailing "/" only
+// matches directories, and a pattern without a "/" matches at any depth
+type PathMatcher struct {
+	patterns []*pathPattern
+}
+
+type pathPattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// NewPathMatcher creates a PathMatcher from a list of patterns (blank lines and # comments are skipped)
+func NewPathMatcher(patterns []string) *PathMatcher {
+	pm := &PathMatcher{}
+	pm.Append(patterns)
+	return pm
+}
+
+// Append adds more patterns, which take precedence over existing ones
+func (pm *PathMatcher) Append(patterns []string) {
+	for _, line := range patterns {
+		if pattern := parsePathPattern(line); pattern != nil {
+			pm.patterns = append(pm.patterns, pattern)
+		}
+	}
+}
+
+// Empty gets whether the PathMatcher has no patterns
+func (pm *PathMatcher) Empty() bool {
+	return len(pm.patterns) == 0
+}
+
+// Matches tests whether a file, or any of the directories containing it, matches
+func (pm *PathMatcher) Matches(relativePath string) bool {
+	segments := strings.Split(strings.Trim(relativePath, "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		if pm.match(strings.Join(segments[:i], "/"), true) {
+			return true
+		}
+	}
+	return pm.match(strings.Join(segments, "/"), false)
+}
+
+// MatchesDir tests whether a directory, or any of its parents, matches
+func (pm *PathMatcher) MatchesDir(relativePath string) bool {
+	segments := strings.Split(strings.Trim(relativePath, "/"), "/")
+	for i := 1; i <= len(segments); i++ {
+		if pm.match(strings.Join(segments[:i], "/"), true) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pm *PathMatcher) match(path string, isDir bool) bool {
+	matched := false
+	for _, pattern := range pm.patterns {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+		if pattern.re.MatchString(path) {
+			matched = !pattern.negate
+		}
+	}
+	return matched
+}
+
+// ReadPathPatternFile reads the patterns from a .gitignore-style file
+func ReadPathPatternFile(filepath string) ([]string, error) {
+	contents, err := util.ReadContents(filepath)
+	if err != nil {
+		return nil, err
+	}
+	return util.StringToLines(contents), nil
+}
+
+func parsePathPattern(line string) *pathPattern {
+	pattern := strings.TrimSpace(line)
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return nil
+	}
+
+	negate := strings.HasPrefix(pattern, "!")
+	if negate {
+		pattern = pattern[1:]
+	}
+
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	// patterns containing a slash are relative to the root, otherwise they match at any depth
+	prefix := "^(?:.*/)?"
+	if strings.Contains(pattern, "/") {
+		prefix = "^"
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+
+	re, err := regexp.Compile(prefix + globToRegexp(pattern) + "$")
+	if err != nil {
+		return nil
+	}
+	return &pathPattern{re, negate, dirOnly}
+}
+
+// globToRegexp converts a glob (supporting *, **, ? and [...]) into an equivalent regular expression
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	n := len(glob)
+	for i := 0; i < n; i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && i+1 < n && glob[i+1] == '*':
+			if i+2 < n && glob[i+2] == '/' {
+				sb.WriteString("(?:.*/)?") // zero or more directories
+				i += 2
+			} else {
+				sb.WriteString(".*")
+				i++
+			}
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[' && strings.IndexByte(glob[i:], ']') > 1:
+			closePos := i + strings.IndexByte(glob[i:], ']')
+			class := glob[i+1 : closePos]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i = closePos
+		case c == '\\' && i+1 < n:
+			sb.WriteString(regexp.QuoteMeta(string(glob[i+1])))
+			i++
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
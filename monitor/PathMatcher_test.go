@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/rjeczalik/notify"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathMatcher(t *testing.T) {
+	cases := map[string]struct {
+		patterns []string
+		path     string
+		expected bool
+	}{
+		"basename-any-depth":   {[]string{"*.min.js"}, "app/libs/jquery.min.js", true},
+		"basename-no-match":    {[]string{"*.min.js"}, "app/libs/jquery.js", false},
+		"dir-any-depth":        {[]string{"node_modules/"}, "app/node_modules/x/index.js", true},
+		"dir-only-not-file":    {[]string{"dist/"}, "app/dist", false},
+		"anchored":             {[]string{"/build"}, "build/out.js", true},
+		"anchored-not-nested":  {[]string{"/build"}, "app/build/out.js", false},
+		"nested-slash":         {[]string{"app/generated"}, "app/generated/a.js", true},
+		"double-star-prefix":   {[]string{"**/temp/*.js"}, "a/b/temp/x.js", true},
+		"double-star-middle":   {[]string{"app/**/*.spec.js"}, "app/a/b/c.spec.js", true},
+		"double-star-zero-dir": {[]string{"app/**/*.spec.js"}, "app/c.spec.js", true},
+		"question":             {[]string{"file?.js"}, "file1.js", true},
+		"class":                {[]string{"file[0-9].js"}, "filex.js", false},
+		"negated-class":        {[]string{"file[!0-9].js"}, "filex.js", true},
+		"negation":             {[]string{"*.js", "!keep.js"}, "src/keep.js", false},
+		"negation-order":       {[]string{"!keep.js", "*.js"}, "src/keep.js", true},
+		"parent-excluded":      {[]string{"out/", "!out/keep.js"}, "out/keep.js", true},
+		"comment":              {[]string{"# *.js"}, "a.js", false},
+		"blank":                {[]string{"", "   "}, "a.js", false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sut := NewPathMatcher(tc.patterns)
+			assert.Equal(t, tc.expected, sut.Matches(tc.path))
+		})
+	}
+}
+
+func TestPathMatcherMatchesDir(t *testing.T) {
+	sut := NewPathMatcher([]string{"node_modules/", "/dist"})
+	assert.True(t, sut.MatchesDir("node_modules"))
+	assert.True(t, sut.MatchesDir("app/node_modules/lib"))
+	assert.True(t, sut.MatchesDir("dist"))
+	assert.False(t, sut.MatchesDir("app"))
+}
+
+func TestPathMatcherEmpty(t *testing.T) {
+	assert.True(t, NewPathMatcher(nil).Empty())
+	assert.True(t, NewPathMatcher([]string{"# comment"}).Empty())
+	assert.False(t, NewPathMatcher([]string{"*.js"}).Empty())
+}
+
+func TestMonitorFilter(t *testing.T) {
+	dir := testutil.CreateTempDirWithPrefix("TestMonitorFilter")
+	defer testutil.RemoveTempDir(dir)
+	testutil.WriteTextFile(dir, swarmIgnoreFilename, "# generated output\ngenerated/\n")
+
+	monitorConfig := config.NewMonitorConfig([]string{".js"}, 150)
+	monitorConfig.Watcher = config.WatcherPoll
+	monitorConfig.Include = []string{"app/", "Config.js"}
+	mon, err := NewMonitor(source.NewWorkspace(dir), monitorConfig)
+	assert.Nil(t, err)
+	defer mon.Stop()
+
+	cases := map[string]bool{
+		"app/src/main.js":           true,
+		"Config.js":                 true,
+		"app/src/main.css":          false,
+		"other/main.js":             false,
+		"app/generated/main.js":     false,
+		"app/node_modules/x/a.js":   false,
+		".git/hooks/pre-commit.js":  false,
+		"app/src/node_modules.js":   true,
+		"app/src/generated-file.js": true,
+	}
+	for relativePath, expected := range cases {
+		absoluteFilepath := filepath.Join(dir, filepath.FromSlash(relativePath))
+		assert.Equal(t, expected, mon.filter(notify.Write, absoluteFilepath), relativePath)
+	}
+
+	assert.True(t, mon.skipDir(filepath.Join(dir, "app", "generated")))
+	assert.False(t, mon.skipDir(filepath.Join(dir, "app")))
+	assert.False(t, mon.skipDir(dir))
+}
@@ -0,0 +1,161 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rjeczalik/notify"
+)
+
+// pollingWatcher detects changes by periodically stat-ing every file beneath the root path.
+// It is slower than notifyWatcher, but works where native notifications never arrive,
+// e.g. network drives, bind-mounted Docker volumes and WSL mounts
+type pollingWatcher struct {
+	interval time.Duration
+	skipDir  SkipDirFn // may be nil
+	stop     chan bool
+}
+
+func newPollingWatcher(interval time.Duration, skipDir SkipDirFn) *pollingWatcher {
+	return &pollingWatcher{interval: interval, skipDir: skipDir}
+}
+
+// Watch takes an initial snapshot of rootPath, then polls for changes until stopped
+func (pw *pollingWatcher) Watch(rootPath string, channel chan<- notify.EventInfo) error {
+	if _, err := os.Stat(rootPath); err != nil {
+		return err
+	}
+
+	previous := takeSnapshot(rootPath, pw.skipDir)
+	pw.stop = make(chan bool)
+	go pw.poll(rootPath, previous, channel, pw.stop)
+	return nil
+}
+
+func (pw *pollingWatcher) poll(rootPath string, previous snapshot, channel chan<- notify.EventInfo, stop chan bool) {
+	ticker := time.NewTicker(pw.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			current := takeSnapshot(rootPath, pw.skipDir)
+			for _, e := range diffSnapshots(previous, current) {
+				channel <- e
+			}
+			previous = current
+
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop ends polling
+func (pw *pollingWatcher) Stop() {
+	if pw.stop != nil {
+		close(pw.stop)
+		pw.stop = nil
+	}
+}
+
+// fileStat is the subset of os.FileInfo used to detect a change
+type fileStat struct {
+	size    int64
+	modTime time.Time
+}
+
+func (fs fileStat) same(other fileStat) bool {
+	return fs.size == other.size && fs.modTime.Equal(other.modTime)
+}
+
+// snapshot maps absolute filepaths to their stat at a point in time
+type snapshot map[string]fileStat
+
+func takeSnapshot(rootPath string, skipDir SkipDirFn) snapshot {
+	snap := snapshot{}
+	filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if skipDir != nil && skipDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		snap[path] = fileStat{info.Size(), info.ModTime()}
+		return nil
+	})
+	return snap
+}
+
+// diffSnapshots lists the events that turn one snapshot into another.
+// A removed file and a created file with identical size and modification time are reported as a rename
+func diffSnapshots(previous snapshot, current snapshot) []notify.EventInfo {
+	var created, removed []string
+	var events []notify.EventInfo
+
+	for _, path := range sortedPaths(current) {
+		prevStat, existed := previous[path]
+		if !existed {
+			created = append(created, path)
+		} else if !prevStat.same(current[path]) {
+			events = append(events, &pollEvent{notify.Write, path})
+		}
+	}
+
+	for _, path := range sortedPaths(previous) {
+		if _, exists := current[path]; !exists {
+			removed = append(removed, path)
+		}
+	}
+
+	renamedTo := make(map[string]bool)
+	for _, oldPath := range removed {
+		newPath := findRenameTarget(previous[oldPath], created, current, renamedTo)
+		if newPath == "" {
+			events = append(events, &pollEvent{notify.Remove, oldPath})
+			continue
+		}
+		renamedTo[newPath] = true
+		events = append(events, &pollEvent{notify.Rename, oldPath}, &pollEvent{notify.Rename, newPath})
+	}
+
+	for _, path := range created {
+		if !renamedTo[path] {
+			events = append(events, &pollEvent{notify.Create, path})
+		}
+	}
+
+	return events
+}
+
+func findRenameTarget(stat fileStat, created []string, current snapshot, claimed map[string]bool) string {
+	for _, path := range created {
+		if !claimed[path] && current[path].same(stat) {
+			return path
+		}
+	}
+	return ""
+}
+
+func sortedPaths(snap snapshot) []string {
+	paths := make([]string, 0, len(snap))
+	for path := range snap {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// pollEvent is the notify.EventInfo produced by a pollingWatcher
+type pollEvent struct {
+	event notify.Event
+	path  string
+}
+
+func (pe *pollEvent) Event() notify.Event { return pe.event }
+func (pe *pollEvent) Path() string        { return pe.path }
+func (pe *pollEvent) Sys() interface{}    { return nil }
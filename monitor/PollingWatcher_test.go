@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/rjeczalik/notify"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var epoch = time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+
+func eventStrings(events []notify.EventInfo) []string {
+	result := make([]string, len(events))
+	for i, e := range events {
+		result[i] = makeEventKey(e.Event(), e.Path())
+	}
+	return result
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	previous := snapshot{
+		"same.js":    fileStat{10, epoch},
+		"written.js": fileStat{10, epoch},
+		"removed.js": fileStat{20, epoch},
+		"old.js":     fileStat{30, epoch.Add(time.Second)},
+	}
+	current := snapshot{
+		"same.js":    fileStat{10, epoch},
+		"written.js": fileStat{11, epoch.Add(time.Second)},
+		"created.js": fileStat{40, epoch},
+		"new.js":     fileStat{30, epoch.Add(time.Second)},
+	}
+
+	events := diffSnapshots(previous, current)
+	assert.ElementsMatch(t, []string{
+		"W:written.js",
+		"D:removed.js",
+		"C:created.js",
+		"M:old.js",
+		"M:new.js",
+	}, eventStrings(events))
+}
+
+func TestDiffSnapshotsUnchanged(t *testing.T) {
+	snap := snapshot{"same.js": fileStat{10, epoch}}
+	assert.Empty(t, diffSnapshots(snap, snap))
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	dir := testutil.CreateTempDirWithPrefix("TestTakeSnapshot")
+	defer testutil.RemoveTempDir(dir)
+	subdir := testutil.MakeSubdirectoryTree(dir, "one/two")
+	testutil.WriteTextFile(dir, "a.js", "a")
+	testutil.WriteTextFile(subdir, "b.js", "bb")
+
+	snap := takeSnapshot(dir, nil)
+	assert.Len(t, snap, 2)
+	assert.Equal(t, int64(2), snap[filepath.Join(subdir, "b.js")].size)
+}
+
+func TestNewWatcherUnknown(t *testing.T) {
+	monitorConfig := config.NewMonitorConfig([]string{".js"}, 150)
+	monitorConfig.Watcher = "psychic"
+	_, err := NewWatcher(monitorConfig, nil)
+	assert.NotNil(t, err)
+}
+
+func TestMonitorPolling(t *testing.T) {
+	dir := testutil.CreateTempDirWithPrefix("TestMonitorPolling")
+	defer testutil.RemoveTempDir(dir)
+	testutil.WriteTextFile(dir, "existing.js", "hello world")
+
+	monitorConfig := config.NewMonitorConfig([]string{".js"}, 150)
+	monitorConfig.Watcher = config.WatcherPoll
+	monitorConfig.PollIntervalMillis = 50
+	mon, err := NewMonitor(source.NewWorkspace(dir), monitorConfig)
+	assert.Nil(t, err)
+	defer mon.Stop()
+
+	changesets := make(chan *EventChangeset, 1)
+	mon.RegisterCallback(func(ec *EventChangeset) { changesets <- ec })
+	go mon.NotifyOnChanges()
+
+	testutil.WriteTextFile(dir, "created.js", "hello world")
+	os.Rename(filepath.Join(dir, "existing.js"), filepath.Join(dir, "renamed.js"))
+
+	select {
+	case ec := <-changesets:
+		keys := make([]string, 0)
+		for _, change := range ec.Changes() {
+			keys = append(keys, makeEventKey(change.event, filepath.Base(change.AbsoluteFilepath())))
+		}
+		assert.ElementsMatch(t, []string{"C:created.js", "M:existing.js", "M:renamed.js"}, keys)
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "no changeset received from polling watcher")
+	}
+}
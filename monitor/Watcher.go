@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"fmt"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/logging"
+	"github.com/rjeczalik/notify"
+)
+
+// Watcher is a source of file-level events for a recursively watched directory
+type Watcher interface {
+	Watch(rootPath string, channel chan<- notify.EventInfo) error
+	Stop()
+}
+
+// NewWatcher creates the Watcher described by a MonitorConfig, which may skip (unnecessarily) scanning some directories
+func NewWatcher(monitorConfig *config.MonitorConfig, skipDir SkipDirFn) (Watcher, error) {
+	pollInterval := monitorConfig.PollInterval()
+	switch monitorConfig.WatcherKind() {
+	case config.WatcherAuto:
+		return newFallbackWatcher(newNotifyWatcher(), newPollingWatcher(pollInterval, skipDir)), nil
+	case config.WatcherNotify:
+		return newNotifyWatcher(), nil
+	case config.WatcherPoll:
+		return newPollingWatcher(pollInterval, skipDir), nil
+	}
+
+	return nil, fmt.Errorf("Unknown watcher '%s'", monitorConfig.Watcher)
+}
+
+// fallbackWatcher tries a primary watcher, then switches to a secondary watcher if the first fails
+type fallbackWatcher struct {
+	primary   Watcher
+	secondary Watcher
+	active    Watcher
+}
+
+func newFallbackWatcher(primary Watcher, secondary Watcher) *fallbackWatcher {
+	return &fallbackWatcher{primary, secondary, nil}
+}
+
+// Watch starts the primary watcher, falling back to the secondary watcher on error
+func (fw *fallbackWatcher) Watch(rootPath string, channel chan<- notify.EventInfo) error {
+	err := fw.primary.Watch(rootPath, channel)
+	if err == nil {
+		fw.active = fw.primary
+		return nil
+	}
+
+	logging.Warn("watch-fallback", logging.Fields{"file": rootPath, "error": err}, "Failed to watch '%s' (%s), falling back to polling", rootPath, err)
+	if err := fw.secondary.Watch(rootPath, channel); err != nil {
+		return err
+	}
+	fw.active = fw.secondary
+	return nil
+}
+
+// Stop stops whichever watcher is active
+func (fw *fallbackWatcher) Stop() {
+	if fw.active != nil {
+		fw.active.Stop()
+		fw.active = nil
+	}
+}
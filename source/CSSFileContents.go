@@ -1,6 +1,7 @@
 package source
 
 import (
+	"encoding/base64"
 	"fmt"
 	"path"
 	"path/filepath"
@@ -18,6 +19,8 @@ const (
 type CSSFileContents struct {
 	lines         []string
 	rawCSSContent string
+	css           string
+	includes      []string
 }
 
 // BundleLines returns a list of lines ready to include in a SystemJSBundle
@@ -30,6 +33,16 @@ func (cssfc *CSSFileContents) RawCSSContent() string {
 	return cssfc.rawCSSContent
 }
 
+// CSS returns the CSS as it was bundled, i.e. after it went through the CSSPipeline
+func (cssfc *CSSFileContents) CSS() string {
+	return cssfc.css
+}
+
+// Includes returns the absolute filepaths of the files inlined by @imports
+func (cssfc *CSSFileContents) Includes() []string {
+	return cssfc.includes
+}
+
 // SourceMappingURL returns ""
 func (cssfc *CSSFileContents) SourceMappingURL() string {
 	return ""
@@ -49,30 +62,48 @@ const cssTemplate = `System.register("%s", [], function (_export, _context) {
 	}
 });`
 
-// ParseCSSFileContents parses the lines of a CSS file into bundle-ready code
-func ParseCSSFileContents(name string, cssContents string, base string) (*CSSFileContents, error) {
-	cssContentsWithURLsRewritten := rewriteURLStatementsInCSS(cssContents, name)
-	encodedFile := util.JSONEncodeString(cssContentsWithURLsRewritten)
+// ParseCSSFileContents runs a CSS file through a CSSPipeline, and prepares the result as bundle-ready code.
+// The injected CSS ends with an inline source map, and a sourceURL, so that devtools can link it to its sources
+func ParseCSSFileContents(name string, cssContents string, pipeline *CSSPipeline) (*CSSFileContents, error) {
+	processed := pipeline.Process(name, cssContents)
+	css := processed.CSS + cssSourceMapComments(name, processed.SourceMap(name))
+	encodedFile := util.JSONEncodeString(css)
 	body := fmt.Sprintf(cssTemplate, name, encodedFile, CSSPrefix+name)
 	lines := util.StringToLines(body)
-	return &CSSFileContents{lines, cssContents}, nil
+	return &CSSFileContents{lines, cssContents, css, processed.Includes}, nil
 }
 
-var rewriteURLPattern = regexp.MustCompile(`url\(['"][^'"]+['"]\)`)
+// cssSourceMapComments creates the comments which give injected CSS a name and source map in devtools
+func cssSourceMapComments(name string, sourceMapJSON string) string {
+	encodedMap := base64.StdEncoding.EncodeToString([]byte(sourceMapJSON))
+	return "\n/*# sourceMappingURL=data:application/json;charset=utf-8;base64," + encodedMap + " */\n/*# sourceURL=/" + name + " */"
+}
 
-func rewriteURLStatementsInCSS(css string, name string) string {
-	rewrittenCSS := rewriteURLPattern.ReplaceAllStringFunc(css, func(cssURLStatement string) string {
-		uri := extractURI(cssURLStatement)
-		quote := string(cssURLStatement[4])
-		rewrittenURI := rewriteURI(uri, name, "app")
-		return "url(" + quote + rewrittenURI + quote + ")"
-	})
+var rewriteURLPattern = regexp.MustCompile(`url\(\s*(?:"[^"]*"|'[^']*'|[^'"\s)]*)\s*\)`)
 
-	return rewrittenCSS
+// rewriteURLStatementsInCSS makes the url()s in a file's CSS relative to the base href, since the CSS is injected
+// into the page (and so relative urls are resolved against the page, rather than the file)
+func rewriteURLStatementsInCSS(css string, name string, base string) string {
+	masked := maskCSSComments(css)
+	var rewrittenCSS strings.Builder
+	last := 0
+	for _, match := range rewriteURLPattern.FindAllStringIndex(masked, -1) {
+		cssURLStatement := css[match[0]:match[1]]
+		uri := extractURI(cssURLStatement)
+		quote := ""
+		if i := strings.IndexAny(cssURLStatement, `'"`); i >= 0 {
+			quote = string(cssURLStatement[i])
+		}
+		rewrittenCSS.WriteString(css[last:match[0]])
+		rewrittenCSS.WriteString("url(" + quote + rewriteURI(uri, name, base) + quote + ")")
+		last = match[1]
+	}
+	rewrittenCSS.WriteString(css[last:])
+	return rewrittenCSS.String()
 }
 
 func rewriteURI(uri string, name string, base string) string {
-	if isDataURI(uri) {
+	if isAbsoluteURI(uri) {
 		return uri
 	}
 
@@ -85,11 +116,23 @@ func rewriteURI(uri string, name string, base string) string {
 	return rewrittenURI
 }
 
-// extractURI strips url(' and ') from a css url() statement
+// extractURI strips url(, ) and any quotes from a css url() statement
 func extractURI(cssURLStatement string) string {
-	return cssURLStatement[5 : len(cssURLStatement)-2]
+	uri := strings.TrimSpace(cssURLStatement[4 : len(cssURLStatement)-1])
+	if len(uri) >= 2 && (uri[0] == '\'' || uri[0] == '"') && uri[len(uri)-1] == uri[0] {
+		return uri[1 : len(uri)-1]
+	}
+	return uri
 }
 
 func isDataURI(path string) bool {
 	return strings.HasPrefix(path, "data:")
 }
+
+var uriSchemePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// isAbsoluteURI tests whether a uri doesn't depend on the location of the file it's in, e.g. /fonts/a.ttf,
+// https://example.com/a.png, data:... or #filter
+func isAbsoluteURI(uri string) bool {
+	return uri == "" || strings.HasPrefix(uri, "/") || strings.HasPrefix(uri, "#") || isDataURI(uri) || uriSchemePattern.MatchString(uri)
+}
@@ -31,19 +31,51 @@ func TestRewriteCSSUrls(t *testing.T) {
 	cases := map[string]struct {
 		css          string
 		rewrittenCSS string
+		name         string
 		base         string
-	}{}
+	}{
+		"quoted": {
+			inputCSS1,
+			outputCSS1,
+			"common/directives/my-directive.css",
+			"app",
+		},
+		"unquoted": {
+			`a { background: url( ./img/a.png ) }`,
+			`a { background: url(src/img/a.png) }`,
+			"app/src/a.css",
+			"app",
+		},
+		"other base href": {
+			`a { background: url(img/a.png) }`,
+			`a { background: url(../app/src/img/a.png) }`,
+			"app/src/a.css",
+			"controlpanel",
+		},
+		"absolute urls": {
+			`a { background: url(/img/a.png), url("https://example.com/a.png"), url(//example.com/a.png); filter: url(#blur) }`,
+			`a { background: url(/img/a.png), url("https://example.com/a.png"), url(//example.com/a.png); filter: url(#blur) }`,
+			"app/src/a.css",
+			"app",
+		},
+		"commented out": {
+			`/* url(img/a.png) */ a { background: url('img/a.png') }`,
+			`/* url(img/a.png) */ a { background: url('src/img/a.png') }`,
+			"app/src/a.css",
+			"app",
+		},
+	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			rewrittenCSS := rewriteURLStatementsInCSS(tc.css, tc.base)
+			rewrittenCSS := rewriteURLStatementsInCSS(tc.css, tc.name, tc.base)
 			assert.Equal(t, tc.rewrittenCSS, rewrittenCSS)
 		})
 	}
 }
 
 func TestRewriteCSSUrlsDataURI(t *testing.T) {
-	rewrittenCSS := rewriteURLStatementsInCSS(inputCSS2, "common/directives/my-directive.css")
+	rewrittenCSS := rewriteURLStatementsInCSS(inputCSS2, "common/directives/my-directive.css", "app")
 	assert.Equal(t, outputCSS2, rewrittenCSS)
 }
 
@@ -78,8 +110,8 @@ func TestRewriteURI(t *testing.T) {
 func TestIsDataURI(t *testing.T) {
 	cases := map[string]bool{
 		"data:image/svg+xml;base64,PD94bWwgdmVyc2lvbj0iMS4wIiBlbm...": true,
-		"./some-background.png":                                       false,
-		"../../../../../some-background.png":                          false,
+		"./some-background.png":              false,
+		"../../../../../some-background.png": false,
 	}
 	for uri, expected := range cases {
 		t.Run(uri, func(t *testing.T) {
@@ -91,8 +123,10 @@ func TestIsDataURI(t *testing.T) {
 
 func TestExtractURI(t *testing.T) {
 	cases := map[string]string{
-		`url('./some-background.png')`: "./some-background.png",
-		`url("./some-background.png")`: "./some-background.png",
+		`url('./some-background.png')`:   "./some-background.png",
+		`url("./some-background.png")`:   "./some-background.png",
+		`url(./some-background.png)`:     "./some-background.png",
+		`url( "./some-background.png" )`: "./some-background.png",
 	}
 	for uri, expected := range cases {
 		t.Run(uri, func(t *testing.T) {
@@ -0,0 +1,320 @@
+package source
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/logging"
+	"github.com/mrcrowl/swarm/util"
+)
+
+// CSSPipeline prepares CSS files for bundling: @imports of local files are inlined, url()s are made relative to the
+// base href, and (optionally) the result is autoprefixed and minified
+type CSSPipeline struct {
+	rootPath string
+	baseHref string
+	options  *config.CSSConfig
+}
+
+// NewCSSPipeline creates a CSSPipeline for files within a workspace's root path
+func NewCSSPipeline(rootPath string, baseHref string, options *config.CSSConfig) *CSSPipeline {
+	if options == nil {
+		options = &config.CSSConfig{}
+	}
+	return &CSSPipeline{rootPath, strings.Trim(baseHref, "/"), options}
+}
+
+// ProcessedCSS is the output of a CSSPipeline
+type ProcessedCSS struct {
+	CSS      string
+	Includes []string // the absolute filepaths of the files inlined by @imports (including missing ones)
+	mappings []*cssMapping
+	sources  cssSources
+}
+
+// cssImportPattern matches @import "a.css" media; or @import url(a.css) media;
+var cssImportPattern = regexp.MustCompile(`@import\s*(url\(\s*(?:"[^"]*"|'[^']*'|[^'"\s)]*)\s*\)|"[^"]*"|'[^']*')([^;]*);`)
+
+var cssCharsetPattern = regexp.MustCompile(`@charset\s+("[^"]*"|'[^']*')\s*;`)
+
+// Process runs the CSS of a file, by its root-relative ID, through the pipeline
+func (pipeline *CSSPipeline) Process(id string, css string) *ProcessedCSS {
+	processed := &ProcessedCSS{}
+	var remoteImports []string
+	css, mappings := pipeline.inline(id, css, []string{id}, processed, &remoteImports)
+
+	// @imports are ignored after any other rule, so remote ones from inlined files are moved to the top
+	if len(remoteImports) > 0 {
+		prefix := strings.Join(remoteImports, "\n") + "\n"
+		css = prefix + css
+		shiftCSSMappings(mappings, len(prefix))
+	}
+	if pipeline.options.Autoprefix {
+		builder := autoprefixCSS(css)
+		css, mappings = builder.String(), builder.translate(mappings)
+	}
+	if pipeline.options.Minify {
+		builder := minifyCSS(css)
+		css, mappings = builder.String(), builder.translate(mappings)
+	}
+	processed.CSS = css
+	processed.mappings = mappings
+	return processed
+}
+
+// SourceMap generates a source map for the processed CSS, leading back to the files it was inlined from (or their
+// Sass sources, if they have source maps), with their contents included
+func (processed *ProcessedCSS) SourceMap(file string) string {
+	return cssSourceMapJSON(file, processed.CSS, processed.mappings, &processed.sources)
+}
+
+// inline replaces the @imports of local files with their (processed) contents.  stack holds the IDs of the files
+// being inlined, to detect circular imports.  Returns the mappings of the inlined CSS back to its sources
+func (pipeline *CSSPipeline) inline(id string, css string, stack []string, processed *ProcessedCSS, remoteImports *[]string) (string, []*cssMapping) {
+	file := newCSSSourceFile(pipeline.rootPath, id, css, &processed.sources)
+	var inlined strings.Builder
+	var mappings []*cssMapping
+	writeSegment := func(from int, to int) {
+		segment := rewriteURLStatementsInCSS(css[from:to], id, pipeline.baseHref)
+		segment = cssSourceMappingURLPattern.ReplaceAllString(segment, "")
+		if len(stack) > 1 {
+			// only the first @charset counts, so those of inlined files are dropped
+			segment = cssCharsetPattern.ReplaceAllString(segment, "")
+		}
+		mappings = append(mappings, file.mapSegment(segment, from, inlined.Len())...)
+		inlined.WriteString(segment)
+	}
+
+	last := 0
+	for _, match := range cssImportPattern.FindAllStringSubmatchIndex(maskCSSComments(css), -1) {
+		writeSegment(last, match[0])
+		last = match[1]
+
+		uri := css[match[2]:match[3]]
+		if strings.HasPrefix(uri, "url(") {
+			uri = extractURI(uri)
+		} else {
+			uri = uri[1 : len(uri)-1]
+		}
+		media := strings.TrimSpace(css[match[4]:match[5]])
+
+		if strings.HasPrefix(uri, "//") || uriSchemePattern.MatchString(uri) {
+			*remoteImports = append(*remoteImports, css[match[0]:match[1]])
+			continue
+		}
+		imported, importedMappings := pipeline.inlineImport(id, uri, media, stack, processed, remoteImports)
+		mappings = append(mappings, shiftCSSMappings(importedMappings, inlined.Len())...)
+		inlined.WriteString(imported)
+	}
+	writeSegment(last, len(css))
+	return inlined.String(), mappings
+}
+
+// inlineImport reads and processes an @imported file, wrapping it in an @media block if the import had a media query
+func (pipeline *CSSPipeline) inlineImport(importerID string, uri string, media string, stack []string, processed *ProcessedCSS, remoteImports *[]string) (string, []*cssMapping) {
+	importedID := resolveCSSImport(importerID, uri)
+	for _, stackID := range stack {
+		if stackID == importedID {
+			logging.Warn("css-import-cycle", logging.Fields{"file": importerID, "import": importedID}, "Circular @import of %s in %s (ignored)", importedID, importerID)
+			return "", nil
+		}
+	}
+
+	// missing files are included too, so that the importer is refreshed when they're created
+	absoluteFilepath := filepath.Join(pipeline.rootPath, filepath.FromSlash(importedID))
+	processed.addInclude(absoluteFilepath)
+	contents, err := util.ReadContents(absoluteFilepath)
+	if err != nil {
+		logging.Warn("missing-import", logging.Fields{"file": importedID, "importer": importerID}, "Missing import: %s (in %s)", importedID, importerID)
+		return "", nil
+	}
+
+	inlined, mappings := pipeline.inline(importedID, contents, append(stack, importedID), processed, remoteImports)
+	if media != "" {
+		prefix := "@media " + media + " {\n"
+		return prefix + inlined + "\n}", shiftCSSMappings(mappings, len(prefix))
+	}
+	return inlined, mappings
+}
+
+func (processed *ProcessedCSS) addInclude(absoluteFilepath string) {
+	for _, include := range processed.Includes {
+		if include == absoluteFilepath {
+			return
+		}
+	}
+	processed.Includes = append(processed.Includes, absoluteFilepath)
+}
+
+// resolveCSSImport gets the root-relative ID of an @imported file
+func resolveCSSImport(importerID string, uri string) string {
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		uri = uri[:i]
+	}
+	if strings.HasPrefix(uri, "/") {
+		return path.Clean(uri[1:])
+	}
+	return path.Join(path.Dir(importerID), uri)
+}
+
+// maskCSSComments replaces comments with spaces, so that patterns don't match commented-out CSS (positions are kept)
+func maskCSSComments(css string) string {
+	masked := []byte(css)
+	for i := 0; i < len(masked); i++ {
+		switch c := masked[i]; {
+		case c == '"' || c == '\'':
+			i = endOfCSSString(css, i) - 1
+		case c == '/' && i+1 < len(masked) && masked[i+1] == '*':
+			end := endOfCSSComment(css, i)
+			for j := i; j < end; j++ {
+				masked[j] = ' '
+			}
+			i = end - 1
+		}
+	}
+	return string(masked)
+}
+
+// endOfCSSString gets the index just after the string starting at start (or the end of the CSS, if it's unterminated)
+func endOfCSSString(css string, start int) int {
+	quote := css[start]
+	for i := start + 1; i < len(css); i++ {
+		if css[i] == '\\' {
+			i++
+		} else if css[i] == quote || css[i] == '\n' {
+			return i + 1
+		}
+	}
+	return len(css)
+}
+
+// endOfCSSComment gets the index just after the comment starting at start (or the end of the CSS, if it's unterminated)
+func endOfCSSComment(css string, start int) int {
+	if end := strings.Index(css[start+2:], "*/"); end >= 0 {
+		return start + 2 + end + 2
+	}
+	return len(css)
+}
+
+// cssPrefixes lists the vendor prefixes still needed for properties, by the browsers swarm's users target
+var cssPrefixes = map[string][]string{
+	"appearance":           {"-webkit-", "-moz-"},
+	"backdrop-filter":      {"-webkit-"},
+	"box-decoration-break": {"-webkit-"},
+	"hyphens":              {"-webkit-", "-ms-"},
+	"mask":                 {"-webkit-"},
+	"mask-image":           {"-webkit-"},
+	"mask-position":        {"-webkit-"},
+	"mask-repeat":          {"-webkit-"},
+	"mask-size":            {"-webkit-"},
+	"tab-size":             {"-moz-"},
+	"text-size-adjust":     {"-webkit-", "-moz-", "-ms-"},
+	"user-select":          {"-webkit-", "-moz-", "-ms-"},
+}
+
+// cssDeclarationPattern matches a declaration, including the { or ; (and whitespace) before it
+var cssDeclarationPattern = regexp.MustCompile(`([{;]\s*)([a-zA-Z-]+)\s*:([^;{}]*)`)
+
+// autoprefixCSS adds vendor-prefixed copies of declarations before the standard ones, e.g. -webkit-user-select
+func autoprefixCSS(css string) *cssBuilder {
+	prefixed := &cssBuilder{}
+	last := 0
+	masked := maskCSSComments(css)
+	for _, match := range cssDeclarationPattern.FindAllStringSubmatchIndex(masked, -1) {
+		if match[1] < len(css) && css[match[1]] == '{' {
+			continue // a selector, e.g. a:hover {
+		}
+
+		property := strings.ToLower(css[match[4]:match[5]])
+		value := strings.TrimSpace(css[match[6]:match[7]])
+		indent := " "
+		if lead := masked[match[2]:match[3]]; strings.Contains(lead, "\n") {
+			indent = lead[strings.LastIndex(lead, "\n"):]
+		}
+
+		// copies already declared in the same block, e.g. by hand, aren't added again
+		declared := cssBlockDeclarations(masked, match[4])
+		var copies []string
+		for _, prefix := range cssPrefixes[property] {
+			if _, found := declared[prefix+property]; !found {
+				copies = append(copies, prefix+property+": "+value+";")
+			}
+		}
+		if property == "position" && value == "sticky" && !declared["position"]["-webkit-sticky"] {
+			copies = append(copies, "position: -webkit-sticky;")
+		}
+		if len(copies) == 0 {
+			continue
+		}
+
+		prefixed.copy(css, last, match[3])
+		for _, declaration := range copies {
+			prefixed.insert(declaration)
+			prefixed.insert(indent)
+		}
+		last = match[3]
+	}
+	prefixed.copy(css, last, len(css))
+	return prefixed
+}
+
+// cssBlockDeclarations gets the properties declared in the block around an offset in (comment-masked) CSS, with
+// their values
+func cssBlockDeclarations(masked string, offset int) map[string]map[string]bool {
+	start := strings.LastIndexAny(masked[:offset], "{}") + 1
+	end := len(masked)
+	if i := strings.IndexAny(masked[offset:], "{}"); i >= 0 {
+		end = offset + i
+	}
+
+	declared := map[string]map[string]bool{}
+	for _, declaration := range strings.Split(masked[start:end], ";") {
+		colon := strings.Index(declaration, ":")
+		if colon < 0 {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(declaration[:colon]))
+		if declared[property] == nil {
+			declared[property] = map[string]bool{}
+		}
+		declared[property][strings.TrimSpace(declaration[colon+1:])] = true
+	}
+	return declared
+}
+
+// minifyCSS removes comments and whitespace that isn't significant.  Whitespace is kept where it may separate
+// tokens, e.g. in selectors (a :hover) and calc() (1px + 2px)
+func minifyCSS(css string) *cssBuilder {
+	minified := &cssBuilder{output: make([]byte, 0, len(css))}
+	space := false
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+		switch {
+		case c == '/' && i+1 < len(css) && css[i+1] == '*':
+			i = endOfCSSComment(css, i) - 1
+			space = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			space = true
+		default:
+			last := minified.lastByte()
+			if space && last != 0 && !strings.ContainsRune("{};,:(", rune(last)) && !strings.ContainsRune("{};,)", rune(c)) {
+				minified.insert(" ")
+			}
+			space = false
+			if c == '}' && last == ';' {
+				minified.unwrite()
+			}
+			if c == '"' || c == '\'' {
+				end := endOfCSSString(css, i)
+				minified.copy(css, i, end)
+				i = end - 1
+				continue
+			}
+			minified.copy(css, i, i+1)
+		}
+	}
+	return minified
+}
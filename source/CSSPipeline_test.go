@@ -0,0 +1,131 @@
+package source
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSSPipelineInlinesImports(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	partialsPath := testutil.MakeSubdirectoryTree(workspacePath, "app/src/partials")
+	testutil.WriteTextFile(partialsPath, "vars.css", "@charset \"utf-8\";\n.logo { background: url(../img/logo.png); }")
+	testutil.WriteTextFile(partialsPath, "print.css", `@import url("https://fonts.example.com/a.css"); .nav { display: none; }`)
+
+	css := `@import "partials/vars.css";
+/* @import "partials/commented.css"; */
+@import url(partials/print.css) print;
+.main { background: url('img/main.png'); }`
+
+	pipeline := NewCSSPipeline(workspacePath, "/app/", nil)
+	processed := pipeline.Process("app/src/main.css", css)
+	expected := `@import url("https://fonts.example.com/a.css");
+
+.logo { background: url(src/img/logo.png); }
+/* @import "partials/commented.css"; */
+@media print {
+ .nav { display: none; }
+}
+.main { background: url('src/img/main.png'); }`
+	assert.Equal(t, expected, processed.CSS)
+	assert.Equal(t, []string{filepath.Join(partialsPath, "vars.css"), filepath.Join(partialsPath, "print.css")}, processed.Includes)
+}
+
+func TestCSSPipelineMissingAndCircularImports(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	appPath := testutil.MakeSubdirectoryTree(workspacePath, "app")
+	testutil.WriteTextFile(appPath, "a.css", `@import "/app/b.css"; .a {}`)
+	testutil.WriteTextFile(appPath, "b.css", `@import "a.css"; .b {}`)
+
+	pipeline := NewCSSPipeline(workspacePath, "app", nil)
+	processed := pipeline.Process("app/main.css", `@import "a.css"; @import "missing.css"; .main {}`)
+	assert.Equal(t, " .b {} .a {}  .main {}", processed.CSS)
+
+	// the missing file is included, so that creating it refreshes the file
+	assert.Equal(t, []string{filepath.Join(appPath, "a.css"), filepath.Join(appPath, "b.css"), filepath.Join(appPath, "missing.css")}, processed.Includes)
+}
+
+func TestCSSPipelineOptions(t *testing.T) {
+	pipeline := NewCSSPipeline("", "", &config.CSSConfig{Minify: true, Autoprefix: true})
+	processed := pipeline.Process("main.css", ".a {\n  user-select: none;\n}\n")
+	assert.Equal(t, ".a{-webkit-user-select:none;-moz-user-select:none;-ms-user-select:none;user-select:none}", processed.CSS)
+}
+
+func TestAutoprefixCSS(t *testing.T) {
+	cases := map[string]struct {
+		css      string
+		expected string
+	}{
+		"indented": {
+			".a {\n  color: red;\n  appearance: none;\n}",
+			".a {\n  color: red;\n  -webkit-appearance: none;\n  -moz-appearance: none;\n  appearance: none;\n}",
+		},
+		"one line": {
+			".a { hyphens: auto }",
+			".a { -webkit-hyphens: auto; -ms-hyphens: auto; hyphens: auto }",
+		},
+		"sticky": {
+			".a { position: sticky; }",
+			".a { position: -webkit-sticky; position: sticky; }",
+		},
+		"selectors are untouched": {
+			"@media print { user-select:hover { color: red } }",
+			"@media print { user-select:hover { color: red } }",
+		},
+		"comments are untouched": {
+			".a { /* user-select: none; */ }",
+			".a { /* user-select: none; */ }",
+		},
+		"already prefixed": {
+			".a {\n  -webkit-user-select: none;\n  user-select: none;\n}",
+			".a {\n  -webkit-user-select: none;\n  -moz-user-select: none;\n  -ms-user-select: none;\n  user-select: none;\n}",
+		},
+		"already sticky": {
+			".a { position: -webkit-sticky; position: sticky; }",
+			".a { position: -webkit-sticky; position: sticky; }",
+		},
+		"prefixed in another block": {
+			".a { -webkit-hyphens: auto } .b { hyphens: auto }",
+			".a { -webkit-hyphens: auto } .b { -webkit-hyphens: auto; -ms-hyphens: auto; hyphens: auto }",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, autoprefixCSS(tc.css).String())
+		})
+	}
+}
+
+func TestMinifyCSS(t *testing.T) {
+	cases := map[string]struct {
+		css      string
+		expected string
+	}{
+		"whitespace": {
+			".a ,\n.b > .c {\n  color : red ;\n  margin: 0 auto;\n}\n",
+			".a,.b > .c{color :red;margin:0 auto}",
+		},
+		"comments": {
+			"/* header */\n.a { /* inner */ color: red; }",
+			".a{color:red}",
+		},
+		"strings": {
+			`.a::before { content: "  /* not a comment */  "; }`,
+			`.a::before{content:"  /* not a comment */  "}`,
+		},
+		"significant whitespace": {
+			".a :hover { width: calc(100% - 2px); }\n@media screen and (max-width: 10px) { .b { color: red } }",
+			".a :hover{width:calc(100% - 2px)}@media screen and (max-width:10px){.b{color:red}}",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, minifyCSS(tc.css).String())
+		})
+	}
+}
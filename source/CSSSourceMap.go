@@ -0,0 +1,365 @@
+package source
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mrcrowl/swarm/logging"
+	"github.com/mrcrowl/swarm/util"
+)
+
+// cssMapping maps a byte offset in processed CSS to a (0-based) position in one of its sources
+type cssMapping struct {
+	offset       int
+	source       int
+	sourceLine   int
+	sourceColumn int
+}
+
+// cssSources are the sources of processed CSS, i.e. the files it was inlined from, or their Sass sources
+type cssSources struct {
+	ids      []string // root-relative
+	contents []*string
+	index    map[string]int
+}
+
+func (sources *cssSources) add(id string, contents *string) int {
+	if i, found := sources.index[id]; found {
+		return i
+	}
+	if sources.index == nil {
+		sources.index = map[string]int{}
+	}
+	sources.index[id] = len(sources.ids)
+	sources.ids = append(sources.ids, id)
+	sources.contents = append(sources.contents, contents)
+	return sources.index[id]
+}
+
+// cssSourceFile is a file being inlined by a CSSPipeline
+type cssSourceFile struct {
+	id       string
+	css      string
+	source   int             // the index of the file in the cssSources
+	inputMap [][]*cssMapping // the mappings of the file's own source map (e.g. from Sass) by line, or nil
+}
+
+var cssSourceMappingURLPattern = regexp.MustCompile(`/\*[#@]\s*source(?:Mapping)?URL=\S*\s*\*/`)
+
+// newCSSSourceFile adds a file to the sources, or if it has a source map (e.g. a .css.map file produced by Sass),
+// the sources of that
+func newCSSSourceFile(rootPath string, id string, css string, sources *cssSources) *cssSourceFile {
+	file := &cssSourceFile{id: id, css: css}
+	for _, match := range cssSourceMappingURLPattern.FindAllString(css, -1) {
+		if strings.Contains(match, "sourceMappingURL=") {
+			url := strings.TrimSpace(strings.TrimSuffix(match[strings.Index(match, "=")+1:], "*/"))
+			file.inputMap = loadCSSInputSourceMap(rootPath, id, url, sources)
+		}
+	}
+	if file.inputMap == nil {
+		file.source = sources.add(id, &css)
+	}
+	return file
+}
+
+// loadCSSInputSourceMap reads the source map of a CSS file, adding its sources.  Returns nil if it can't be read
+func loadCSSInputSourceMap(rootPath string, id string, url string, sources *cssSources) [][]*cssMapping {
+	var mapJSON string
+	mapID := path.Join(path.Dir(id), url)
+	if strings.HasPrefix(url, "data:") {
+		encoded := url[strings.Index(url, ",")+1:]
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			logging.Warn("source-map", logging.Fields{"file": id, "error": err}, "Failed to decode the source map of %s", id)
+			return nil
+		}
+		mapJSON, mapID = string(decoded), id
+	} else if !isAbsoluteURI(url) {
+		contents, err := util.ReadContents(filepath.Join(rootPath, filepath.FromSlash(mapID)))
+		if err != nil {
+			logging.Warn("source-map", logging.Fields{"file": mapID, "error": err}, "Failed to load source map: %s", mapID)
+			return nil
+		}
+		mapJSON = contents
+	} else {
+		return nil
+	}
+
+	config, err := ParseSourceMapConfig(mapJSON)
+	if err == nil {
+		var lines [][]*cssMapping
+		if lines, err = parseCSSMappings(config.Mappings); err == nil {
+			remapCSSSources(rootPath, path.Dir(mapID), config, lines, sources)
+			return lines
+		}
+	}
+	logging.Warn("source-map", logging.Fields{"file": mapID, "error": err}, "Failed to parse source map: %s", mapID)
+	return nil
+}
+
+// parseCSSMappings decodes the mappings of a source map, by generated line (offset is the generated column)
+func parseCSSMappings(mappings string) ([][]*cssMapping, error) {
+	var lines [][]*cssMapping
+	previous := cssMapping{}
+	for _, lineMappings := range strings.Split(mappings, ";") {
+		var line []*cssMapping
+		previous.offset = 0
+		for _, segment := range strings.Split(lineMappings, ",") {
+			values, err := util.DecodeVLQ(segment)
+			if err != nil {
+				return nil, err
+			}
+			if len(values) > 0 {
+				previous.offset += values[0]
+			}
+			if len(values) < 4 {
+				continue // unmapped
+			}
+			previous.source += values[1]
+			previous.sourceLine += values[2]
+			previous.sourceColumn += values[3]
+			mapping := previous
+			line = append(line, &mapping)
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// remapCSSSources adds the sources of a source map, and changes its mappings to use their indexes
+func remapCSSSources(rootPath string, mapDir string, config *MapConfig, lines [][]*cssMapping, sources *cssSources) {
+	indexes := make([]int, len(config.Sources))
+	for i, sourceURL := range config.Sources {
+		id := resolveCSSSourceURL(mapDir, config.SourceRoot, sourceURL)
+
+		var contents *string
+		if i < len(config.SourcesContent) && config.SourcesContent[i] != nil {
+			contents = config.SourcesContent[i]
+		} else if isExternalCSSSource(id) {
+			// e.g. file:// or webpack:// sources, which aren't served from the workspace
+		} else if fileContents, err := util.ReadContents(filepath.Join(rootPath, filepath.FromSlash(id))); err == nil {
+			contents = &fileContents
+		}
+		indexes[i] = sources.add(id, contents)
+	}
+
+	for _, line := range lines {
+		for _, mapping := range line {
+			if mapping.source >= 0 && mapping.source < len(indexes) {
+				mapping.source = indexes[mapping.source]
+			}
+		}
+	}
+}
+
+// resolveCSSSourceURL gets the ID of a source in a source map: root-relative, unless its URL (or the sourceRoot) is an
+// absolute URL, e.g. file:///home/dev/app/main.scss, which is kept as it is
+func resolveCSSSourceURL(mapDir string, sourceRoot string, sourceURL string) string {
+	switch {
+	case isExternalCSSSource(sourceURL):
+		return sourceURL
+	case strings.HasPrefix(sourceURL, "/"):
+		return path.Clean(sourceURL[1:])
+	case isExternalCSSSource(sourceRoot):
+		return strings.TrimSuffix(sourceRoot, "/") + "/" + sourceURL
+	case strings.HasPrefix(sourceRoot, "/"):
+		return path.Join(sourceRoot[1:], sourceURL)
+	default:
+		return path.Join(mapDir, sourceRoot, sourceURL)
+	}
+}
+
+// isExternalCSSSource tests whether a source's ID is an absolute URL, rather than a root-relative path
+func isExternalCSSSource(id string) bool {
+	return strings.HasPrefix(id, "//") || uriSchemePattern.MatchString(id)
+}
+
+// mapSegment creates mappings for the lines of a segment of the file's CSS, from its byte offset in the file to
+// where it was written.  The segment may have been rewritten, but its lines must still match
+func (file *cssSourceFile) mapSegment(segment string, fileOffset int, outputOffset int) []*cssMapping {
+	before := file.css[:fileOffset]
+	line := strings.Count(before, "\n")
+	column := len(before) - (strings.LastIndex(before, "\n") + 1)
+
+	var mappings []*cssMapping
+	for lineStart := 0; lineStart < len(segment); line, column = line+1, 0 {
+		lineEnd := len(segment)
+		if i := strings.IndexByte(segment[lineStart:], '\n'); i >= 0 {
+			lineEnd = lineStart + i
+		}
+		mappings = append(mappings, file.mapLine(outputOffset+lineStart, lineEnd-lineStart, line, column)...)
+		lineStart = lineEnd + 1
+	}
+	return mappings
+}
+
+// mapLine creates mappings for (part of) a line of the file's CSS, of a given length, starting at a line and column
+func (file *cssSourceFile) mapLine(outputOffset int, length int, line int, column int) []*cssMapping {
+	if length == 0 {
+		return nil
+	}
+	if file.inputMap == nil {
+		return []*cssMapping{{outputOffset, file.source, line, column}}
+	}
+
+	// the file was generated, e.g. by Sass, so map through its own source map
+	if line >= len(file.inputMap) {
+		return nil
+	}
+	var mappings []*cssMapping
+	for _, inputMapping := range file.inputMap[line] {
+		if inputMapping.offset >= column && inputMapping.offset-column < length {
+			mapping := *inputMapping
+			mapping.offset = outputOffset + inputMapping.offset - column
+			mappings = append(mappings, &mapping)
+		}
+	}
+	return mappings
+}
+
+// shiftCSSMappings moves mappings along, e.g. after text is inserted before them
+func shiftCSSMappings(mappings []*cssMapping, delta int) []*cssMapping {
+	for _, mapping := range mappings {
+		mapping.offset += delta
+	}
+	return mappings
+}
+
+// cssBuilder builds transformed CSS, remembering where runs of the original were copied to, so that offsets in the
+// original can be translated
+type cssBuilder struct {
+	output []byte
+	runs   []cssRun
+}
+
+type cssRun struct {
+	from   int // offset in the original
+	to     int // offset in the output
+	length int
+}
+
+// copy copies css[from:to] to the output
+func (builder *cssBuilder) copy(css string, from int, to int) {
+	if from == to {
+		return
+	}
+	if n := len(builder.runs); n > 0 {
+		last := &builder.runs[n-1]
+		if last.from+last.length == from && last.to+last.length == len(builder.output) {
+			last.length += to - from
+			builder.output = append(builder.output, css[from:to]...)
+			return
+		}
+	}
+	builder.runs = append(builder.runs, cssRun{from, len(builder.output), to - from})
+	builder.output = append(builder.output, css[from:to]...)
+}
+
+// insert adds text to the output, which isn't from the original
+func (builder *cssBuilder) insert(s string) {
+	builder.output = append(builder.output, s...)
+}
+
+// unwrite removes the last byte of the output
+func (builder *cssBuilder) unwrite() {
+	builder.output = builder.output[:len(builder.output)-1]
+	if n := len(builder.runs); n > 0 {
+		last := &builder.runs[n-1]
+		if last.to+last.length > len(builder.output) {
+			last.length--
+		}
+	}
+}
+
+func (builder *cssBuilder) lastByte() byte {
+	if len(builder.output) == 0 {
+		return 0
+	}
+	return builder.output[len(builder.output)-1]
+}
+
+func (builder *cssBuilder) String() string {
+	return string(builder.output)
+}
+
+// translate moves mappings from offsets in the original to the output.  Offsets in text that was removed move to
+// wherever the following text was copied
+func (builder *cssBuilder) translate(mappings []*cssMapping) []*cssMapping {
+	for _, mapping := range mappings {
+		i := sort.Search(len(builder.runs), func(i int) bool {
+			return builder.runs[i].from+builder.runs[i].length > mapping.offset
+		})
+		switch {
+		case i == len(builder.runs):
+			mapping.offset = len(builder.output)
+		case mapping.offset < builder.runs[i].from:
+			mapping.offset = builder.runs[i].to
+		default:
+			mapping.offset = builder.runs[i].to + mapping.offset - builder.runs[i].from
+		}
+	}
+	return mappings
+}
+
+// cssSourceMapJSON generates a source map for processed CSS, which includes the contents of its sources
+func cssSourceMapJSON(file string, css string, mappings []*cssMapping, sources *cssSources) string {
+	sort.SliceStable(mappings, func(i, j int) bool { return mappings[i].offset < mappings[j].offset })
+
+	var encoded strings.Builder
+	previous := cssMapping{}
+	lineStart, lastOffset, firstOnLine := 0, -1, true
+	for _, mapping := range mappings {
+		if mapping.offset == lastOffset {
+			continue
+		}
+		lastOffset = mapping.offset
+		for {
+			next := strings.IndexByte(css[lineStart:], '\n')
+			if next < 0 || lineStart+next >= mapping.offset {
+				break
+			}
+			encoded.WriteByte(';')
+			lineStart += next + 1
+			previous.offset = 0
+			firstOnLine = true
+		}
+		if !firstOnLine {
+			encoded.WriteByte(',')
+		}
+		firstOnLine = false
+		encodeCSSMapping(&encoded, mapping.offset-lineStart, mapping, &previous)
+	}
+
+	sourceURLs := make([]string, len(sources.ids))
+	for i, id := range sources.ids {
+		if isExternalCSSSource(id) {
+			sourceURLs[i] = id
+		} else {
+			sourceURLs[i] = "/" + id
+		}
+	}
+	sourceMap, _ := json.Marshal(&MapConfig{
+		Version:        3,
+		File:           path.Base(file),
+		Sources:        sourceURLs,
+		SourcesContent: sources.contents,
+		Names:          []string{},
+		Mappings:       encoded.String(),
+	})
+	return string(sourceMap)
+}
+
+func encodeCSSMapping(encoded *strings.Builder, column int, mapping *cssMapping, previous *cssMapping) {
+	encoded.WriteString(util.EncodeVLQ([]int{
+		column - previous.offset,
+		mapping.source - previous.source,
+		mapping.sourceLine - previous.sourceLine,
+		mapping.sourceColumn - previous.sourceColumn,
+	}))
+	*previous = cssMapping{column, mapping.source, mapping.sourceLine, mapping.sourceColumn}
+}
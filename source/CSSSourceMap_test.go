@@ -0,0 +1,125 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// describeCSSSourceMap lists the mappings of a source map as "line:column -> source:line:column"
+func describeCSSSourceMap(t *testing.T, sourceMapJSON string) (*MapConfig, []string) {
+	sourceMap, err := ParseSourceMapConfig(sourceMapJSON)
+	assert.Nil(t, err)
+	lines, err := parseCSSMappings(sourceMap.Mappings)
+	assert.Nil(t, err)
+
+	var described []string
+	for line, mappings := range lines {
+		for _, mapping := range mappings {
+			described = append(described, fmt.Sprintf("%d:%d -> %s:%d:%d", line, mapping.offset, sourceMap.Sources[mapping.source], mapping.sourceLine, mapping.sourceColumn))
+		}
+	}
+	return sourceMap, described
+}
+
+func TestCSSSourceMap(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	appPath := testutil.MakeSubdirectoryTree(workspacePath, "app")
+	partial := ".partial {}\n.b { background: url(b.png) }"
+	testutil.WriteTextFile(appPath, "partial.css", partial)
+
+	main := "@import \"partial.css\";\n.main { color: red; }"
+	processed := NewCSSPipeline(workspacePath, "app", nil).Process("app/main.css", main)
+	assert.Equal(t, ".partial {}\n.b { background: url(b.png) }\n.main { color: red; }", processed.CSS)
+
+	sourceMap, mappings := describeCSSSourceMap(t, processed.SourceMap("app/main.css"))
+	assert.Equal(t, "main.css", sourceMap.File)
+	assert.Equal(t, []string{"/app/main.css", "/app/partial.css"}, sourceMap.Sources)
+	assert.Equal(t, []*string{&main, &partial}, sourceMap.SourcesContent)
+	assert.Equal(t, []string{
+		"0:0 -> /app/partial.css:0:0",
+		"1:0 -> /app/partial.css:1:0",
+		"2:0 -> /app/main.css:1:0",
+	}, mappings)
+}
+
+func TestCSSSourceMapMinified(t *testing.T) {
+	css := ".a {\n  color: red;\n}\n.b { color: blue; }"
+	pipeline := NewCSSPipeline("", "", &config.CSSConfig{Minify: true})
+	processed := pipeline.Process("main.css", css)
+	assert.Equal(t, ".a{color:red}.b{color:blue}", processed.CSS)
+
+	_, mappings := describeCSSSourceMap(t, processed.SourceMap("main.css"))
+	assert.Equal(t, []string{
+		"0:0 -> /main.css:0:0",
+		"0:3 -> /main.css:1:0",
+		"0:12 -> /main.css:2:0",
+		"0:13 -> /main.css:3:0",
+	}, mappings)
+}
+
+func TestCSSSourceMapFromSass(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	appPath := testutil.MakeSubdirectoryTree(workspacePath, "app")
+	stylesPath := testutil.MakeSubdirectoryTree(workspacePath, "app/styles")
+	scss := ".a {\n  .b { color: red; }\n}"
+	testutil.WriteTextFile(stylesPath, "partial.scss", scss)
+	testutil.WriteTextFile(appPath, "partial.css", ".a .b {\n  color: red; }\n\n/*# sourceMappingURL=partial.css.map */")
+	testutil.WriteTextFile(appPath, "partial.css.map", `{"version":3,"sources":["styles/partial.scss"],"names":[],"mappings":"AAAA;EACE"}`)
+
+	processed := NewCSSPipeline(workspacePath, "app", nil).Process("app/main.css", `@import "partial.css";`)
+	assert.False(t, strings.Contains(processed.CSS, "sourceMappingURL"))
+
+	sourceMap, mappings := describeCSSSourceMap(t, processed.SourceMap("app/main.css"))
+	assert.Equal(t, []string{"/app/main.css", "/app/styles/partial.scss"}, sourceMap.Sources)
+	assert.Equal(t, &scss, sourceMap.SourcesContent[1])
+	assert.Equal(t, []string{
+		"0:0 -> /app/styles/partial.scss:0:0",
+		"1:2 -> /app/styles/partial.scss:1:2",
+	}, mappings)
+}
+
+func TestParseCSSFileContentsSourceMapComments(t *testing.T) {
+	contents, err := ParseCSSFileContents("app/main.css", ".main {}", NewCSSPipeline("", "app", nil))
+	assert.Nil(t, err)
+	lines := strings.Split(contents.CSS(), "\n")
+	assert.Equal(t, ".main {}", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "/*# sourceMappingURL=data:application/json;charset=utf-8;base64,"), lines[1])
+	assert.Equal(t, "/*# sourceURL=/app/main.css */", lines[2])
+}
+
+func TestCSSSourceMapSourceURLs(t *testing.T) {
+	cases := map[string]struct {
+		sources    string
+		sourceRoot string
+		expected   string
+	}{
+		"relative":             {`["styles/a.scss"]`, "", "/app/styles/a.scss"},
+		"root-relative":        {`["/lib/a.scss"]`, "", "/lib/a.scss"},
+		"file url":             {`["file:///home/dev/app/a.scss"]`, "", "file:///home/dev/app/a.scss"},
+		"webpack url":          {`["webpack:///./src/a.scss"]`, "", "webpack:///./src/a.scss"},
+		"relative source root": {`["a.scss"]`, "styles", "/app/styles/a.scss"},
+		"rooted source root":   {`["a.scss"]`, "/lib/", "/lib/a.scss"},
+		"absolute source root": {`["a.scss"]`, "file:///home/dev/app/", "file:///home/dev/app/a.scss"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			workspacePath := testutil.CreateTempDir()
+			defer testutil.RemoveTempDir(workspacePath)
+			appPath := testutil.MakeSubdirectoryTree(workspacePath, "app")
+			testutil.WriteTextFile(appPath, "partial.css", ".a {}\n/*# sourceMappingURL=partial.css.map */")
+			testutil.WriteTextFile(appPath, "partial.css.map", fmt.Sprintf(`{"version":3,"sourceRoot":%q,"sources":%s,"names":[],"mappings":"AAAA"}`, tc.sourceRoot, tc.sources))
+
+			processed := NewCSSPipeline(workspacePath, "app", nil).Process("app/main.css", `@import "partial.css";`)
+			sourceMap, mappings := describeCSSSourceMap(t, processed.SourceMap("app/main.css"))
+			assert.Equal(t, []string{"/app/main.css", tc.expected}, sourceMap.Sources)
+			assert.Equal(t, []string{"0:0 -> " + tc.expected + ":0:0"}, mappings)
+		})
+	}
+}
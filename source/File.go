@@ -4,16 +4,21 @@ import (
 	"path/filepath"
 	"strings"
 	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/logging"
 	"github.com/mrcrowl/swarm/util"
+	"sync"
 )
 
 // File represents a single file containing source code
 type File struct {
-	ID        string // also happens to be the root-relative url for this file
-	Filepath  string
-	ext       string
-	contents  FileContents
-	sourceMap *Mapping
+	ID         string // also happens to be the root-relative url for this file
+	Filepath   string
+	ext        string
+	contents   FileContents
+	sourceMaps map[string]*Mapping // keyed by base href and entry point, since a File may be shared between builds
+	loadedFor  string              // the base href and CSS options that CSS contents were prepared for
+	includes   []string            // the absolute filepaths of files the contents inline (kept after unloading)
+	mutex      sync.Mutex          // guards the fields above, since a File may be shared between builds
 }
 
 // newFile creates a new SourceFile
@@ -46,18 +51,57 @@ func (file *File) Ext() string {
 
 // Loaded gets whether a file's contents are loaded
 func (file *File) Loaded() bool {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
 	return file.contents != nil
 }
 
-// EnsureLoaded ensures that the Load method has been called for this File instance
+// EnsureLoaded ensures that the Load method has been called for this File instance.  CSS is prepared for a
+// particular base href, so it's loaded again if it was prepared for another build
 func (file *File) EnsureLoaded(runtimeConfig *config.RuntimeConfig) {
-	if !file.Loaded() {
-		file.LoadContents(runtimeConfig)
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	if file.contents == nil || file.loadedFor != file.contentsKey(runtimeConfig) {
+		file.loadContents(runtimeConfig)
 	}
 }
 
+// contentsKey describes the parts of a RuntimeConfig that a file's contents depend on
+func (file *File) contentsKey(runtimeConfig *config.RuntimeConfig) string {
+	if file.ext != ".css" || runtimeConfig == nil {
+		return ""
+	}
+	return runtimeConfig.BaseHref + "|" + runtimeConfig.CSSOptions().Key()
+}
+
+// Includes tests whether a file's contents inline another file, by its absolute filepath, e.g. a CSS @import
+// The last loaded contents are used, so that the file is still known to include it after being unloaded
+func (file *File) Includes(absoluteFilepath string) bool {
+	absoluteFilepath = filepath.Clean(absoluteFilepath)
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	for _, include := range file.includes {
+		if include == absoluteFilepath {
+			return true
+		}
+	}
+	return false
+}
+
+// rootPath gets the workspace root path that the file's ID is relative to
+func (file *File) rootPath() string {
+	return strings.TrimSuffix(file.Filepath, filepath.FromSlash(file.ID))
+}
+
 // LoadContents loads a file's contents from disk and prepares them for bundling
 func (file *File) LoadContents(runtimeConfig *config.RuntimeConfig) {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	file.loadContents(runtimeConfig)
+}
+
+func (file *File) loadContents(runtimeConfig *config.RuntimeConfig) {
+	file.loadedFor = file.contentsKey(runtimeConfig)
 	contents, err := util.ReadContents(file.Filepath)
 	if err != nil {
 		file.contents = &FailedFileContents{}
@@ -65,19 +109,32 @@ func (file *File) LoadContents(runtimeConfig *config.RuntimeConfig) {
 	}
 
 	var baseHref string
+	var cssOptions *config.CSSConfig
 	if runtimeConfig != nil {
 		baseHref = runtimeConfig.BaseHref
+		cssOptions = runtimeConfig.CSSOptions()
 	}
 
 	switch file.ext {
 	case ".js":
 		file.contents, err = ParseJSFileContents(file.ID, contents)
 	case ".css":
-		file.contents, err = ParseCSSFileContents(file.ID, contents, baseHref)
+		pipeline := NewCSSPipeline(file.rootPath(), baseHref, cssOptions)
+		file.contents, err = ParseCSSFileContents(file.ID, contents, pipeline)
+	case ".json":
+		file.contents, err = ParseJSONFileContents(file.ID, contents)
 	default:
 		file.contents, err = ParseStringFileContents(file.ID, contents)
 	}
 
+	if cssContents, ok := file.contents.(*CSSFileContents); ok {
+		file.includes = cssContents.Includes()
+	}
+
+	if jsonErr, ok := err.(*JSONSyntaxError); ok {
+		logging.Error("invalid-json", logging.Fields{"file": file.ID, "line": jsonErr.Line, "column": jsonErr.Column, "error": jsonErr.Err}, "%s", jsonErr)
+	}
+
 	if file.contents == nil {
 		panic("ah!")
 	}
@@ -85,35 +142,52 @@ func (file *File) LoadContents(runtimeConfig *config.RuntimeConfig) {
 
 // UnloadContents clears a file's contents
 func (file *File) UnloadContents() {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
 	file.contents = nil
-	file.sourceMap = nil
+	file.sourceMaps = nil
 }
 
 // SourceMap gets a Mapping that wraps the sourceMappingURL found within the file's contents.
 // This only returns true if the file's contents have been loaded
 func (file *File) SourceMap(runtimeConfig *config.RuntimeConfig, entryPointRootRelativePath string) *Mapping {
-	if file.sourceMap == nil {
-		if file.contents == nil {
-			return nil
-		}
+	var baseHref string
+	if runtimeConfig != nil {
+		baseHref = runtimeConfig.BaseHref
+	}
 
-		sourceMappingURL := file.contents.SourceMappingURL()
-		if sourceMappingURL == "" {
-			return nil
-		}
-		relativePath := file.PathRelativeTo(runtimeConfig, entryPointRootRelativePath)
-		absoluteFilepath := filepath.Join(filepath.Dir(file.Filepath), sourceMappingURL)
-		file.sourceMap = NewMapping(sourceMappingURL, relativePath, absoluteFilepath)
+	key := baseHref + "|" + entryPointRootRelativePath
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	if sourceMap, ok := file.sourceMaps[key]; ok {
+		return sourceMap
+	}
+
+	if file.contents == nil {
+		return nil
+	}
+
+	sourceMappingURL := file.contents.SourceMappingURL()
+	if sourceMappingURL == "" {
+		return nil
+	}
+	relativePath := file.PathRelativeTo(runtimeConfig, entryPointRootRelativePath)
+	absoluteFilepath := filepath.Join(filepath.Dir(file.Filepath), sourceMappingURL)
+	if file.sourceMaps == nil {
+		file.sourceMaps = map[string]*Mapping{}
 	}
-	return file.sourceMap
+	file.sourceMaps[key] = NewMapping(sourceMappingURL, relativePath, absoluteFilepath)
+	return file.sourceMaps[key]
 }
 
 // BundleBody returns a list of lines from the body ready to include in a SystemJSBundle
 func (file *File) BundleBody() []string {
-	return file.contents.BundleLines()
+	return file.RawContents().BundleLines()
 }
 
 // RawContents provides access to the underlying file contents object
 func (file *File) RawContents() FileContents {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
 	return file.contents
 }
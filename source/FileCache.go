@@ -0,0 +1,68 @@
+package source
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/mrcrowl/swarm/config"
+)
+
+// FileCache shares Files between FileSets (e.g. for each variant of a build), so that a file is only loaded and parsed once.
+// CSS files are only shared by builds with the same base href and CSS options, since their contents depend on them
+type FileCache struct {
+	files map[fileCacheKey]*File
+	mutex sync.Mutex
+}
+
+type fileCacheKey struct {
+	id          string
+	filepath    string
+	contentsKey string
+}
+
+// NewFileCache creates an empty FileCache
+func NewFileCache() *FileCache {
+	return &FileCache{
+		files: make(map[fileCacheKey]*File),
+	}
+}
+
+// intern returns the cached File with the same ID and path as file (and contents key, for CSS), or else caches file
+func (cache *FileCache) intern(file *File, runtimeConfig *config.RuntimeConfig) *File {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	key := fileCacheKey{file.ID, filepath.Clean(file.Filepath), file.contentsKey(runtimeConfig)}
+	if cached, ok := cache.files[key]; ok {
+		return cached
+	}
+	cache.files[key] = file
+	return file
+}
+
+// Clear unloads the contents of every cached File, e.g. so that a rebuild re-reads everything
+func (cache *FileCache) Clear() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	for _, file := range cache.files {
+		file.UnloadContents()
+	}
+}
+
+// Invalidate unloads the contents of any cached Files for an absolute filepath, e.g. after the file has changed, along
+// with any that include it (e.g. CSS files which @import it)
+func (cache *FileCache) Invalidate(absoluteFilepath string) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	invalidated := false
+	absoluteFilepath = filepath.Clean(absoluteFilepath)
+	for key, file := range cache.files {
+		if key.filepath == absoluteFilepath || file.Includes(absoluteFilepath) {
+			file.UnloadContents()
+			invalidated = true
+		}
+	}
+	return invalidated
+}
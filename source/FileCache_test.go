@@ -0,0 +1,56 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkspaceWithFileCache(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	abcdFilepath := testutil.WriteTextFile(temppath, "abcd.js", `System.register([], function (exports_1, context_1) {`)
+
+	cache := NewFileCache()
+	ws := NewWorkspace(temppath).WithFileCache(cache)
+	assert.True(t, ws.CachesFiles())
+	assert.False(t, NewWorkspace(temppath).CachesFiles())
+
+	first, _ := ws.ReadSourceFile(NewImport("abcd"))
+	second, _ := ws.ReadSourceFile(NewImport("abcd"))
+	assert.True(t, first == second)
+
+	withExt, _ := ws.ReadSourceFile(NewImport("abcd.js"))
+	assert.False(t, first == withExt)
+
+	first.EnsureLoaded(nil)
+	withExt.EnsureLoaded(nil)
+	assert.True(t, cache.Invalidate(abcdFilepath))
+	assert.False(t, first.Loaded())
+	assert.False(t, withExt.Loaded())
+	assert.False(t, cache.Invalidate(abcdFilepath+".map"))
+}
+
+func TestFileCacheKeepsCSSApartByBuild(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	testutil.WriteTextFile(temppath, "style.css", "a { background: url(img.png); }")
+	testutil.WriteTextFile(temppath, "abcd.js", `System.register([], function (exports_1, context_1) {`)
+
+	ws := NewWorkspace(temppath).WithFileCache(NewFileCache())
+	app := ws.ForBuild(config.NewRuntimeConfig("", "app"))
+	appVariant := ws.ForBuild(config.NewRuntimeConfig("", "app"))
+	admin := ws.ForBuild(config.NewRuntimeConfig("", "admin/panel"))
+
+	appCSS, _ := app.ReadSourceFile(NewImport("style.css"))
+	appVariantCSS, _ := appVariant.ReadSourceFile(NewImport("style.css"))
+	adminCSS, _ := admin.ReadSourceFile(NewImport("style.css"))
+	assert.True(t, appCSS == appVariantCSS)
+	assert.False(t, appCSS == adminCSS)
+
+	appJS, _ := app.ReadSourceFile(NewImport("abcd"))
+	adminJS, _ := admin.ReadSourceFile(NewImport("abcd"))
+	assert.True(t, appJS == adminJS, "only CSS depends on the build")
+}
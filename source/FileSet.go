@@ -1,7 +1,9 @@
 package source
 
 import (
-	"fmt"
+	"path/filepath"
+
+	"github.com/mrcrowl/swarm/logging"
 )
 
 // FileSet is
@@ -9,6 +11,7 @@ type FileSet struct {
 	index        map[string]*File
 	links        map[string][]string
 	reverseLinks map[string][]string
+	unresolved   map[string][]string // dependency ID --> IDs of files that import it, but it isn't in the set
 	workspace    *Workspace
 	dirty        bool
 }
@@ -19,6 +22,7 @@ func NewEmptyFileSet(workspace *Workspace) *FileSet {
 		index:        make(map[string]*File),
 		links:        make(map[string][]string),
 		reverseLinks: make(map[string][]string),
+		unresolved:   make(map[string][]string),
 		workspace:    workspace,
 		dirty:        true,
 	}
@@ -37,7 +41,7 @@ func (fs *FileSet) Ingest(imports []*Import, links []*DependencyLink, replace bo
 	for _, imp := range imports {
 		file, err := fs.workspace.ReadSourceFile(imp)
 		if err != nil {
-			fmt.Printf("Could not read '%s'\n", imp.Path())
+			logging.Warn("unreadable", logging.Fields{"file": imp.Path(), "error": err}, "Could not read '%s'", imp.Path())
 			continue
 		}
 
@@ -91,6 +95,7 @@ func (fs *FileSet) Add(file *File) bool {
 	}
 
 	fs.index[file.ID] = file
+	delete(fs.unresolved, file.ID)
 	return true
 }
 
@@ -103,29 +108,138 @@ func (fs *FileSet) Replace(file *File) {
 	// }
 
 	fs.index[file.ID] = file
+	delete(fs.unresolved, file.ID)
+}
+
+// Remove removes a File from a FileSet, along with its links.
+// Files that imported it will remember it as an unresolved dependency
+func (fs *FileSet) Remove(id string) bool {
+	if !fs.Contains(id) {
+		return false
+	}
+
+	delete(fs.index, id)
+	fs.removeLinks(id)
+
+	for _, dependentID := range fs.reverseLinks[id] {
+		fs.links[dependentID] = removeID(fs.links[dependentID], id)
+		fs.addUnresolved(id, dependentID)
+	}
+	delete(fs.reverseLinks, id)
+
+	fs.dirty = true
+	return true
+}
+
+// RemoveUnreachable removes every File that can't be reached by following links from one of the root IDs
+func (fs *FileSet) RemoveUnreachable(rootIDs []string) []string {
+	reachable := make(map[string]bool)
+	queue := append([]string(nil), rootIDs...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if reachable[id] || !fs.Contains(id) {
+			continue
+		}
+		reachable[id] = true
+		queue = append(queue, fs.links[id]...)
+	}
+
+	var removedIDs []string
+	for id := range fs.index {
+		if !reachable[id] {
+			removedIDs = append(removedIDs, id)
+		}
+	}
+	for _, id := range removedIDs {
+		fs.Remove(id)
+	}
+	return removedIDs
+}
+
+// UnresolvedDependents gets the IDs of Files in the set which import a dependency that isn't in the set
+func (fs *FileSet) UnresolvedDependents(dependencyID string) []string {
+	var dependentIDs []string
+	for _, dependentID := range fs.unresolved[dependencyID] {
+		if fs.Contains(dependentID) {
+			dependentIDs = append(dependentIDs, dependentID)
+		}
+	}
+	return dependentIDs
 }
 
-// AddLink adds a DependencyLink between Files in a FileSet
+// IncludersOf gets the Files in the set whose contents inline a file, by its root-relative path, e.g. CSS files
+// which @import it.  Files that were never loaded will include its latest contents anyway, so they're not returned
+func (fs *FileSet) IncludersOf(relativePath string) []*File {
+	absoluteFilepath := filepath.Join(fs.workspace.RootPath(), filepath.FromSlash(relativePath))
+	var includers []*File
+	for _, file := range fs.index {
+		if file.Includes(absoluteFilepath) {
+			includers = append(includers, file)
+		}
+	}
+	return includers
+}
+
+// removeLinks forgets the dependencies of a File
+func (fs *FileSet) removeLinks(id string) {
+	for _, dependencyID := range fs.links[id] {
+		fs.reverseLinks[dependencyID] = removeID(fs.reverseLinks[dependencyID], id)
+		if len(fs.reverseLinks[dependencyID]) == 0 {
+			delete(fs.reverseLinks, dependencyID)
+		}
+	}
+	delete(fs.links, id)
+}
+
+func (fs *FileSet) addUnresolved(dependencyID string, dependentID string) {
+	dependentIDs := fs.unresolved[dependencyID]
+	for _, id := range dependentIDs {
+		if id == dependentID {
+			return
+		}
+	}
+	fs.unresolved[dependencyID] = append(dependentIDs, dependentID)
+}
+
+func removeID(ids []string, id string) []string {
+	result := ids[:0]
+	for _, existingID := range ids {
+		if existingID != id {
+			result = append(result, existingID)
+		}
+	}
+	return result
+}
+
+// AddLink adds a DependencyLink between Files in a FileSet, replacing any previous link for the same File
 func (fs *FileSet) AddLink(link *DependencyLink) bool {
 	if !fs.Contains(link.id) {
-		fmt.Printf("ERROR: AddLink() dependent file doesn't exist in the FileSet, ID: %s\n", link.id)
+		logging.Error("add-link", logging.Fields{"file": link.id}, "AddLink() dependent file doesn't exist in the FileSet, ID: %s", link.id)
 		return false
 	}
 
+	// the file may no longer import some of its previous dependencies
+	fs.removeLinks(link.id)
+
+	var resolvedIDs []string
 	for _, dependencyID := range link.dependencyIDs {
-		if !fs.Contains(dependencyID) {
-			// Commented out this warning for now, because builds
-			// in the CP modules often link to files that are in
-			// other builds.  Haven't got a solution yet.
-			// -- BC 2018-10-25
-			//
-			// fmt.Printf("ERROR: AddLink() dependency file doesn't exist in the FileSet, ID: %s\n", dependencyID)
-			return false
+		if fs.Contains(dependencyID) {
+			resolvedIDs = append(resolvedIDs, dependencyID)
+		} else {
+			// builds in the CP modules often link to files that are in
+			// other builds, and some dependencies are simply missing, so
+			// remember them in case the file turns up later
+			fs.addUnresolved(dependencyID, link.id)
 		}
 	}
 
-	fs.links[link.id] = link.dependencyIDs
-	for _, dependencyID := range link.dependencyIDs {
+	if len(resolvedIDs) == 0 {
+		return false
+	}
+
+	fs.links[link.id] = resolvedIDs
+	for _, dependencyID := range resolvedIDs {
 		if rlinks, found := fs.reverseLinks[dependencyID]; found {
 			foundLinkID := false
 			for _, rlink := range rlinks {
@@ -52,6 +52,70 @@ func TestAddDistinct(t *testing.T) {
 	assert.Equal(t, 1, sut.linkCount())
 }
 
+func createLinkedFileSet() *FileSet {
+	sut := NewEmptyFileSet(createWorkspace())
+	for _, id := range []string{"entry", "abcd", "efgh", "ijkl"} {
+		sut.Add(newFile(id, "c:\\"+id))
+	}
+	sut.AddLink(NewDependencyLink("entry", []string{"abcd", "ijkl"}))
+	sut.AddLink(NewDependencyLink("abcd", []string{"efgh"}))
+	sut.AddLink(NewDependencyLink("ijkl", []string{"efgh"}))
+	return sut
+}
+
+func TestAddLinkPartiallyResolved(t *testing.T) {
+	sut := NewEmptyFileSet(createWorkspace())
+	sut.Add(newFile("abcd", "c:\\abcd"))
+	sut.Add(newFile("efgh", "c:\\efgh"))
+	success := sut.AddLink(NewDependencyLink("abcd", []string{"efgh", "xyzw"}))
+	assert.True(t, success)
+	assert.Equal(t, []string{"efgh"}, sut.links["abcd"])
+	assert.Equal(t, []string{"abcd"}, sut.UnresolvedDependents("xyzw"))
+}
+
+func TestRemove(t *testing.T) {
+	sut := createLinkedFileSet()
+	sut.ClearDirty()
+	assert.True(t, sut.Remove("abcd"))
+	assert.False(t, sut.Contains("abcd"))
+	assert.True(t, sut.Dirty())
+	assert.Equal(t, []string{"ijkl"}, sut.links["entry"])
+	assert.Equal(t, []string{"ijkl"}, sut.reverseLinks["efgh"])
+	assert.Equal(t, []string{"entry"}, sut.UnresolvedDependents("abcd"))
+	assert.False(t, sut.Remove("abcd"))
+}
+
+func TestRemoveUnreachable(t *testing.T) {
+	sut := createLinkedFileSet()
+	sut.Remove("abcd")
+	assert.Empty(t, sut.RemoveUnreachable([]string{"entry"}))
+
+	sut.Remove("ijkl")
+	removed := sut.RemoveUnreachable([]string{"entry"})
+	assert.Equal(t, []string{"efgh"}, removed)
+	assert.Equal(t, 1, sut.Count())
+	assert.True(t, sut.Contains("entry"))
+}
+
+func TestUnresolvedDependentsClearedByAdd(t *testing.T) {
+	sut := createLinkedFileSet()
+	sut.Remove("efgh")
+	assert.ElementsMatch(t, []string{"abcd", "ijkl"}, sut.UnresolvedDependents("efgh"))
+	sut.Add(newFile("efgh", "c:\\efgh"))
+	assert.Empty(t, sut.UnresolvedDependents("efgh"))
+}
+
+func TestAddLinkReplacesPrevious(t *testing.T) {
+	sut := createLinkedFileSet()
+	sut.AddLink(NewDependencyLink("abcd", nil))
+	assert.NotContains(t, sut.links, "abcd")
+	assert.Equal(t, []string{"ijkl"}, sut.reverseLinks["efgh"])
+
+	sut.AddLink(NewDependencyLink("ijkl", nil))
+	assert.NotContains(t, sut.reverseLinks, "efgh")
+	assert.Equal(t, []string{"efgh"}, sut.RemoveUnreachable([]string{"entry"}))
+}
+
 // func TestNewBuilder(t *testing.T) {
 // 	imports := []*Import{
 // 		NewImport("Config"),
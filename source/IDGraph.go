@@ -1,8 +1,9 @@
 package source
 
 import (
-	"fmt"
 	"strings"
+
+	"github.com/mrcrowl/swarm/logging"
 )
 
 // IDGraph is used for sorting topologically
@@ -173,7 +174,7 @@ func (graph *IDGraph) analyseForCycles(id string) {
 
 	if len(cycles) > 0 {
 		for _, c := range cycles {
-			fmt.Println(displayCycle(c))
+			logging.Warn("cycle", logging.Fields{"files": c}, "Dependency cycle: %s", displayCycle(c))
 		}
 	}
 }
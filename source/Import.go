@@ -1,9 +1,11 @@
 package source
 
 import (
+	"fmt"
 	"log"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -17,14 +19,45 @@ type Import struct {
 	IsSolo           bool
 }
 
-var reStripInterpolationTemplate = regexp.MustCompile(`#\{.*?\}`)
+// ImportConditions are the values that the #{...} and #? conditions in import paths are resolved against
+type ImportConditions struct {
+	Values   map[string]string
+	Variants map[string][]string // when not nil, every variant of a conditional import is resolved, not just the current one
+}
+
+// NewImportConditions creates ImportConditions.  variants may be nil, to resolve only the current values
+func NewImportConditions(values map[string]string, variants map[string][]string) *ImportConditions {
+	if values == nil {
+		values = map[string]string{}
+	}
+	return &ImportConditions{values, variants}
+}
+
+// AllVariants indicates whether every variant of a conditional import should be resolved
+func (conditions *ImportConditions) AllVariants() bool {
+	return conditions.Variants != nil
+}
+
+// NewImportsWithConditions creates an Import for each variant of a (possibly) conditional import path.
+// This is usually one Import, but may be none if a #? condition is false, or several if conditions.AllVariants()
+func NewImportsWithConditions(importPath string, conditions *ImportConditions) ([]*Import, error) {
+	if !ContainsCondition(importPath) {
+		return []*Import{NewImport(importPath)}, nil
+	}
+
+	if conditions == nil {
+		conditions = NewImportConditions(nil, nil)
+	}
+	importPaths, err := resolveConditionalImport(importPath, conditions)
+	if err != nil {
+		return nil, err
+	}
 
-// NewImportWithInterpolation creates an Import for a path, but first interpolates any values
-func NewImportWithInterpolation(importPath string, interpolationValues map[string]string) *Import {
-	if containsInterpolation(importPath) {
-		importPath = performInterpolation(importPath, interpolationValues)
+	imports := make([]*Import, len(importPaths))
+	for i, resolvedPath := range importPaths {
+		imports[i] = NewImport(resolvedPath)
 	}
-	return NewImport(importPath)
+	return imports, nil
 }
 
 // NewImport creates an Import for a path
@@ -79,60 +112,220 @@ func (imp *Import) ToRootRelativeImport(relativeImport *Import) *Import {
 	return nil
 }
 
-// containsInterpolation indicates whether a part contains a SystemJS interpolation directive: #{...}
-func containsInterpolation(importPath string) bool {
+// ContainsInterpolation indicates whether a part contains a SystemJS interpolation directive: #{...}
+func ContainsInterpolation(importPath string) bool {
 	return strings.Contains(importPath, "#{")
 }
 
+// ContainsCondition indicates whether a part contains either a SystemJS interpolation, or a boolean condition: #?
+func ContainsCondition(importPath string) bool {
+	return ContainsInterpolation(importPath) || strings.Contains(importPath, booleanConditionPrefix)
+}
+
+const booleanConditionPrefix = "#?"
+const systemEnvModule = "@system-env"
+
+// systemEnv holds the values of the built-in @system-env condition module, as seen by a browser during development
+var systemEnv = map[string]string{
+	"browser":    "true",
+	"node":       "false",
+	"dev":        "true",
+	"production": "false",
+	"build":      "false",
+	"default":    "true",
+}
+
 var interpRe = regexp.MustCompile("#{[^}]*}")
 
-// performInterpolation interpolates a string with a set of values
-func performInterpolation(importPath string, interpolationValues map[string]string) string {
-	result := interpRe.ReplaceAllStringFunc(importPath, func(match string) string {
-		inner := match[2 : len(match)-1]
-		pipePos := strings.Index(inner, "|")
-		if pipePos >= 0 {
-			key := inner[pipePos+1:]
-			if value, ok := interpolationValues[key]; ok {
-				return value
+// importCondition is a SystemJS condition, e.g. ~./env.js|mobile.enabled ==> module "./env.js", export "mobile.enabled", negated
+type importCondition struct {
+	expression string
+	module     string
+	export     string // empty for the default export
+	negate     bool
+}
+
+// parseImportCondition parses the inside of #{...}, or the part after #?
+func parseImportCondition(expression string) (*importCondition, error) {
+	cond := &importCondition{expression: expression}
+	remainder := strings.TrimSpace(expression)
+	if strings.HasPrefix(remainder, "~") {
+		cond.negate = true
+		remainder = remainder[1:]
+	}
+
+	cond.module = remainder
+	if pipePos := strings.Index(remainder, "|"); pipePos >= 0 {
+		cond.module = remainder[:pipePos]
+		cond.export = remainder[pipePos+1:]
+		if cond.export == "" {
+			return nil, fmt.Errorf("Invalid condition '%s': missing export after '|'", expression)
+		}
+	}
+
+	if cond.module == "" {
+		return nil, fmt.Errorf("Invalid condition '%s': missing condition module", expression)
+	}
+	return cond, nil
+}
+
+// keys lists the names that a condition's value may be stored under, e.g. ./app/Config.js|SUFFIX ==> SUFFIX, Config.SUFFIX
+func (cond *importCondition) keys() []string {
+	moduleName := strings.TrimSuffix(path.Base(cond.module), ".js")
+	if cond.export == "" {
+		if moduleName == cond.module {
+			return []string{cond.module}
+		}
+		return []string{cond.module, moduleName}
+	}
+
+	keys := []string{cond.export}
+	if !strings.HasPrefix(cond.export, moduleName+".") {
+		keys = append(keys, moduleName+"."+cond.export)
+	}
+	return keys
+}
+
+// lookup finds the current value of a condition
+func (cond *importCondition) lookup(values map[string]string) (string, bool) {
+	for _, key := range cond.keys() {
+		if value, ok := values[key]; ok {
+			return value, true
+		}
+	}
+	if cond.module == systemEnvModule {
+		value, ok := systemEnv[cond.export]
+		return value, ok
+	}
+	return "", false
+}
+
+// variants lists the values a condition may take: the current value first, followed by any other known variants
+func (cond *importCondition) variants(conditions *ImportConditions) []string {
+	var variants []string
+	if value, ok := cond.lookup(conditions.Values); ok {
+		variants = append(variants, value)
+	}
+	if conditions.AllVariants() {
+		for _, key := range cond.keys() {
+			variants = appendDistinct(variants, conditions.Variants[key]...)
+		}
+	}
+	return variants
+}
+
+// resolveConditionalImport resolves the boolean condition (#?) and interpolations (#{...}) in an import path
+func resolveConditionalImport(importPath string, conditions *ImportConditions) ([]string, error) {
+	basePath := importPath
+	if conditionPos := strings.Index(importPath, booleanConditionPrefix); conditionPos >= 0 {
+		basePath = importPath[:conditionPos]
+		cond, err := parseImportCondition(importPath[conditionPos+len(booleanConditionPrefix):])
+		if err != nil {
+			return nil, fmt.Errorf("%s in import '%s'", err, importPath)
+		}
+
+		// when bundling all variants, conditional imports are always included
+		if !conditions.AllVariants() {
+			value, ok := cond.lookup(conditions.Values)
+			if !ok {
+				return nil, fmt.Errorf("Unresolved condition '%s' in import '%s'", cond.expression, importPath)
+			}
+			if isTruthy(value) == cond.negate {
+				return nil, nil
+			}
+		}
+	}
+
+	if strings.Count(basePath, "#{") != len(interpRe.FindAllString(basePath, -1)) {
+		return nil, fmt.Errorf("Unterminated interpolation in import '%s'", importPath)
+	}
+
+	resolvedPaths := []string{basePath}
+	for _, match := range interpRe.FindAllString(basePath, -1) {
+		cond, err := parseImportCondition(match[2 : len(match)-1])
+		if err != nil {
+			return nil, fmt.Errorf("%s in import '%s'", err, importPath)
+		}
+		if cond.negate {
+			return nil, fmt.Errorf("Invalid condition '%s' in import '%s': negation is only allowed for #? conditions", cond.expression, importPath)
+		}
+
+		variants := cond.variants(conditions)
+		if len(variants) == 0 {
+			return nil, fmt.Errorf("Unresolved condition '%s' in import '%s'", cond.expression, importPath)
+		}
+
+		var expandedPaths []string
+		for _, resolvedPath := range resolvedPaths {
+			for _, variant := range variants {
+				expandedPaths = appendDistinct(expandedPaths, strings.Replace(resolvedPath, match, variant, 1))
 			}
 		}
-		return ""
-	})
-	return result
+		resolvedPaths = expandedPaths
+	}
+
+	return resolvedPaths, nil
 }
 
-var interpValuesRe = regexp.MustCompile("(Config\\.\\w+)\\s*=\\s*([^;]+?)\\s*(?:;|\\/\\*)")
+// isTruthy interprets a value the way a JS condition would
+func isTruthy(value string) bool {
+	switch strings.Trim(value, "\"'") {
+	case "", "false", "0", "null", "undefined", "NaN":
+		return false
+	default:
+		return true
+	}
+}
+
+func appendDistinct(values []string, additions ...string) []string {
+	for _, addition := range additions {
+		found := false
+		for _, value := range values {
+			if value == addition {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, addition)
+		}
+	}
+	return values
+}
 
-// readInterpolationValues
+const interpValuesPattern = "(%s\\.\\w+)\\s*=\\s*([^;]+?)\\s*(?:;|\\/\\*)"
+
+// readInterpolationValues reads assignments to properties of moduleName, e.g. Config.DEBUG = true;
 func readInterpolationValues(moduleName string, configJSLines []string) map[string]string {
 	values := map[string]string{}
 	combinedContents := strings.Join(configJSLines, "\n")
+	interpValuesRe := regexp.MustCompile(fmt.Sprintf(interpValuesPattern, regexp.QuoteMeta(moduleName)))
 	matches := interpValuesRe.FindAllStringSubmatch(combinedContents, -1)
 	for _, match := range matches {
 		key := match[1]
-		value := match[2]
-		if is, stringValue := isJSPrimitive(value); is {
-			values[key] = stringValue
-		} else if is, condition, whenTrue, whenFalse := isJSTernary(value); is {
-			values[key] = interpretTernary(values, condition, whenTrue, whenFalse)
+		if value, ok := evaluateJSExpression(values, match[2]); ok {
+			values[key] = value
 		}
 	}
 	return values
 }
 
-// isJSPrimitive
+// isJSPrimitive tests whether a value is a boolean, number or string literal, and returns it unquoted
 func isJSPrimitive(value string) (bool, string) {
 	switch {
 	case value == "true":
 		return true, "true"
 	case value == "false":
 		return true, "false"
-	case strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\""):
+	case len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\""):
 		return true, value[1 : len(value)-1]
-	default:
-		return false, ""
+	case len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'"):
+		return true, value[1 : len(value)-1]
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return true, value
 	}
+	return false, ""
 }
 
 var ternaryRe = regexp.MustCompile("^\\s*(.*?)\\s*\\?\\s*(.*?)\\s*:\\s*(.*)$")
@@ -147,17 +340,33 @@ func isJSTernary(value string) (is bool, condition string, whenTrue string, when
 	return false, "", "", ""
 }
 
-// interpretTernary chooses between two alternatives, based on a dictionary value being true or "true (vs. false)
+// interpretTernary chooses between two alternatives, based on a condition being truthy
 func interpretTernary(values map[string]string, condition string, whenTrue string, whenFalse string) string {
-	value := values[condition]
-	if value == "true" || value == "\"true\"" {
-		if is, trueValue := isJSPrimitive(whenTrue); is {
-			return trueValue
-		}
-	}
-	if is, falseValue := isJSPrimitive(whenFalse); is {
-		return falseValue
+	conditionValue, _ := evaluateJSExpression(values, condition)
+	branch := whenFalse
+	if isTruthy(conditionValue) {
+		branch = whenTrue
 	}
+	value, _ := evaluateJSExpression(values, branch)
+	return value
+}
 
-	return ""
+// evaluateJSExpression evaluates a primitive, a negation (!), a reference to an earlier value, or a ternary,
+// which may be chained in its false branch, e.g. Config.A ? ".a" : Config.B ? ".b" : ""
+func evaluateJSExpression(values map[string]string, expression string) (string, bool) {
+	expression = strings.TrimSpace(expression)
+	if is, value := isJSPrimitive(expression); is {
+		return value, true
+	}
+	if is, condition, whenTrue, whenFalse := isJSTernary(expression); is {
+		return interpretTernary(values, condition, whenTrue, whenFalse), true
+	}
+	if strings.HasPrefix(expression, "!") {
+		if value, ok := evaluateJSExpression(values, expression[1:]); ok {
+			return strconv.FormatBool(!isTruthy(value)), true
+		}
+		return "", false
+	}
+	value, ok := values[expression]
+	return value, ok
 }
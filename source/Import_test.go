@@ -22,8 +22,9 @@ func TestPathConsistency(t *testing.T) {
 }
 
 func TestImportWithInterpolation(t *testing.T) {
-	var sut = NewImportWithInterpolation("tslib-#{Hello|Hello.World}", map[string]string{"Hello.World": "Gidday"})
-	assert.Equal(t, NewImport("tslib-Gidday"), sut)
+	var sut, err = NewImportsWithConditions("tslib-#{Hello|Hello.World}", NewImportConditions(map[string]string{"Hello.World": "Gidday"}, nil))
+	assert.Nil(t, err)
+	assert.Equal(t, []*Import{NewImport("tslib-Gidday")}, sut)
 }
 
 func TestSolo(t *testing.T) {
@@ -54,7 +55,7 @@ func TestImportExt(t *testing.T) {
 
 func TestImportContainsDirective(t *testing.T) {
 	path := "import \"./login-page#{Config|Config.RELEASE_TEMPLATE_STRING}.css\";"
-	assert.True(t, containsInterpolation(path))
+	assert.True(t, ContainsInterpolation(path))
 }
 
 func TestPerformInterpolation(t *testing.T) {
@@ -62,8 +63,132 @@ func TestPerformInterpolation(t *testing.T) {
 	interpValues := map[string]string{
 		"Config.RELEASE_TEMPLATE_STRING": ".mobile",
 	}
-	result := performInterpolation(path, interpValues)
-	assert.Equal(t, "import \"./login-page.mobile.css\";", result)
+	result, err := resolveConditionalImport(path, NewImportConditions(interpValues, nil))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"import \"./login-page.mobile.css\";"}, result)
+}
+
+func TestResolveConditionalImport(t *testing.T) {
+	values := map[string]string{
+		"Config.SUFFIX":  ".mobile",
+		"Config.DEBUG":   "true",
+		"Config.LOCALE":  "fr",
+		"platform":       "ios",
+		"Config.RELEASE": "false",
+	}
+	cases := map[string]struct {
+		importPath string
+		expected   []string
+	}{
+		"plain": {
+			importPath: "./login-page",
+			expected:   []string{"./login-page"},
+		},
+		"qualified export": {
+			importPath: "./login-page#{Config|Config.SUFFIX}",
+			expected:   []string{"./login-page.mobile"},
+		},
+		"unqualified export": {
+			importPath: "./login-page#{./app/Config.js|SUFFIX}",
+			expected:   []string{"./login-page.mobile"},
+		},
+		"default export": {
+			importPath: "./#{platform}/shim",
+			expected:   []string{"./ios/shim"},
+		},
+		"several interpolations": {
+			importPath: "./lang/#{Config|LOCALE}/strings#{Config|SUFFIX}",
+			expected:   []string{"./lang/fr/strings.mobile"},
+		},
+		"boolean true": {
+			importPath: "./debug-panel#?Config|DEBUG",
+			expected:   []string{"./debug-panel"},
+		},
+		"boolean false": {
+			importPath: "./release-panel#?Config|RELEASE",
+			expected:   nil,
+		},
+		"negated": {
+			importPath: "./release-panel#?~Config|RELEASE",
+			expected:   []string{"./release-panel"},
+		},
+		"system env": {
+			importPath: "./dev-tools#?~@system-env|production",
+			expected:   []string{"./dev-tools"},
+		},
+		"boolean with interpolation": {
+			importPath: "./debug#{Config|SUFFIX}#?Config|DEBUG",
+			expected:   []string{"./debug.mobile"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := resolveConditionalImport(tc.importPath, NewImportConditions(values, nil))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestResolveConditionalImportErrors(t *testing.T) {
+	cases := map[string]struct {
+		importPath string
+		expected   string
+	}{
+		"unresolved interpolation": {
+			importPath: "./page#{Config|MISSING}",
+			expected:   "Unresolved condition 'Config|MISSING' in import './page#{Config|MISSING}'",
+		},
+		"unresolved boolean": {
+			importPath: "./page#?Config|MISSING",
+			expected:   "Unresolved condition 'Config|MISSING' in import './page#?Config|MISSING'",
+		},
+		"negated interpolation": {
+			importPath: "./page#{~Config|SUFFIX}",
+			expected:   "Invalid condition '~Config|SUFFIX' in import './page#{~Config|SUFFIX}': negation is only allowed for #? conditions",
+		},
+		"missing export": {
+			importPath: "./page#?Config|",
+			expected:   "Invalid condition 'Config|': missing export after '|' in import './page#?Config|'",
+		},
+		"missing module": {
+			importPath: "./page#?~",
+			expected:   "Invalid condition '~': missing condition module in import './page#?~'",
+		},
+		"unterminated": {
+			importPath: "./page#{Config|SUFFIX",
+			expected:   "Unterminated interpolation in import './page#{Config|SUFFIX'",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := resolveConditionalImport(tc.importPath, NewImportConditions(map[string]string{"Config.SUFFIX": ".mobile"}, nil))
+			if assert.NotNil(t, err) {
+				assert.Equal(t, tc.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestNewImportsWithConditionsAllVariants(t *testing.T) {
+	conditions := NewImportConditions(
+		map[string]string{"Config.SUFFIX": ".mobile", "Config.DEBUG": "false"},
+		map[string][]string{"Config.SUFFIX": {"", ".mobile", ".tablet"}},
+	)
+
+	imports, err := NewImportsWithConditions("./page#{Config|Config.SUFFIX}", conditions)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Import{NewImport("./page.mobile"), NewImport("./page"), NewImport("./page.tablet")}, imports)
+
+	imports, err = NewImportsWithConditions("./debug-panel#?Config|DEBUG", conditions)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Import{NewImport("./debug-panel")}, imports)
+}
+
+func TestNewImportsWithConditionsExcluded(t *testing.T) {
+	imports, err := NewImportsWithConditions("./debug-panel#?Config|DEBUG", NewImportConditions(map[string]string{"Config.DEBUG": "false"}, nil))
+	assert.Nil(t, err)
+	assert.Empty(t, imports)
 }
 
 func TestGetInterpolationValues(t *testing.T) {
@@ -98,6 +223,14 @@ func TestIsJSPrimitive(t *testing.T) {
 			value:    "false",
 			expected: true,
 		},
+		"single quoted": {
+			value:    "'string'",
+			expected: true,
+		},
+		"number": {
+			value:    "2",
+			expected: true,
+		},
 		"Date": {
 			value:    "new Date()",
 			expected: false,
@@ -155,6 +288,27 @@ func TestInterpretTernary(t *testing.T) {
 			whenFalse: "\"\"",
 			expected:  ".mobile",
 		},
+		"negated": {
+			values:    map[string]string{"Config.MOBILE_RELEASE": "true"},
+			condition: "!Config.MOBILE_RELEASE",
+			whenTrue:  "\".desktop\"",
+			whenFalse: "\".mobile\"",
+			expected:  ".mobile",
+		},
+		"chained": {
+			values:    map[string]string{"Config.MOBILE_RELEASE": "false", "Config.TABLET_RELEASE": "true"},
+			condition: "Config.MOBILE_RELEASE",
+			whenTrue:  "\".mobile\"",
+			whenFalse: "Config.TABLET_RELEASE ? \".tablet\" : \"\"",
+			expected:  ".tablet",
+		},
+		"reference": {
+			values:    map[string]string{"Config.MOBILE_RELEASE": "true", "Config.MOBILE_SUFFIX": ".phone"},
+			condition: "Config.MOBILE_RELEASE",
+			whenTrue:  "Config.MOBILE_SUFFIX",
+			whenFalse: "\"\"",
+			expected:  ".phone",
+		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
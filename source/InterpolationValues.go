@@ -0,0 +1,94 @@
+package source
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/util"
+)
+
+// ReadInterpolationValues returns a map of key/value pairs that can be interpolated into import paths.
+// Values that could be read are returned, even when an error occurs reading one of the sources
+func (ws *Workspace) ReadInterpolationValues(runtimeConfig *config.RuntimeConfig) (map[string]string, error) {
+	sources := runtimeConfig.InterpolationSources()
+	isExplicit := runtimeConfig.Interpolation != nil
+	values := map[string]string{}
+	var problems []string
+
+	for _, jsPath := range sources.JS {
+		contents, err := util.ReadContents(filepath.Join(ws.rootPath, jsPath))
+		if err != nil {
+			if isExplicit { // the default Config.js is optional
+				problems = append(problems, fmt.Sprintf("failed to read '%s'", jsPath))
+			}
+			continue
+		}
+
+		moduleName := util.RemoveExtension(path.Base(filepath.ToSlash(jsPath)))
+		for key, value := range readInterpolationValues(moduleName, util.StringToLines(contents)) {
+			values[key] = value
+		}
+	}
+
+	if sources.JSON != "" {
+		contents, err := util.ReadContents(filepath.Join(ws.rootPath, sources.JSON))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("failed to read '%s'", sources.JSON))
+		} else if jsonValues, err := readJSONInterpolationValues(contents); err != nil {
+			problems = append(problems, fmt.Sprintf("'%s': %s", sources.JSON, err))
+		} else {
+			for key, value := range jsonValues {
+				values[key] = value
+			}
+		}
+	}
+
+	for key, value := range sources.Values {
+		values[key] = value
+	}
+
+	for key, value := range runtimeConfig.VariantValues() {
+		values[key] = value
+	}
+
+	if len(problems) > 0 {
+		return values, errors.New(strings.Join(problems, ", "))
+	}
+	return values, nil
+}
+
+// readJSONInterpolationValues reads a JSON object, flattening nested objects into dotted keys, e.g. Config.DEBUG
+func readJSONInterpolationValues(jsonString string) (map[string]string, error) {
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonString), &object); err != nil {
+		return nil, errors.New("Invalid JSON in interpolation values: " + err.Error())
+	}
+
+	values := map[string]string{}
+	flattenJSONValues("", object, values)
+	return values, nil
+}
+
+func flattenJSONValues(prefix string, object map[string]interface{}, values map[string]string) {
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch typedValue := value.(type) {
+		case map[string]interface{}:
+			flattenJSONValues(key, typedValue, values)
+		case string:
+			values[key] = typedValue
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(typedValue)
+		}
+	}
+}
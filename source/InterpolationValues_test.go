@@ -0,0 +1,69 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadJSONInterpolationValues(t *testing.T) {
+	values, err := readJSONInterpolationValues(`{"Config": {"DEBUG": true, "BRAND": 2, "SUFFIX": ".mobile", "Nested": {"X": null}}, "Locale": "fr"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"Config.DEBUG":    "true",
+		"Config.BRAND":    "2",
+		"Config.SUFFIX":   ".mobile",
+		"Config.Nested.X": "",
+		"Locale":          "fr",
+	}, values)
+}
+
+func TestReadJSONInterpolationValuesInvalid(t *testing.T) {
+	_, err := readJSONInterpolationValues(`{"Config": `)
+	assert.NotNil(t, err)
+}
+
+func TestReadInterpolationValues(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	testutil.WriteTextFile(temppath, "Config.js", `Config.A = "js"; Config.B = "js";`)
+	testutil.WriteTextFile(temppath, "Flags.js", `Flags.MOBILE = true;`)
+	testutil.WriteTextFile(temppath, "values.json", `{"Config": {"B": "json", "C": "json"}}`)
+
+	runtimeConfig := config.NewRuntimeConfig("", "app")
+	runtimeConfig.Interpolation = &config.InterpolationConfig{
+		JS:     []string{"Config.js", "Flags.js"},
+		JSON:   "values.json",
+		Values: map[string]string{"Config.C": "explicit"},
+	}
+	values, err := NewWorkspace(temppath).ReadInterpolationValues(runtimeConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"Config.A":     "js",
+		"Config.B":     "json",
+		"Config.C":     "explicit",
+		"Flags.MOBILE": "true",
+	}, values)
+}
+
+func TestReadInterpolationValuesMissing(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	ws := NewWorkspace(temppath)
+
+	values, err := ws.ReadInterpolationValues(config.NewRuntimeConfig("", "app"))
+	assert.Nil(t, err, "the default Config.js is optional")
+	assert.Empty(t, values)
+
+	runtimeConfig := config.NewRuntimeConfig("", "app")
+	runtimeConfig.Interpolation = &config.InterpolationConfig{
+		JS:     []string{"Missing.js"},
+		Values: map[string]string{"Config.A": "explicit"},
+	}
+	values, err = ws.ReadInterpolationValues(runtimeConfig)
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string]string{"Config.A": "explicit"}, values)
+}
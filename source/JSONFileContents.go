@@ -0,0 +1,84 @@
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mrcrowl/swarm/util"
+)
+
+// JSONFileContents describes a JSON file, bundled as a module whose value is the minified JSON text.  Like any other
+// non-JavaScript file, the value is a string, so code that parses it (e.g. JSON.parse) keeps working
+type JSONFileContents struct {
+	lines        []string
+	minifiedJSON string
+}
+
+// BundleLines returns a list of lines ready to include in a SystemJSBundle
+func (jfc *JSONFileContents) BundleLines() []string {
+	return jfc.lines
+}
+
+// MinifiedJSON returns the JSON without any insignificant whitespace, or "" if it was invalid
+func (jfc *JSONFileContents) MinifiedJSON() string {
+	return jfc.minifiedJSON
+}
+
+// SourceMappingURL returns ""
+func (jfc *JSONFileContents) SourceMappingURL() string {
+	return ""
+}
+
+// JSONSyntaxError is a syntax error in a JSON file, and where it was found
+type JSONSyntaxError struct {
+	Name   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *JSONSyntaxError) Error() string {
+	return fmt.Sprintf("Invalid JSON in %s at line %d, column %d: %s", e.Name, e.Line, e.Column, e.Err)
+}
+
+const jsonErrorTemplate = `System.register("%s", [], function (_export, _context) {
+	"use strict";
+
+	return {
+		setters: [],
+		execute: function () {
+			throw new SyntaxError(%s);
+		}
+	}
+});`
+
+// ParseJSONFileContents validates and minifies a JSON file into bundle-ready code.  Invalid JSON returns a
+// *JSONSyntaxError, along with contents that throw the error when the module is imported, so it still bundles
+func ParseJSONFileContents(name string, jsonContents string) (*JSONFileContents, error) {
+	var minified bytes.Buffer
+	if err := json.Compact(&minified, []byte(jsonContents)); err != nil {
+		syntaxErr := newJSONSyntaxError(name, jsonContents, err)
+		body := fmt.Sprintf(jsonErrorTemplate, name, util.JSONEncodeString(syntaxErr.Error()))
+		return &JSONFileContents{util.StringToLines(body), ""}, syntaxErr
+	}
+
+	body := fmt.Sprintf(template, name, util.JSONEncodeString(minified.String()))
+	return &JSONFileContents{util.StringToLines(body), minified.String()}, nil
+}
+
+// newJSONSyntaxError finds the line and column of an error from the json package
+func newJSONSyntaxError(name string, jsonContents string, err error) *JSONSyntaxError {
+	offset := len(jsonContents)
+	syntaxErr, ok := err.(*json.SyntaxError)
+	if ok && syntaxErr.Offset > 0 && int(syntaxErr.Offset) <= offset && !strings.HasPrefix(syntaxErr.Error(), "unexpected end") {
+		// the offset is just after the character that caused the error
+		offset = int(syntaxErr.Offset) - 1
+	}
+
+	before := jsonContents[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := len([]rune(before[strings.LastIndex(before, "\n")+1:])) + 1
+	return &JSONSyntaxError{name, line, column, err}
+}
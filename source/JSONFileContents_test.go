@@ -0,0 +1,45 @@
+package source
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJSONFileContents(t *testing.T) {
+	contents, err := ParseJSONFileContents("app/config.json", "{\n  \"debug\": true,\n  \"name\": \"swarm\"\n}\n")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"debug":true,"name":"swarm"}`, contents.MinifiedJSON())
+	assert.Equal(t, `System.register("app/config.json", [], function (_export, _context) {`, contents.BundleLines()[0])
+	assert.Contains(t, contents.BundleLines(), `	var __useDefault = "{\"debug\":true,\"name\":\"swarm\"}";`)
+}
+
+func TestParseJSONFileContentsErrors(t *testing.T) {
+	cases := map[string]struct {
+		json           string
+		expectedLine   int
+		expectedColumn int
+	}{
+		"first line":        {`{"debug" true}`, 1, 10},
+		"later line":        {"{\n  \"debug\": true,\n  \"name\": swarm\n}", 3, 11},
+		"trailing comma":    {"[\n  1,\n  2,\n]", 4, 1},
+		"unexpected end":    {"{\n  \"debug\": true\n", 3, 1},
+		"multibyte columns": {`{"naïve": tru}`, 1, 14},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			contents, err := ParseJSONFileContents("app/config.json", tc.json)
+			assert.NotNil(t, err)
+			syntaxErr, ok := err.(*JSONSyntaxError)
+			assert.True(t, ok)
+			assert.Equal(t, tc.expectedLine, syntaxErr.Line, "line")
+			assert.Equal(t, tc.expectedColumn, syntaxErr.Column, "column")
+			assert.True(t, strings.HasPrefix(err.Error(), "Invalid JSON in app/config.json at line"), err.Error())
+
+			// still bundles, but throws when imported
+			assert.Equal(t, "", contents.MinifiedJSON())
+			assert.Contains(t, strings.Join(contents.BundleLines(), "\n"), "throw new SyntaxError(")
+		})
+	}
+}
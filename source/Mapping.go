@@ -3,8 +3,7 @@ package source
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
-	"log"
+	"github.com/mrcrowl/swarm/logging"
 	"github.com/mrcrowl/swarm/util"
 )
 
@@ -30,7 +29,7 @@ func (mapping *Mapping) CachePlayback(playback *MapPlayback) {
 // Mappings returns the string of source mappings
 func (mapping *Mapping) Mappings() string {
 	if mapping.config == nil {
-		fmt.Println("ERROR: unexpected nil in Mapping.Mappings()")
+		logging.Error("source-map", nil, "unexpected nil in Mapping.Mappings()")
 		return ""
 	}
 	return mapping.config.Mappings
@@ -67,12 +66,13 @@ func (seg *Segment) Add(other Segment) Segment {
 
 // MapConfig represents the JSON structure of a source map in .map file
 type MapConfig struct {
-	Version    int      `json:"version"`
-	File       string   `json:"file"`
-	SourceRoot string   `json:"sourceRoot"`
-	Sources    []string `json:"sources"`
-	Names      []string `json:"names"`
-	Mappings   string   `json:"mappings"`
+	Version        int       `json:"version"`
+	File           string    `json:"file"`
+	SourceRoot     string    `json:"sourceRoot,omitempty"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent,omitempty"`
+	Names          []string  `json:"names"`
+	Mappings       string    `json:"mappings"`
 }
 
 // ParseSourceMapConfig parses a source map from a json string
@@ -118,13 +118,13 @@ func (mapping *Mapping) EnsureLoaded() {
 func (mapping *Mapping) LoadConfig() {
 	contents, err := util.ReadContents(mapping.filepath)
 	if err != nil {
-		log.Printf("Failed to load source map: %s", mapping.filepath)
+		logging.Error("source-map", logging.Fields{"file": mapping.filepath, "error": err}, "Failed to load source map: %s", mapping.filepath)
 		return
 	}
 
 	smapConfig, err := ParseSourceMapConfig(contents)
 	if err != nil {
-		log.Printf("ERROR parsing source map: " + mapping.relativePath)
+		logging.Error("source-map", logging.Fields{"file": mapping.relativePath, "error": err}, "Failed to parse source map: %s", mapping.relativePath)
 		return
 	}
 	mapping.config = smapConfig
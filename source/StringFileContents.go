@@ -7,7 +7,8 @@ import (
 
 // StringFileContents describes a systemjs file
 type StringFileContents struct {
-	lines []string
+	lines      []string
+	rawContent string
 }
 
 // BundleLines returns a list of lines ready to include in a SystemJSBundle
@@ -15,6 +16,11 @@ func (sfc *StringFileContents) BundleLines() []string {
 	return sfc.lines
 }
 
+// RawContent returns the string as it was originally found in the source file, e.g. an HTML template
+func (sfc *StringFileContents) RawContent() string {
+	return sfc.rawContent
+}
+
 // SourceMappingURL returns ""
 func (sfc *StringFileContents) SourceMappingURL() string {
 	return ""
@@ -40,5 +46,5 @@ func ParseStringFileContents(name string, fileContents string) (*StringFileConte
 
 	body := fmt.Sprintf(template, name, encodedFile)
 	lines := util.StringToLines(body)
-	return &StringFileContents{lines}, nil
+	return &StringFileContents{lines, fileContents}, nil
 }
@@ -1,16 +1,18 @@
 package source
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+
 	"github.com/mrcrowl/swarm/config"
 )
 
 // Workspace is
 type Workspace struct {
-	rootPath string
+	rootPath      string
+	fileCache     *FileCache            // may be nil
+	runtimeConfig *config.RuntimeConfig // the build that cached files are read for, or nil
 }
 
 var explicitSep = os.PathSeparator
@@ -47,24 +49,32 @@ func normaliseFilepath(filepath string, requireSuffix bool) string {
 	return normalisedFilepath
 }
 
-// RootPath returns the workspace's root path
-func (ws *Workspace) RootPath() string {
-	return ws.rootPath
+// WithFileCache creates a Workspace for the same root path, which shares the Files it reads through a FileCache
+func (ws *Workspace) WithFileCache(cache *FileCache) *Workspace {
+	return &Workspace{
+		rootPath:  ws.rootPath,
+		fileCache: cache,
+	}
 }
 
-// ReadInterpolationValues returns a map of key/value pairs that can be interpolated into import paths
-func (ws *Workspace) ReadInterpolationValues(config *config.RuntimeConfig) map[string]string {
-	// TODO: Config.js is hard-coded for now
-	//       Ideally, this would come from configuration
-
-	file, err := ws.ReadSourceFile(NewImport("./Config.js"))
-	if err != nil {
-		fmt.Println("ERR: Failed to read interpolation values from Config.js")
+// ForBuild creates a Workspace which shares the same FileCache, for reading the files of a build.  Files are still
+// shared with other builds, except those whose contents depend on the build, e.g. CSS, which is prepared for its base href
+func (ws *Workspace) ForBuild(runtimeConfig *config.RuntimeConfig) *Workspace {
+	return &Workspace{
+		rootPath:      ws.rootPath,
+		fileCache:     ws.fileCache,
+		runtimeConfig: runtimeConfig,
 	}
+}
 
-	file.EnsureLoaded(config)
-	values := readInterpolationValues("Config", file.RawContents().BundleLines())
-	return values
+// CachesFiles indicates whether Files read by this Workspace are shared through a FileCache
+func (ws *Workspace) CachesFiles() bool {
+	return ws.fileCache != nil
+}
+
+// RootPath returns the workspace's root path
+func (ws *Workspace) RootPath() string {
+	return ws.rootPath
 }
 
 // ReadSourceFile loads a source file
@@ -81,7 +91,11 @@ func (ws *Workspace) ReadSourceFile(imp *Import) (*File, error) {
 	}
 
 	if exists {
-		return newFile(imp.Path(), absoluteFilePath), nil
+		file := newFile(imp.Path(), absoluteFilePath)
+		if ws.fileCache != nil {
+			return ws.fileCache.intern(file, ws.runtimeConfig), nil
+		}
+		return file, nil
 	}
 
 	return nil, os.ErrNotExist
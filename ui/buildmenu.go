@@ -17,55 +17,91 @@ const linuxExecName = "./swarm"
 const windowsPrompt = "C:\\>"
 const linuxPrompt = "$ "
 
-// ChooseBuild chooses the build to use from a list of builds, invoking a user prompt if necessary
-func ChooseBuild(builds map[string]*config.RuntimeConfig, tailArgs []string) *config.RuntimeConfig {
+// allBuilds can be given instead of build names, to choose every build
+const allBuilds = "all"
+
+// ChooseBuilds chooses the builds to run from a list of builds, invoking a user prompt if necessary.
+// Several builds can be chosen at once, e.g. swarm app controlpanel
+func ChooseBuilds(builds map[string]*config.RuntimeConfig, tailArgs []string) map[string]*config.RuntimeConfig {
 	if len(tailArgs) > 0 {
-		// build specified as first command line argument
-		buildName := tailArgs[0]
-		if build, found := builds[buildName]; found {
-			return build
+		// builds specified as command line arguments
+		if selectedBuilds := selectBuilds(builds, tailArgs); len(selectedBuilds) > 0 {
+			return selectedBuilds
 		}
 	}
 
-	var selectedBuild *config.RuntimeConfig
+	var selectedBuilds map[string]*config.RuntimeConfig
 	switch len(builds) {
 	case 0: // no builds
 		fmt.Printf("No builds found")
 		os.Exit(1)
 
 	case 1: // single build
-		for k := range builds {
-			selectedBuild = builds[k]
-			break
-		}
+		selectedBuilds = builds
 
 	default: // choose from menu
-		selectedBuild = chooseBuildFromMenu(builds)
+		selectedBuilds = chooseBuildsFromMenu(builds)
+	}
+
+	return selectedBuilds
+}
+
+// ChooseBuildsLater is like ChooseBuilds, but doesn't prompt.  When there's a choice to make, it starts with the
+// first build and returns false, so that the dashboard can offer the choice instead
+func ChooseBuildsLater(builds map[string]*config.RuntimeConfig, tailArgs []string) (map[string]*config.RuntimeConfig, bool) {
+	if len(tailArgs) > 0 {
+		if selectedBuilds := selectBuilds(builds, tailArgs); len(selectedBuilds) > 0 {
+			return selectedBuilds, true
+		}
+	}
+	if len(builds) <= 1 {
+		return ChooseBuilds(builds, nil), true
 	}
 
-	return selectedBuild
+	first := enumerateBuildNames(builds)[0]
+	return map[string]*config.RuntimeConfig{first: builds[first]}, false
 }
 
-// chooseBuildFromMenu presents a menu to select a build
-func chooseBuildFromMenu(builds map[string]*config.RuntimeConfig) *config.RuntimeConfig {
+// BuildNames gets the names of the builds, in the order they're listed in the menu
+func BuildNames(builds map[string]*config.RuntimeConfig) []string {
+	return enumerateBuildNames(builds)
+}
+
+// selectBuilds finds the builds with the given names (or numbers, as listed in the menu)
+func selectBuilds(builds map[string]*config.RuntimeConfig, choices []string) map[string]*config.RuntimeConfig {
 	buildNames := enumerateBuildNames(builds)
-	fmt.Println("     Hint: use build arg to skip this menu")
+	selectedBuilds := map[string]*config.RuntimeConfig{}
+	for _, choice := range choices {
+		if choice == allBuilds {
+			return builds
+		}
+		if number, err := strconv.Atoi(choice); err == nil && number >= 1 && number <= len(buildNames) {
+			choice = buildNames[number-1]
+		}
+		if build, found := builds[choice]; found {
+			selectedBuilds[choice] = build
+		} else {
+			fmt.Printf("Unknown build: %s\n", choice)
+		}
+	}
+	return selectedBuilds
+}
+
+// chooseBuildsFromMenu presents a menu to select one or more builds
+func chooseBuildsFromMenu(builds map[string]*config.RuntimeConfig) map[string]*config.RuntimeConfig {
+	buildNames := enumerateBuildNames(builds)
+	fmt.Println("     Hint: use build args to skip this menu")
 	fmt.Printf("              e.g. %s%s %s\n", executablePrompt(), executableName(), buildNames[0])
 	fmt.Println("-----------------------------------------------")
-	fmt.Println("Choose your build:")
+	fmt.Printf("Choose your build(s), e.g. 1 2 or %s:\n", allBuilds)
 
 	for {
-
-		appmap := map[string]*config.RuntimeConfig{}
 		longestBuildName := 3
 		for i, name := range buildNames {
 			fmt.Printf("  %d) %s\n", (i + 1), name)
-			appmap[strconv.Itoa(i+1)] = builds[name]
-			appmap[name] = builds[name]
 			if len(name) > longestBuildName {
 				longestBuildName = len(name)
 			}
-			i++
 		}
 		fmt.Print("  ")
 		fmt.Println(strings.Repeat("-", longestBuildName+3))
@@ -75,8 +111,9 @@ func chooseBuildFromMenu(builds map[string]*config.RuntimeConfig) *config.Runtim
 		if err != nil {
 			log.Fatal("Bad input")
 		}
-		if build, found := appmap[string(lineBytes)]; found {
-			return build
+		choices := strings.FieldsFunc(string(lineBytes), func(r rune) bool { return r == ' ' || r == ',' })
+		if selectedBuilds := selectBuilds(builds, choices); len(selectedBuilds) > 0 {
+			return selectedBuilds
 		}
 	}
 }
@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/logging"
+)
+
+// checkConfigCommand validates swarm.json and its build description files, e.g. swarm check-config
+const checkConfigCommand = "check-config"
+
+// initCommand creates a swarm.json file in the current directory, e.g. swarm init
+const initCommand = "init"
+
+// showConfigCommand lists the effective settings and where they came from, e.g. swarm show-config
+const showConfigCommand = "show-config"
+
+// RunCommand runs the command named by the first command line argument (if any), then exits
+func RunCommand(tailArgs []string, overrides *config.Overrides) {
+	if len(tailArgs) == 0 {
+		return
+	}
+
+	switch tailArgs[0] {
+	case checkConfigCommand:
+		os.Exit(checkConfig(overrides))
+	case initCommand:
+		os.Exit(initConfig())
+	case showConfigCommand:
+		os.Exit(showConfig(overrides))
+	}
+}
+
+// checkConfig prints every problem found in the configuration files, returning the exit code
+func checkConfig(overrides *config.Overrides) int {
+	cwd, _ := os.Getwd()
+	swarmConfigFilepath, err := config.LocateSwarmConfig(cwd, overrides)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		return 1
+	}
+	if swarmConfigFilepath == "" {
+		swarmConfigFilepath = config.SwarmConfigFilepathInCWD()
+	}
+
+	problems, checkedFilepaths := config.CheckConfig(swarmConfigFilepath, filepath.Dir(swarmConfigFilepath))
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("Found %d problem(s) in %d file(s)\n", len(problems), len(checkedFilepaths))
+		return 1
+	}
+	fmt.Printf("No problems found in %d file(s)\n", len(checkedFilepaths))
+	return 0
+}
+
+// showConfig prints the value of every setting that can be overridden, and where it came from
+func showConfig(overrides *config.Overrides) int {
+	swarmConfig, err := config.DiscoverSwarmConfig(overrides)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to load swarm.json file: %s\n", err)
+		return 1
+	}
+
+	for _, setting := range swarmConfig.Settings() {
+		fmt.Printf("%-14s %-30s (%s)\n", setting.Name, setting.Value, setting.Source)
+	}
+	return 0
+}
+
+// initConfig scaffolds a swarm.json file in the current directory, returning the exit code
+func initConfig() int {
+	swarmConfigFilepath := config.SwarmConfigFilepathInCWD()
+	if _, err := os.Stat(swarmConfigFilepath); err == nil {
+		fmt.Printf("ERROR: %s already exists\n", swarmConfigFilepath)
+		return 1
+	}
+
+	contents, err := config.ScaffoldSwarmConfig(filepath.Dir(swarmConfigFilepath))
+	if err == nil {
+		err = ioutil.WriteFile(swarmConfigFilepath, append(contents, '\n'), 0644)
+	}
+	if err != nil {
+		fmt.Printf("ERROR: Failed to create swarm.json: %s\n", err)
+		return 1
+	}
+
+	fmt.Printf("Created %s:\n%s\n", swarmConfigFilepath, contents)
+	return 0
+}
+
+// PrintConfigSummary shows which swarm.json file is being used, and any settings which were overridden by
+// environment variables or flags
+func PrintConfigSummary(swarmConfig *config.SwarmConfig) {
+	if swarmConfig.SwarmConfigFilepath() != "" {
+		logging.Info("config", logging.Fields{"file": swarmConfig.SwarmConfigFilepath()}, "Using %s", swarmConfig.SwarmConfigFilepath())
+	} else {
+		logging.Warn("config", logging.Fields{"root": swarmConfig.RootPath},
+			"No swarm.json found in this directory or its parents, so using defaults (root: %s).\n         Run '%s %s' to create one",
+			swarmConfig.RootPath, executableName(), initCommand)
+	}
+
+	for _, setting := range swarmConfig.Settings() {
+		if setting.IsOverridden() {
+			fields := logging.Fields{"setting": setting.Name, "value": setting.Value, "source": setting.Source}
+			logging.Info("setting", fields, "Using %s = %s (from %s)", setting.Name, setting.Value, setting.Source)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrcrowl/swarm/events"
+	"github.com/mrcrowl/swarm/logging"
+	"github.com/nsf/termbox-go"
+)
+
+// the dashboard keeps this many recent changes and log lines
+const maxRecentChanges = 8
+const maxLogLines = 100
+
+// dashboardRefreshInterval is how often the dashboard redraws, e.g. to show hot reload clients connecting
+const dashboardRefreshInterval = time.Second
+
+// DashboardActions are what the dashboard's keyboard shortcuts do.  Each is called on its own goroutine
+type DashboardActions struct {
+	Rebuild      func()               // r: rebundles every module from scratch
+	SwitchBuilds func(names []string) // b: runs a different selection of builds, and rebundles them all
+	OpenBrowser  func()               // o: opens the web server's URL
+}
+
+// DashboardOptions describe what the dashboard shows
+type DashboardOptions struct {
+	Version      string
+	URL          string
+	Builds       []string   // every build in swarm.json
+	Running      []string   // the builds being run
+	ChooseBuilds bool       // opens the build picker straight away, instead of the build menu
+	Clients      func() int // the number of browsers connected for hot reload
+	Actions      DashboardActions
+}
+
+// Dashboard is a full-screen terminal UI, which shows the status and size of each bundled module, recent changes,
+// hot reload clients and missing imports, rather than a scrolling log
+type Dashboard struct {
+	mutex          sync.Mutex
+	options        DashboardOptions
+	running        []string
+	modules        map[string]*moduleStatus // by bundle path, so that each variant has its own
+	changes        []*recentChange          // most recent first
+	missingImports []string
+	building       int // the build number in progress, or 0
+	lastBuild      *events.Event
+	logLines       []string
+	partialLine    string
+	picker         map[string]bool // the builds chosen so far in the build picker, or nil if it's closed
+	redraw         chan bool
+	sub            *events.Subscription
+}
+
+type moduleStatus struct {
+	module     string
+	files      int
+	size       int
+	durationMs float64
+	time       time.Time
+}
+
+type recentChange struct {
+	time time.Time
+	file string
+}
+
+// NewDashboard creates a dashboard, which starts following the build lifecycle events straight away (i.e. it
+// should be created before the initial build), but isn't shown until Run
+func NewDashboard(options DashboardOptions) *Dashboard {
+	dashboard := newDashboard(options)
+	dashboard.sub = events.Default().Subscribe()
+	go dashboard.follow(dashboard.sub)
+	return dashboard
+}
+
+func newDashboard(options DashboardOptions) *Dashboard {
+	dashboard := &Dashboard{
+		options: options,
+		running: options.Running,
+		modules: map[string]*moduleStatus{},
+		redraw:  make(chan bool, 1),
+	}
+	if options.ChooseBuilds {
+		dashboard.openPicker()
+	}
+	return dashboard
+}
+
+// follow applies events to the dashboard, until the subscription is closed
+func (dashboard *Dashboard) follow(sub *events.Subscription) {
+	for event := range sub.C {
+		dashboard.apply(event)
+		dashboard.requestRedraw()
+	}
+}
+
+func (dashboard *Dashboard) apply(event *events.Event) {
+	dashboard.mutex.Lock()
+	defer dashboard.mutex.Unlock()
+
+	switch event.Type {
+	case events.BuildStart:
+		dashboard.building = event.Build
+		for _, file := range event.Files {
+			dashboard.changes = append([]*recentChange{{event.Time, file}}, dashboard.changes...)
+		}
+		if len(dashboard.changes) > maxRecentChanges {
+			dashboard.changes = dashboard.changes[:maxRecentChanges]
+		}
+
+	case events.ModuleBundled:
+		dashboard.modules[event.Bundle] = &moduleStatus{strings.Join(event.Modules, ", "), event.FileCount, event.Size, event.DurationMs, event.Time}
+
+	case events.BuildEnd:
+		dashboard.building = 0
+		dashboard.lastBuild = event
+		// only a full build walks every file, so otherwise the imports that were missing before still are
+		if len(event.Files) == 0 {
+			dashboard.missingImports = nil
+		}
+		dashboard.missingImports = appendDistinct(dashboard.missingImports, event.MissingImports...)
+	}
+}
+
+// Write adds lines to the dashboard's log, so that it can replace the logger's output while it's shown
+func (dashboard *Dashboard) Write(data []byte) (int, error) {
+	dashboard.mutex.Lock()
+	lines := strings.Split(dashboard.partialLine+string(data), "\n")
+	dashboard.partialLine = lines[len(lines)-1]
+	dashboard.logLines = append(dashboard.logLines, lines[:len(lines)-1]...)
+	if len(dashboard.logLines) > maxLogLines {
+		dashboard.logLines = dashboard.logLines[len(dashboard.logLines)-maxLogLines:]
+	}
+	dashboard.mutex.Unlock()
+
+	dashboard.requestRedraw()
+	return len(data), nil
+}
+
+func (dashboard *Dashboard) requestRedraw() {
+	select {
+	case dashboard.redraw <- true:
+	default: // a redraw is already pending
+	}
+}
+
+// Run shows the dashboard until q or Ctrl+C is pressed.  The log is shown within the dashboard in the meantime.
+// Returns an error if the terminal can't show it
+func (dashboard *Dashboard) Run() error {
+	if err := termbox.Init(); err != nil {
+		return err
+	}
+	logging.SetOutput(dashboard)
+	defer func() {
+		termbox.Close()
+		logging.SetOutput(os.Stdout)
+		if dashboard.sub != nil {
+			dashboard.sub.Close()
+		}
+	}()
+
+	keys := make(chan termbox.Event)
+	go func() {
+		for {
+			keys <- termbox.PollEvent()
+		}
+	}()
+	ticker := time.NewTicker(dashboardRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		dashboard.draw()
+		select {
+		case event := <-keys:
+			if event.Type == termbox.EventKey && dashboard.handleKey(event.Key, event.Ch) {
+				return nil
+			}
+		case <-dashboard.redraw:
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleKey runs the keyboard shortcuts.  Returns true to quit
+func (dashboard *Dashboard) handleKey(key termbox.Key, ch rune) bool {
+	dashboard.mutex.Lock()
+	defer dashboard.mutex.Unlock()
+
+	if key == termbox.KeyCtrlC {
+		return true
+	}
+	if dashboard.picker != nil {
+		dashboard.handlePickerKey(key, ch)
+		return false
+	}
+
+	actions := dashboard.options.Actions
+	switch ch {
+	case 'q':
+		return true
+	case 'r':
+		if actions.Rebuild != nil {
+			go actions.Rebuild()
+		}
+	case 'b':
+		if actions.SwitchBuilds != nil {
+			dashboard.openPicker()
+		}
+	case 'o':
+		if actions.OpenBrowser != nil {
+			go actions.OpenBrowser()
+		}
+	}
+	return false
+}
+
+func (dashboard *Dashboard) openPicker() {
+	dashboard.picker = map[string]bool{}
+	for _, name := range dashboard.running {
+		dashboard.picker[name] = true
+	}
+}
+
+// handlePickerKey toggles builds by their number, then applies the choice with Enter (or cancels with Esc)
+func (dashboard *Dashboard) handlePickerKey(key termbox.Key, ch rune) {
+	switch {
+	case key == termbox.KeyEsc:
+		dashboard.picker = nil
+
+	case key == termbox.KeyEnter:
+		var names []string
+		for _, name := range dashboard.options.Builds {
+			if dashboard.picker[name] {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return
+		}
+		dashboard.picker = nil
+		if !sameStrings(names, dashboard.running) {
+			dashboard.running = names
+			dashboard.modules = map[string]*moduleStatus{}
+			go dashboard.options.Actions.SwitchBuilds(names)
+		}
+
+	default:
+		if number, err := strconv.Atoi(string(ch)); err == nil && number >= 1 && number <= len(dashboard.options.Builds) {
+			name := dashboard.options.Builds[number-1]
+			dashboard.picker[name] = !dashboard.picker[name]
+		}
+	}
+}
+
+// draw renders the dashboard to the terminal
+func (dashboard *Dashboard) draw() {
+	width, height := termbox.Size()
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	for y, line := range dashboard.render(width, height) {
+		fg := termbox.ColorDefault
+		switch {
+		case y == 0:
+			fg = termbox.ColorDefault | termbox.AttrBold
+		case strings.HasPrefix(line, "ERROR") || strings.HasPrefix(line, "  ERROR"):
+			fg = termbox.ColorRed
+		case strings.HasPrefix(line, "WARNING") || strings.HasPrefix(line, "  WARNING"):
+			fg = termbox.ColorYellow
+		}
+		x := 0
+		for _, r := range line {
+			termbox.SetCell(x, y, r, fg, termbox.ColorDefault)
+			x++
+		}
+	}
+	termbox.Flush()
+}
+
+// render lays out the dashboard as lines of text, which fit within a width and height
+func (dashboard *Dashboard) render(width int, height int) []string {
+	dashboard.mutex.Lock()
+	defer dashboard.mutex.Unlock()
+
+	clients := 0
+	if dashboard.options.Clients != nil {
+		clients = dashboard.options.Clients()
+	}
+	lines := []string{
+		fmt.Sprintf("swarm v%s   %s   builds: %s   clients: %d", dashboard.options.Version, dashboard.options.URL, strings.Join(dashboard.running, ", "), clients),
+		dashboard.renderBuildStatus(),
+		"",
+	}
+
+	if dashboard.picker != nil {
+		lines = append(lines, "SWITCH BUILDS (press a number to toggle, enter to apply, esc to cancel)")
+		for i, name := range dashboard.options.Builds {
+			check := " "
+			if dashboard.picker[name] {
+				check = "x"
+			}
+			lines = append(lines, fmt.Sprintf("  [%s] %d) %s", check, i+1, name))
+		}
+	} else {
+		lines = append(lines, dashboard.renderModules()...)
+		lines = append(lines, "", "RECENT CHANGES")
+		for _, change := range dashboard.changes {
+			lines = append(lines, fmt.Sprintf("  %s  %s", change.time.Format("15:04:05"), change.file))
+		}
+		if len(dashboard.missingImports) > 0 {
+			lines = append(lines, "", "MISSING IMPORTS")
+			for _, missingImport := range dashboard.missingImports {
+				lines = append(lines, "  "+missingImport)
+			}
+		}
+	}
+
+	// the log fills whatever space is left above the shortcuts
+	footer := "r rebuild   b switch builds   o open browser   q quit"
+	if logHeight := height - len(lines) - 3; logHeight > 0 && len(dashboard.logLines) > 0 {
+		lines = append(lines, "", "LOG")
+		logLines := dashboard.logLines
+		if len(logLines) > logHeight-1 {
+			logLines = logLines[len(logLines)-(logHeight-1):]
+		}
+		for _, line := range logLines {
+			lines = append(lines, "  "+line)
+		}
+	}
+	if len(lines) > height-1 {
+		lines = lines[:maxInt(height-1, 0)]
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, footer)
+
+	for i, line := range lines {
+		if runes := []rune(line); len(runes) > width {
+			lines[i] = string(runes[:maxInt(width, 0)])
+		}
+	}
+	return lines
+}
+
+func (dashboard *Dashboard) renderBuildStatus() string {
+	lastBuild := dashboard.lastBuild
+	switch {
+	case dashboard.building > 0:
+		return fmt.Sprintf("Building #%d...", dashboard.building)
+	case lastBuild == nil:
+		return "Waiting for the first build..."
+	case len(lastBuild.Errors) > 0:
+		return fmt.Sprintf("ERROR: build #%d failed in %s with %d error(s): %s", lastBuild.Build, formatMillis(lastBuild.DurationMs), len(lastBuild.Errors), lastBuild.Errors[0])
+	}
+	status := fmt.Sprintf("Build #%d bundled %d module(s) in %s at %s", lastBuild.Build, len(lastBuild.Modules), formatMillis(lastBuild.DurationMs), lastBuild.Time.Format("15:04:05"))
+	if len(lastBuild.Warnings) > 0 {
+		status += fmt.Sprintf(", with %d warning(s)", len(lastBuild.Warnings))
+	}
+	return status
+}
+
+func (dashboard *Dashboard) renderModules() []string {
+	bundles := make([]string, 0, len(dashboard.modules))
+	bundleWidth := len("BUNDLE")
+	for bundle := range dashboard.modules {
+		bundles = append(bundles, bundle)
+		bundleWidth = maxInt(bundleWidth, len(bundle))
+	}
+	sort.Strings(bundles)
+
+	row := "%-" + strconv.Itoa(bundleWidth) + "s  %6s  %9s  %8s  %8s  %s"
+	lines := []string{fmt.Sprintf(row, "BUNDLE", "FILES", "SIZE", "TIME", "BUNDLED", "MODULE")}
+	for _, bundle := range bundles {
+		mod := dashboard.modules[bundle]
+		lines = append(lines, fmt.Sprintf(row, bundle, strconv.Itoa(mod.files), formatSize(mod.size), formatMillis(mod.durationMs), mod.time.Format("15:04:05"), mod.module))
+	}
+	if len(bundles) == 0 {
+		lines = append(lines, "  (nothing bundled yet)")
+	}
+	return lines
+}
+
+// formatSize formats a number of bytes, e.g. 34.5 KB
+func formatSize(size int) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+// formatMillis formats a duration in milliseconds, e.g. 120ms or 1.5s
+func formatMillis(ms float64) string {
+	if ms >= 1000 {
+		return fmt.Sprintf("%.1fs", ms/1000)
+	}
+	return fmt.Sprintf("%.0fms", ms)
+}
+
+func appendDistinct(values []string, more ...string) []string {
+	for _, value := range more {
+		found := false
+		for _, existing := range values {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,132 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrcrowl/swarm/events"
+	"github.com/nsf/termbox-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDashboard(actions DashboardActions) *Dashboard {
+	return newDashboard(DashboardOptions{
+		Version: "1.0.0",
+		URL:     "http://localhost:8096/app",
+		Builds:  []string{"app", "controlpanel"},
+		Running: []string{"app"},
+		Clients: func() int { return 2 },
+		Actions: actions,
+	})
+}
+
+func TestDashboardRender(t *testing.T) {
+	at := time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC)
+	dashboard := newTestDashboard(DashboardActions{})
+	dashboard.apply(&events.Event{Type: events.BuildStart, Build: 2, Time: at, Files: []string{"app/src/main.ts"}})
+	dashboard.apply(&events.Event{Type: events.ModuleBundled, Build: 2, Time: at, Modules: []string{"app/src/main"}, Bundle: "/app/src/main.js", FileCount: 12, Size: 35328, DurationMs: 120})
+	dashboard.apply(&events.Event{Type: events.BuildEnd, Build: 2, Time: at, Files: []string{"app/src/main.ts"}, Modules: []string{"app/src/main"}, DurationMs: 150, MissingImports: []string{"app/src/missing"}})
+	dashboard.Write([]byte("Change detected...\n...done in 150ms\npartial"))
+
+	expected := []string{
+		"swarm v1.0.0   http://localhost:8096/app   builds: app   clients: 2",
+		"Build #2 bundled 1 module(s) in 150ms at 05:06:07",
+		"",
+		"BUNDLE             FILES       SIZE      TIME   BUNDLED  MODULE",
+		"/app/src/main.js      12    34.5 KB     120ms  05:06:07  app/src/main",
+		"",
+		"RECENT CHANGES",
+		"  05:06:07  app/src/main.ts",
+		"",
+		"MISSING IMPORTS",
+		"  app/src/missing",
+		"",
+		"LOG",
+		"  Change detected...",
+		"  ...done in 150ms",
+		"",
+		"r rebuild   b switch builds   o open browser   q quit",
+	}
+	assert.Equal(t, expected, dashboard.render(80, 17))
+}
+
+func TestDashboardRenderFitsTheScreen(t *testing.T) {
+	dashboard := newTestDashboard(DashboardActions{})
+	for i := 0; i < 20; i++ {
+		dashboard.Write([]byte("a long line of log output\n"))
+	}
+
+	lines := dashboard.render(10, 8)
+	assert.Len(t, lines, 8)
+	for _, line := range lines {
+		assert.True(t, len(line) <= 10, line)
+	}
+	assert.Equal(t, "r rebuild ", lines[7])
+}
+
+func TestDashboardMissingImports(t *testing.T) {
+	dashboard := newTestDashboard(DashboardActions{})
+	dashboard.apply(&events.Event{Type: events.BuildEnd, MissingImports: []string{"a"}})
+	dashboard.apply(&events.Event{Type: events.BuildEnd, Files: []string{"x.ts"}, MissingImports: []string{"b", "a"}})
+	assert.Equal(t, []string{"a", "b"}, dashboard.missingImports)
+
+	// a full build finds every missing import again
+	dashboard.apply(&events.Event{Type: events.BuildEnd, MissingImports: []string{"b"}})
+	assert.Equal(t, []string{"b"}, dashboard.missingImports)
+}
+
+func TestDashboardKeys(t *testing.T) {
+	rebuilt := make(chan bool, 1)
+	switched := make(chan []string, 1)
+	dashboard := newTestDashboard(DashboardActions{
+		Rebuild:      func() { rebuilt <- true },
+		SwitchBuilds: func(names []string) { switched <- names },
+	})
+
+	assert.False(t, dashboard.handleKey(0, 'r'))
+	assert.True(t, <-rebuilt)
+
+	assert.False(t, dashboard.handleKey(0, 'b'))
+	assert.True(t, strings.HasPrefix(dashboard.render(80, 10)[3], "SWITCH BUILDS"))
+	dashboard.handleKey(0, '2')
+	dashboard.handleKey(0, '1')
+	dashboard.handleKey(0, 'q') // ignored while picking
+	dashboard.handleKey(termbox.KeyEnter, 0)
+	assert.Equal(t, []string{"controlpanel"}, <-switched)
+	assert.Nil(t, dashboard.picker)
+
+	assert.True(t, dashboard.handleKey(0, 'q'))
+	assert.True(t, dashboard.handleKey(termbox.KeyCtrlC, 0))
+}
+
+func TestDashboardPickerCancel(t *testing.T) {
+	dashboard := newTestDashboard(DashboardActions{SwitchBuilds: func(names []string) { t.Error("should not switch") }})
+	dashboard.handleKey(0, 'b')
+	dashboard.handleKey(0, '2')
+	dashboard.handleKey(termbox.KeyEsc, 0)
+	assert.Nil(t, dashboard.picker)
+	assert.Equal(t, []string{"app"}, dashboard.running)
+
+	// at least one build is needed
+	dashboard.handleKey(0, 'b')
+	dashboard.handleKey(0, '1')
+	dashboard.handleKey(termbox.KeyEnter, 0)
+	assert.NotNil(t, dashboard.picker)
+}
+
+func TestFormatSize(t *testing.T) {
+	cases := map[string]struct {
+		size     int
+		expected string
+	}{
+		"bytes":     {812, "812 B"},
+		"kilobytes": {35328, "34.5 KB"},
+		"megabytes": {3 * 1024 * 1024, "3.0 MB"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatSize(tc.size))
+		})
+	}
+}
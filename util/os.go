@@ -2,18 +2,19 @@ package util
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
 	"syscall"
+
+	"github.com/mrcrowl/swarm/logging"
 )
 
 // ExitIFError checks if err is non-nil and then shows the message and exits the program
 func ExitIfError(err error, message string, args ...interface{}) {
 	if err != nil {
-		log.Fatalf(message, args...)
+		logging.Error("fatal", logging.Fields{"error": err}, message, args...)
 		os.Exit(1)
 	}
 }
@@ -41,6 +42,6 @@ func OpenBrowser(url string) {
 	}
 
 	if err != nil {
-		log.Println("Failed to open browser")
+		logging.Warn("open-browser", logging.Fields{"error": err}, "Failed to open browser")
 	}
 }
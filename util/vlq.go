@@ -0,0 +1,110 @@
+package util
+
+import (
+	"fmt"
+)
+
+// Source maps encode their mappings as base-64 VLQs.  See: https://sourcemaps.info/spec.html
+
+const base64Map = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+func byteToInt(b byte) (int, error) {
+	switch {
+	case b >= 'A' && b <= 'Z':
+		return int(b - 'A'), nil
+	case b >= 'a' && b <= 'z':
+		return int(b - 'a' + 26), nil
+	case b >= '0' && b <= '9':
+		return int(b - '0' + 52), nil
+	case b == '+':
+		return 62, nil
+	case b == '/':
+		return 63, nil
+	case b == '=':
+		return 64, nil
+	default:
+		return 0, fmt.Errorf("Invalid base-64 VLQ character: %c", b)
+	}
+}
+
+func intToByte(i int) byte {
+	if i >= 0 && i <= 64 {
+		return base64Map[i]
+	}
+
+	panic(fmt.Sprintf("intToByte received int out of range: %d", i))
+}
+
+// DecodeVLQ decodes a base-64 VLQ string to a list of integers
+func DecodeVLQ(s string) ([]int, error) {
+	result := make([]int, 0, 4)
+	shift := uint(0)
+	value := 0
+
+	for _, b := range []byte(s) {
+		integer, err := byteToInt(b)
+		if err != nil {
+			return nil, err
+		}
+
+		hasContinuationBit := (integer & 32) > 0
+
+		integer &= 31
+		value += integer << shift
+
+		if hasContinuationBit {
+			shift += 5
+		} else {
+			shouldNegate := (value & 1) > 0
+			value >>= 1
+
+			if shouldNegate {
+				result = append(result, -value)
+			} else {
+				result = append(result, value)
+			}
+
+			// reset
+			value = 0
+			shift = 0
+		}
+	}
+
+	return result, nil
+}
+
+// EncodeVLQ encodes a list of numbers to a base-64 VLQ string
+func EncodeVLQ(values []int) string {
+	result := make([]byte, 0, 8)
+	for _, n := range values {
+		result = append(result, encodeInteger(n)...)
+	}
+	return string(result)
+}
+
+func encodeInteger(n int) []byte {
+	result := make([]byte, 0, 8)
+
+	if n < 0 {
+		n = (-n << 1) | 1
+	} else {
+		n <<= 1
+	}
+
+	for {
+		clamped := n & 31
+		n >>= 5
+
+		if n > 0 {
+			clamped |= 32
+		}
+
+		result = append(result, intToByte(clamped))
+
+		if n <= 0 {
+			break
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,29 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVLQRoundTrip(t *testing.T) {
+	cases := map[string][]int{
+		"AAAC":        {0, 0, 0, 1},
+		"ADAA":        {0, -1, 0, 0},
+		"AAgBC":       {0, 0, 16, 1},
+		"G9s6aAs8BzC": {3, -439502, 0, 966, -41},
+	}
+	for vlq, values := range cases {
+		t.Run(vlq, func(t *testing.T) {
+			assert.Equal(t, vlq, EncodeVLQ(values))
+			decoded, err := DecodeVLQ(vlq)
+			assert.Nil(t, err)
+			assert.Equal(t, values, decoded)
+		})
+	}
+}
+
+func TestDecodeVLQInvalid(t *testing.T) {
+	_, err := DecodeVLQ("AA*A")
+	assert.NotNil(t, err)
+}
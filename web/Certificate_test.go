@@ -0,0 +1,79 @@
+package web
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureLocalhostCertificate(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+
+	certFile, keyFile, err := ensureLocalhostCertificate(tempDir)
+	assert.Nil(t, err)
+	assert.True(t, isCertificateCurrent(certFile, keyFile, time.Now()))
+	certPEM := testutil.ReadTextFile(tempDir, certificateFilename)
+
+	// cached for the next run
+	_, _, err = ensureLocalhostCertificate(tempDir)
+	assert.Nil(t, err)
+	assert.Equal(t, certPEM, testutil.ReadTextFile(tempDir, certificateFilename))
+
+	// renewed when about to expire
+	assert.False(t, isCertificateCurrent(certFile, keyFile, time.Now().Add(certificateValidity-time.Hour)))
+}
+
+func TestGeneratedCertificateIsForLocalhost(t *testing.T) {
+	certPEM, _, err := generateLocalhostCertificate(time.Now())
+	assert.Nil(t, err)
+
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	assert.Nil(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	for _, host := range []string{"localhost", "127.0.0.1", "::1"} {
+		_, err := cert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+		assert.Nil(t, err, host)
+	}
+}
+
+func TestHTTPSURL(t *testing.T) {
+	serverConfig := config.NewServerConfig(9001, false, true)
+	serverConfig.HTTPS = true
+	server := CreateServer(CreateServerOptions("", serverConfig, nil, "app"))
+	assert.Equal(t, "https://localhost:9001/app", server.URL())
+}
+
+func TestResolveCertificateNeedsCertAndKey(t *testing.T) {
+	cases := map[string]struct {
+		certFile string
+		keyFile  string
+		expected string
+	}{
+		"cert only": {"cert.pem", "", "server.certFile is set, but server.keyFile isn't (both are needed to use your own certificate)"},
+		"key only":  {"", "key.pem", "server.keyFile is set, but server.certFile isn't (both are needed to use your own certificate)"},
+		"both":      {"cert.pem", "key.pem", ""},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			serverConfig := config.NewServerConfig(9001, false, true)
+			serverConfig.HTTPS = true
+			serverConfig.CertFile, serverConfig.KeyFile = tc.certFile, tc.keyFile
+			opts := CreateServerOptions("", serverConfig, nil, "app")
+			err := opts.ResolveCertificate()
+			if tc.expected == "" {
+				assert.Nil(t, err)
+				assert.Equal(t, "cert.pem", opts.CertFile)
+			} else {
+				assert.EqualError(t, err, tc.expected)
+			}
+		})
+	}
+}
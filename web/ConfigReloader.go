@@ -0,0 +1,181 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrcrowl/swarm/bundle"
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/logging"
+	"github.com/mrcrowl/swarm/monitor"
+	"github.com/mrcrowl/swarm/source"
+)
+
+const configPollInterval = time.Second
+
+// ConfigReloader watches swarm.json and the build description files, and applies any changes to the running
+// builds and server, without a restart
+type ConfigReloader struct {
+	server      *Server
+	workspace   *source.Workspace
+	buildSet    *bundle.BuildSet
+	swarmConfig *config.SwarmConfig
+	watcher     *monitor.FileWatcher
+	mutex       *sync.Mutex
+}
+
+// NewConfigReloader creates a ConfigReloader for the builds in a BuildSet
+func NewConfigReloader(server *Server, workspace *source.Workspace, buildSet *bundle.BuildSet, swarmConfig *config.SwarmConfig) *ConfigReloader {
+	reloader := &ConfigReloader{
+		server:      server,
+		workspace:   workspace,
+		buildSet:    buildSet,
+		swarmConfig: swarmConfig,
+		mutex:       &sync.Mutex{},
+	}
+	reloader.watcher = monitor.NewFileWatcher(reloader.watchedFilepaths(), configPollInterval)
+	reloader.watcher.RegisterCallback(reloader.notifyChanged)
+	return reloader
+}
+
+// Watch polls the configuration files for changes until stopped (blocking)
+func (reloader *ConfigReloader) Watch() {
+	reloader.watcher.Watch()
+}
+
+// Stop ends watching
+func (reloader *ConfigReloader) Stop() {
+	reloader.watcher.Stop()
+}
+
+func (reloader *ConfigReloader) watchedFilepaths() []string {
+	swarmConfigFilepath := reloader.swarmConfig.SwarmConfigFilepath()
+	if swarmConfigFilepath == "" {
+		swarmConfigFilepath = config.SwarmConfigFilepathInCWD() // in case it's created
+	}
+	filepaths := []string{swarmConfigFilepath}
+	for _, name := range reloader.buildSet.Names() {
+		if build, ok := reloader.swarmConfig.Builds[name]; ok {
+			filepaths = append(filepaths, config.BuildDescriptionFilepath(build.BuildPath))
+		}
+	}
+	return filepaths
+}
+
+func (reloader *ConfigReloader) notifyChanged(changedFilepaths []string) {
+	logging.Info("config-changed", logging.Fields{"files": changedFilepaths}, "Configuration changed: %s", strings.Join(changedFilepaths, ", "))
+	if err := reloader.Reload(); err != nil {
+		logging.Error("config-reload", logging.Fields{"error": err}, "Failed to reload configuration: %s", err)
+	}
+}
+
+// Reload re-reads swarm.json and the build description files, rebuilding any modules that were added or changed
+func (reloader *ConfigReloader) Reload() error {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	swarmConfig, err := reloader.swarmConfig.Reload()
+	if err != nil {
+		return err
+	}
+	reloader.warnAboutRestartRequired(swarmConfig)
+
+	var rebuiltNames, removedNames []string
+	for _, name := range reloader.buildSet.Names() {
+		build, ok := swarmConfig.Builds[name]
+		if !ok {
+			return fmt.Errorf("Build '%s' no longer exists in swarm.json", name)
+		}
+
+		moduleDescrs, err := config.LoadBuildDescriptionFile(build.BuildPath)
+		if err != nil {
+			return err
+		}
+
+		rebuilt, removed, err := reloader.buildSet.Reconfigure(name, moduleDescrs.NormaliseModules(reloader.workspace.RootPath()), build)
+		if err != nil {
+			return fmt.Errorf("%s (in build '%s')", err, name)
+		}
+		rebuiltNames = append(rebuiltNames, rebuilt...)
+		removedNames = append(removedNames, removed...)
+	}
+
+	reloader.swarmConfig = swarmConfig
+	reloader.watcher.SetFilepaths(reloader.watchedFilepaths())
+	if len(rebuiltNames) == 0 && len(removedNames) == 0 {
+		return nil
+	}
+
+	if len(rebuiltNames) > 0 {
+		logging.Summary("config-rebuild", logging.Fields{"modules": rebuiltNames}, "Rebuilt modules: %s", strings.Join(rebuiltNames, ", "))
+	}
+	if len(removedNames) > 0 {
+		logging.Summary("config-remove", logging.Fields{"modules": removedNames}, "Removed modules: %s", strings.Join(removedNames, ", "))
+	}
+	reloader.server.SetHandlers(reloader.buildSet.GenerateHTTPHandlers())
+	if reloader.server.IsHotReloadEnabled() {
+		reloader.server.TriggerFullReload()
+	}
+	return nil
+}
+
+// SwitchBuilds runs a different selection of the builds in swarm.json.  Builds that weren't already running are
+// bundled by the next rebuild
+func (reloader *ConfigReloader) SwitchBuilds(names []string) error {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	builds := make(map[string]*config.RuntimeConfig, len(names))
+	for _, name := range names {
+		build, ok := reloader.swarmConfig.Builds[name]
+		if !ok {
+			return fmt.Errorf("Unknown build '%s'", name)
+		}
+		builds[name] = build
+	}
+	if err := reloader.buildSet.SetBuilds(builds); err != nil {
+		return err
+	}
+
+	reloader.watcher.SetFilepaths(reloader.watchedFilepaths())
+	reloader.server.SetHandlers(reloader.buildSet.GenerateHTTPHandlers())
+
+	served := map[string]bool{}
+	for _, basePath := range reloader.server.basePaths {
+		served[basePath] = true
+	}
+	for _, baseHref := range reloader.buildSet.BaseHrefs() {
+		if !served[baseHref] {
+			logging.Warn("restart-required", logging.Fields{"baseHref": baseHref}, "The base href '%s' will only be served after swarm is restarted", baseHref)
+		}
+	}
+	return nil
+}
+
+// warnAboutRestartRequired lists the changed settings which can't be applied to a running server
+func (reloader *ConfigReloader) warnAboutRestartRequired(swarmConfig *config.SwarmConfig) {
+	var settings []string
+	if swarmConfig.RootPath != reloader.swarmConfig.RootPath {
+		settings = append(settings, "root")
+	}
+	if !reflect.DeepEqual(swarmConfig.Monitor, reloader.swarmConfig.Monitor) {
+		settings = append(settings, "monitor")
+	}
+	if !reflect.DeepEqual(swarmConfig.Server, reloader.swarmConfig.Server) {
+		settings = append(settings, "server")
+	}
+	for _, name := range reloader.buildSet.Names() {
+		previous, wasFound := reloader.swarmConfig.Builds[name]
+		build, isFound := swarmConfig.Builds[name]
+		if wasFound && isFound && previous.BaseHref != build.BaseHref {
+			settings = append(settings, "baseHref of "+name)
+		}
+	}
+
+	if len(settings) > 0 {
+		logging.Warn("restart-required", logging.Fields{"settings": settings}, "Changes to %s will only apply after swarm is restarted", strings.Join(settings, ", "))
+	}
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/mrcrowl/swarm/bundle"
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigReloadRemovesModuleHandlers(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "swarm.json", `{"root": ".", "builds": {"app": {"path": "build/systemjs_build_app.json", "baseHref": "app"}}}`)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	buildPath := testutil.MakeSubdirectoryTree(workspacePath, "build")
+	testutil.WriteTextFile(buildPath, "systemjs_build_app.json", `{"modules": [{ "name": "app/main" }, { "name": "app/other" }], "base": ""}`)
+	appPath := testutil.MakeSubdirectoryTree(workspacePath, "app")
+	testutil.WriteTextFile(appPath, "main.js", `System.register([], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(appPath, "other.js", `System.register([], function (exports_1, context_1) {`)
+
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+	os.Chdir(workspacePath)
+	swarmConfig, err := config.DiscoverSwarmConfig(nil)
+	assert.Nil(t, err)
+
+	ws := source.NewWorkspace(swarmConfig.RootPath)
+	buildSet, err := bundle.CreateBuildSet(ws, swarmConfig.Builds)
+	assert.Nil(t, err)
+	buildSet.NotifyChanges(nil)
+
+	server, mux := createWebServer(workspacePath)
+	server.SetHandlers(buildSet.GenerateHTTPHandlers())
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "fallback") })
+	dispatcher := server.customHandlerDispatcher(mux)
+	serve := func(url string) string {
+		request, _ := http.NewRequest("GET", url, nil)
+		writer := newMockWriter()
+		dispatcher.ServeHTTP(writer, request)
+		return writer.sb.String()
+	}
+	assert.NotEqual(t, "fallback", serve("/app/other.js"))
+
+	// app/other is removed, which doesn't cause anything to be rebuilt
+	testutil.WriteTextFile(buildPath, "systemjs_build_app.json", `{"modules": [{ "name": "app/main" }], "base": ""}`)
+	reloader := NewConfigReloader(server, ws, buildSet, swarmConfig)
+	assert.Nil(t, reloader.Reload())
+	assert.Equal(t, "fallback", serve("/app/other.js"))
+	assert.Equal(t, "fallback", serve("/app/other.js.map"))
+	assert.NotEqual(t, "fallback", serve("/app/main.js"))
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mrcrowl/swarm/events"
+)
+
+const eventStreamPath = swarmVirtualPath + "/events"
+
+// eventStreamKeepAlive is how often a comment is sent, so that idle proxies don't drop the connection
+const eventStreamKeepAlive = 15 * time.Second
+
+// attachEventStream publishes the build lifecycle events as Server-Sent Events, e.g. for editor plugins
+func (server *Server) attachEventStream(mux *http.ServeMux) {
+	mux.HandleFunc(eventStreamPath, server.serveEventStream)
+}
+
+func (server *Server) serveEventStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := server.events.Subscribe()
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+
+		case <-server.shutdown:
+			return
+		}
+	}
+}
+
+// forwardEventsToWebsocket broadcasts the build lifecycle events to the websocket clients, until the subscription
+// is closed
+func (server *Server) forwardEventsToWebsocket(sub *events.Subscription) {
+	for event := range sub.C {
+		data, _ := json.Marshal(event)
+		server.hub.broadcast(event.Type, string(data))
+	}
+}
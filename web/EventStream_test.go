@@ -0,0 +1,83 @@
+package web
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mrcrowl/swarm/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventStream(t *testing.T) {
+	server, mux := createWebServer("")
+	server.events = events.NewBus()
+	server.attachEventStream(mux)
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + eventStreamPath)
+	assert.Nil(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(response.Body)
+	readMessage := func() string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil || line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, ": connected\n", readMessage())
+
+	server.events.Publish(&events.Event{Type: events.BuildStart, Files: []string{"app/src/main.ts"}})
+	message := readMessage()
+	assert.True(t, strings.HasPrefix(message, "event: build-start\ndata: {\"type\":\"build-start\","), message)
+	assert.Contains(t, message, `"build":1,"files":["app/src/main.ts"]`)
+}
+
+func TestEventsForwardedToWebsocket(t *testing.T) {
+	server, mux := createWebServer("")
+	server.events = events.NewBus()
+	server.attachWebSocketListeners(mux, server.hub)
+	go server.hub.run()
+	defer server.hub.stop()
+	sub := server.events.Subscribe()
+	defer sub.Close()
+	go server.forwardEventsToWebsocket(sub)
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(httpServer.URL, "http://", "ws://", 1)+webSocketServerPath, nil)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	// the client is registered asynchronously, so publish until something arrives
+	received := make(chan SocketPayload, 1)
+	go func() {
+		var payload SocketPayload
+		if conn.ReadJSON(&payload) == nil {
+			received <- payload
+		}
+	}()
+	for attempt := 0; attempt < 100; attempt++ {
+		server.events.Publish(&events.Event{Type: events.BuildEnd, Modules: []string{"app/src/main"}})
+		select {
+		case payload := <-received:
+			assert.Equal(t, "build-end", payload.Type)
+			assert.Contains(t, payload.Data, `"modules":["app/src/main"]`)
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	t.Fatal("No event was received over the websocket")
+}
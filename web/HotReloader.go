@@ -1,24 +1,28 @@
 package web
 
 import (
+	"path"
+	"strings"
+
 	"github.com/mrcrowl/swarm/bundle"
 	"github.com/mrcrowl/swarm/monitor"
 	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/util"
 )
 
 // HotReloader is responsible for managing hot reloads
 type HotReloader struct {
 	server    *Server
 	workspace *source.Workspace
-	moduleSet *bundle.ModuleSet
+	buildSet  *bundle.BuildSet
 }
 
 // NewHotReloader creates a new hot reload manager
-func NewHotReloader(server *Server, workspace *source.Workspace, moduleSet *bundle.ModuleSet) *HotReloader {
+func NewHotReloader(server *Server, workspace *source.Workspace, buildSet *bundle.BuildSet) *HotReloader {
 	return &HotReloader{
 		server,
 		workspace,
-		moduleSet,
+		buildSet,
 	}
 }
 
@@ -36,6 +40,8 @@ func (hot *HotReloader) NotifyReload(changes *monitor.EventChangeset) {
 		if changes.HasSingleExt(".css") {
 			// css-only reload
 			seenFiles := make(map[string]bool)
+			var reloadedFiles []*source.File
+			bundles := make(map[*source.File][]string)
 			for _, change := range changes.Changes() {
 				// dedupe: only reload each file once
 				if _, seen := seenFiles[change.AbsoluteFilepath()]; seen {
@@ -44,16 +50,126 @@ func (hot *HotReloader) NotifyReload(changes *monitor.EventChangeset) {
 
 				seenFiles[change.AbsoluteFilepath()] = true
 				if relativePath, ok := hot.workspace.ToRelativePath(change.AbsoluteFilepath()); ok {
-					if file := hot.moduleSet.FindFileByPath(relativePath); file != nil {
-						cssContent := file.RawContents().(*source.CSSFileContents).RawCSSContent()
-						hot.server.TriggerCSSReload(relativePath, cssContent)
+					// CSS is prepared for the base href of each build, so the pages of each build get their own
+					found := false
+					for _, name := range hot.buildSet.Names() {
+						set := hot.buildSet.ModuleSet(name)
+						if set == nil {
+							continue
+						}
+
+						// style sheets which @import the file are reloaded too, or instead, if it isn't bundled itself
+						files := set.FilesIncluding(relativePath)
+						if file := set.FindFileByPath(relativePath); file != nil {
+							files = append([]*source.File{file}, files...)
+						}
+						for _, file := range files {
+							found = true
+							if _, isCSS := file.RawContents().(*source.CSSFileContents); !isCSS {
+								continue
+							}
+							if _, reloaded := bundles[file]; !reloaded {
+								reloadedFiles = append(reloadedFiles, file)
+							}
+							bundles[file] = append(bundles[file], set.BundlesContaining(file.ID)...)
+						}
+					}
+					if !found && change.Removed() {
+						// removed style sheets can't be replaced in-place
+						hot.server.TriggerFullReload()
+						return
 					}
 				}
 			}
 
+			for _, file := range reloadedFiles {
+				cssContents := file.RawContents().(*source.CSSFileContents)
+				hot.server.TriggerCSSReload(file.ID, cssContents.CSS(), bundles[file])
+			}
+			return
+		}
+
+		if (changes.HasSingleExt(".html") || changes.HasSingleExt(".json")) && hot.reloadValues(changes) {
+			// template or JSON-only reload
 			return
 		}
+
+		hot.server.TriggerReload(hot.affectedBundles(changes))
+		return
 	}
 
 	hot.server.TriggerFullReload()
 }
+
+// reloadValues sends changed value modules, i.e. templates (.html files bundled through SystemJS) or JSON files, to
+// the pages using them, to swap in place.  Returns false without sending anything if any of the changes can't be
+// swapped, e.g. a page changed, or the JSON became invalid
+func (hot *HotReloader) reloadValues(changes *monitor.EventChangeset) bool {
+	var files []*source.File
+	seenFiles := make(map[string]bool)
+	for _, change := range changes.Changes() {
+		// dedupe: only reload each file once
+		if seenFiles[change.AbsoluteFilepath()] {
+			continue
+		}
+		seenFiles[change.AbsoluteFilepath()] = true
+
+		relativePath, ok := hot.workspace.ToRelativePath(change.AbsoluteFilepath())
+		if !ok || change.Removed() {
+			return false
+		}
+		file := hot.buildSet.FindFileByPath(relativePath)
+		if file == nil {
+			return false
+		}
+		switch contents := file.RawContents().(type) {
+		case *source.StringFileContents:
+		case *source.JSONFileContents:
+			if contents.MinifiedJSON() == "" {
+				return false
+			}
+		default:
+			return false
+		}
+		files = append(files, file)
+	}
+
+	for _, file := range files {
+		bundles := hot.buildSet.BundlesContaining(file.ID)
+		switch contents := file.RawContents().(type) {
+		case *source.StringFileContents:
+			hot.server.TriggerTemplateReload(file.ID, contents.RawContent(), bundles)
+		case *source.JSONFileContents:
+			// the module's value is the JSON text, not the object it describes
+			hot.server.TriggerValueReload(file.ID, util.JSONEncodeString(contents.MinifiedJSON()), bundles)
+		}
+	}
+	return true
+}
+
+// affectedBundles gets the bundles which include any of the changed files, so that only the pages using them are
+// reloaded.  Changed files outside of the bundles (e.g. .ts files, whose .js output also changes) are ignored, but
+// if none are in a bundle (e.g. a removed file), or a page changed, returns nil to reload every page
+func (hot *HotReloader) affectedBundles(changes *monitor.EventChangeset) []string {
+	var bundles []string
+	for _, change := range changes.Changes() {
+		relativePath, ok := hot.workspace.ToRelativePath(change.AbsoluteFilepath())
+		if !ok {
+			return nil
+		}
+		containing := hot.buildSet.BundlesContaining(relativePath)
+		if len(containing) == 0 && isPage(relativePath) {
+			return nil
+		}
+		bundles = append(bundles, containing...)
+	}
+	if len(bundles) == 0 {
+		return nil
+	}
+	return bundles
+}
+
+func isPage(relativePath string) bool {
+	ext := strings.ToLower(path.Ext(relativePath))
+	return ext == ".html" || ext == ".htm"
+}
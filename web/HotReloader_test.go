@@ -0,0 +1,156 @@
+package web
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mrcrowl/swarm/bundle"
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/monitor"
+	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/rjeczalik/notify"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createValueHotReloader(t *testing.T, workspacePath string) (*HotReloader, *bundle.BuildSet) {
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	buildPath := testutil.MakeSubdirectoryTree(workspacePath, "build")
+	testutil.WriteTextFile(buildPath, "systemjs_build_app.json", `{"modules": [{ "name": "app/main" }], "base": ""}`)
+	appPath := testutil.MakeSubdirectoryTree(workspacePath, "app")
+	testutil.WriteTextFile(appPath, "main.js", `System.register(["./template.html", "./config.json"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(appPath, "template.html", "<p>before</p>")
+	testutil.WriteTextFile(appPath, "config.json", `{"debug": false}`)
+
+	ws := source.NewWorkspace(workspacePath)
+	builds := map[string]*config.RuntimeConfig{
+		"app": config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_app.json"), "app"),
+	}
+	buildSet, err := bundle.CreateBuildSet(ws, builds)
+	assert.Nil(t, err)
+	buildSet.NotifyChanges(nil)
+
+	server, _ := createWebServer(workspacePath)
+	return NewHotReloader(server, ws, buildSet), buildSet
+}
+
+func TestTemplateReload(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	hot, buildSet := createValueHotReloader(t, workspacePath)
+	go hot.server.hub.run()
+	defer hot.server.hub.stop()
+	client := connectTestClient(hot.server.hub, &ClientRegistration{Bundles: []string{"/app/main.js"}})
+
+	templateFilepath := testutil.WriteTextFile(filepath.Join(workspacePath, "app"), "template.html", "<p>after</p>")
+	changes := monitor.NewEventChangeset()
+	changes.Add(notify.Write, templateFilepath)
+	buildSet.NotifyChanges(changes)
+	hot.NotifyReload(changes)
+
+	payload := receivePayload(t, client)
+	assert.NotNil(t, payload)
+	assert.Equal(t, "reload-template", payload.Type)
+	data := &ReloadTemplatePayloadData{}
+	assert.Nil(t, json.Unmarshal([]byte(payload.Data), data))
+	assert.Equal(t, &ReloadTemplatePayloadData{"app/template.html", "<p>after</p>"}, data)
+}
+
+func TestJSONValueReload(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	hot, buildSet := createValueHotReloader(t, workspacePath)
+	go hot.server.hub.run()
+	defer hot.server.hub.stop()
+	client := connectTestClient(hot.server.hub, &ClientRegistration{Bundles: []string{"/app/main.js"}})
+
+	jsonFilepath := testutil.WriteTextFile(filepath.Join(workspacePath, "app"), "config.json", "{\n  \"debug\": true\n}")
+	changes := monitor.NewEventChangeset()
+	changes.Add(notify.Write, jsonFilepath)
+	buildSet.NotifyChanges(changes)
+	hot.NotifyReload(changes)
+
+	payload := receivePayload(t, client)
+	assert.NotNil(t, payload)
+	assert.Equal(t, "reload-value", payload.Type)
+	assert.JSONEq(t, `{"id": "app/config.json", "value": "{\"debug\":true}"}`, payload.Data)
+}
+
+func TestInvalidJSONCausesFullReload(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	hot, buildSet := createValueHotReloader(t, workspacePath)
+
+	jsonFilepath := testutil.WriteTextFile(filepath.Join(workspacePath, "app"), "config.json", `{"debug": }`)
+	changes := monitor.NewEventChangeset()
+	changes.Add(notify.Write, jsonFilepath)
+	buildSet.NotifyChanges(changes)
+	assert.False(t, hot.reloadValues(changes))
+}
+
+func TestRemovedTemplateCausesFullReload(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	hot, _ := createValueHotReloader(t, workspacePath)
+
+	templateFilepath := filepath.Join(workspacePath, "app", "template.html")
+	assert.Nil(t, os.Remove(templateFilepath))
+	changes := monitor.NewEventChangeset()
+	changes.Add(notify.Remove, templateFilepath)
+	assert.False(t, hot.reloadValues(changes))
+}
+
+func TestCSSReloadPerBaseHref(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	buildPath := testutil.MakeSubdirectoryTree(workspacePath, "build")
+	testutil.WriteTextFile(buildPath, "systemjs_build_app.json", `{"modules": [{ "name": "app/main" }], "base": ""}`)
+	testutil.WriteTextFile(buildPath, "systemjs_build_admin.json", `{"modules": [{ "name": "admin/panel/main" }], "base": ""}`)
+	testutil.WriteTextFile(testutil.MakeSubdirectoryTree(workspacePath, "app"), "main.js", `System.register(["../common/style.css"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(testutil.MakeSubdirectoryTree(workspacePath, "admin/panel"), "main.js", `System.register(["../../common/style.css"], function (exports_1, context_1) {`)
+	commonPath := testutil.MakeSubdirectoryTree(workspacePath, "common")
+	styleFilepath := testutil.WriteTextFile(commonPath, "style.css", "a { color: red; }")
+
+	ws := source.NewWorkspace(workspacePath)
+	builds := map[string]*config.RuntimeConfig{
+		"app":   config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_app.json"), "app"),
+		"admin": config.NewRuntimeConfig(filepath.Join(buildPath, "systemjs_build_admin.json"), "admin/panel"),
+	}
+	buildSet, err := bundle.CreateBuildSet(ws, builds)
+	assert.Nil(t, err)
+	buildSet.NotifyChanges(nil)
+
+	server, _ := createWebServer(workspacePath)
+	hot := NewHotReloader(server, ws, buildSet)
+	go hot.server.hub.run()
+	defer hot.server.hub.stop()
+	appClient := connectTestClient(hot.server.hub, &ClientRegistration{Bundles: []string{"/app/main.js"}})
+	adminClient := connectTestClient(hot.server.hub, &ClientRegistration{Bundles: []string{"/admin/panel/main.js"}})
+
+	// url()s are resolved against the page, so each build's pages need CSS rewritten for their base href
+	testutil.WriteTextFile(commonPath, "style.css", "a { background: url(img.png); }")
+	changes := monitor.NewEventChangeset()
+	changes.Add(notify.Write, styleFilepath)
+	buildSet.NotifyChanges(changes)
+	hot.NotifyReload(changes)
+
+	for client, expected := range map[*SocketClient]string{
+		appClient:   "a { background: url(../common/img.png); }",
+		adminClient: "a { background: url(../../common/img.png); }",
+	} {
+		payload := receivePayload(t, client)
+		if assert.NotNil(t, payload) {
+			assert.Equal(t, "reload-css", payload.Type)
+			data := &ReloadCSSPayloadData{}
+			assert.Nil(t, json.Unmarshal([]byte(payload.Data), data))
+			assert.Equal(t, source.CSSPrefix+"common/style.css", data.ID)
+			assert.True(t, strings.HasPrefix(data.CSS, expected+"\n"), data.CSS)
+		}
+		assert.Nil(t, receivePayload(t, client))
+	}
+}
@@ -0,0 +1,144 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/logging"
+)
+
+// requestPathKey is the context key of the path a proxied request was made for, before it was rewritten for the upstream
+type requestPathKey struct{}
+
+// newProxyHandler creates a reverse proxy that forwards requests beneath a path prefix to a route's upstream
+func newProxyHandler(prefix string, route *config.ProxyRoute) (http.Handler, error) {
+	target, err := route.TargetURL()
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	director := func(r *http.Request) {
+		originalHost := r.Host
+		upstreamPath := r.URL.Path
+		if route.StripPrefix {
+			upstreamPath = strings.TrimPrefix(upstreamPath, prefix)
+		}
+
+		r.URL.Scheme = target.Scheme
+		r.URL.Host = target.Host
+		r.URL.Path = joinURLPaths(target.Path, upstreamPath)
+		r.URL.RawPath = ""
+		if target.RawQuery != "" {
+			if r.URL.RawQuery == "" {
+				r.URL.RawQuery = target.RawQuery
+			} else {
+				r.URL.RawQuery = target.RawQuery + "&" + r.URL.RawQuery
+			}
+		}
+
+		if route.ChangeOrigin {
+			r.Host = target.Host
+		}
+		r.Header.Set("X-Forwarded-Host", originalHost)
+		if r.TLS != nil {
+			r.Header.Set("X-Forwarded-Proto", "https")
+		} else {
+			r.Header.Set("X-Forwarded-Proto", "http")
+		}
+		setHeaders(r.Header, route.Headers)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = route.Timeout()
+	if route.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director:  director,
+		Transport: transport,
+		ModifyResponse: func(response *http.Response) error {
+			setHeaders(response.Header, route.ResponseHeaders)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			status := http.StatusBadGateway
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				status = http.StatusGatewayTimeout
+			}
+			// r is the request to the upstream, so the path that was requested is remembered in its context
+			requestPath, _ := r.Context().Value(requestPathKey{}).(string)
+			logging.Error("proxy", logging.Fields{"path": requestPath, "upstream": r.URL.String(), "status": status, "error": err}, "Failed to proxy %s to %s: %s", requestPath, r.URL.String(), err)
+			w.WriteHeader(status)
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestPathKey{}, r.URL.Path)))
+	}), nil
+}
+
+// setHeaders sets headers to new values, or removes them where the value is ""
+func setHeaders(header http.Header, values map[string]string) {
+	for name, value := range values {
+		if value == "" {
+			header.Del(name)
+		} else {
+			header.Set(name, value)
+		}
+	}
+}
+
+// joinURLPaths joins an upstream's base path to a request path, e.g. "/v2" + "/users" ==> "/v2/users"
+func joinURLPaths(basePath string, requestPath string) string {
+	if basePath == "" || basePath == "/" {
+		if requestPath == "" {
+			return "/"
+		}
+		return requestPath
+	}
+	if requestPath == "" || requestPath == "/" {
+		return basePath
+	}
+	joined := path.Join(basePath, requestPath)
+	if strings.HasSuffix(requestPath, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+// attachProxyHandlers mounts a reverse proxy for each proxy route, at both /prefix and /prefix/...
+func (server *Server) attachProxyHandlers(mux *http.ServeMux) {
+	var prefixes []string
+	for prefix := range server.proxyRoutes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	for _, prefix := range prefixes {
+		route := server.proxyRoutes[prefix]
+		rootedPrefix := path.Join("/", prefix)
+		if route == nil || rootedPrefix == "/" {
+			logging.Error("proxy-config", nil, "Invalid proxy route '%s' (a prefix and target are needed, e.g. /api)", prefix)
+			continue
+		}
+
+		handler, err := newProxyHandler(rootedPrefix, route)
+		if err != nil {
+			logging.Error("proxy-config", logging.Fields{"error": err}, "Invalid proxy target for %s: %s", rootedPrefix, err)
+			continue
+		}
+		mux.Handle(rootedPrefix, handler)
+		mux.Handle(rootedPrefix+"/", handler)
+		logging.Info("proxy", nil, "Proxying %s to %s", rootedPrefix, route.Target)
+	}
+}
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/logging"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createProxyServer starts a swarm server (without files) whose proxy routes forward to an upstream
+func createProxyServer(routes map[string]*config.ProxyRoute) *httptest.Server {
+	server, mux := createWebServer("")
+	server.proxyRoutes = routes
+	server.attachProxyHandlers(mux)
+	return httptest.NewServer(mux)
+}
+
+func newEchoUpstream() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "upstream")
+		w.Header().Set("X-Upstream-Secret", "shh")
+		fmt.Fprintf(w, "%s %s?%s host=%s forwarded-host=%s auth=%s cookie=%s",
+			r.Method, r.URL.Path, r.URL.RawQuery, r.Host, r.Header.Get("X-Forwarded-Host"), r.Header.Get("Authorization"), r.Header.Get("Cookie"))
+	}))
+}
+
+func TestProxy(t *testing.T) {
+	upstream := newEchoUpstream()
+	defer upstream.Close()
+	upstreamHost := strings.TrimPrefix(upstream.URL, "http://")
+
+	proxy := createProxyServer(map[string]*config.ProxyRoute{
+		"/api":    {Target: upstream.URL},
+		"/strip/": {Target: upstream.URL + "/v2", StripPrefix: true},
+		"/origin": {Target: upstream.URL, ChangeOrigin: true},
+		"/headers": {
+			Target:          upstream.URL,
+			Headers:         map[string]string{"Authorization": "Bearer dev", "Cookie": ""},
+			ResponseHeaders: map[string]string{"X-Upstream-Secret": "", "Access-Control-Allow-Origin": "*"},
+		},
+	})
+	defer proxy.Close()
+	proxyHost := strings.TrimPrefix(proxy.URL, "http://")
+
+	cases := map[string]struct {
+		url      string
+		expected string
+	}{
+		"prefix-kept": {
+			url:      "/api/users?id=1",
+			expected: fmt.Sprintf("GET /api/users?id=1 host=%s forwarded-host=%s auth= cookie=session=1", proxyHost, proxyHost),
+		},
+		"prefix-exact": {
+			url:      "/api",
+			expected: fmt.Sprintf("GET /api? host=%s forwarded-host=%s auth= cookie=session=1", proxyHost, proxyHost),
+		},
+		"prefix-stripped-and-joined": {
+			url:      "/strip/users/",
+			expected: fmt.Sprintf("GET /v2/users/? host=%s forwarded-host=%s auth= cookie=session=1", proxyHost, proxyHost),
+		},
+		"change-origin": {
+			url:      "/origin/x",
+			expected: fmt.Sprintf("GET /origin/x? host=%s forwarded-host=%s auth= cookie=session=1", upstreamHost, proxyHost),
+		},
+		"request-headers": {
+			url:      "/headers",
+			expected: fmt.Sprintf("GET /headers? host=%s forwarded-host=%s auth=Bearer dev cookie=", proxyHost, proxyHost),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			request, _ := http.NewRequest("GET", proxy.URL+tc.url, nil)
+			request.Header.Set("Cookie", "session=1")
+			response, err := http.DefaultClient.Do(request)
+			assert.Nil(t, err)
+			defer response.Body.Close()
+
+			body, _ := ioutil.ReadAll(response.Body)
+			assert.Equal(t, tc.expected, string(body))
+		})
+	}
+
+	response, err := http.Get(proxy.URL + "/headers")
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, "", response.Header.Get("X-Upstream-Secret"))
+	assert.Equal(t, "*", response.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "upstream", response.Header.Get("Server"))
+}
+
+func TestProxyErrors(t *testing.T) {
+	slowUpstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "too late")
+	}))
+	defer slowUpstream.Close()
+	closedUpstream := httptest.NewServer(http.NotFoundHandler())
+	closedUpstream.Close()
+
+	proxy := createProxyServer(map[string]*config.ProxyRoute{
+		"/slow":    {Target: slowUpstream.URL, TimeoutMillis: 20},
+		"/patient": {Target: slowUpstream.URL, TimeoutMillis: 2000},
+		"/down":    {Target: closedUpstream.URL},
+	})
+	defer proxy.Close()
+
+	cases := map[string]struct {
+		url    string
+		status int
+	}{
+		"timeout":        {url: "/slow/x", status: http.StatusGatewayTimeout},
+		"within-timeout": {url: "/patient/x", status: http.StatusOK},
+		"unreachable":    {url: "/down/x", status: http.StatusBadGateway},
+		"not-proxied":    {url: "/slowly", status: http.StatusNotFound},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			response, err := http.Get(proxy.URL + tc.url)
+			assert.Nil(t, err)
+			response.Body.Close()
+			assert.Equal(t, tc.status, response.StatusCode)
+		})
+	}
+}
+
+func TestProxyErrorLogsRequestedPath(t *testing.T) {
+	closedUpstream := httptest.NewServer(http.NotFoundHandler())
+	closedUpstream.Close()
+	proxy := createProxyServer(map[string]*config.ProxyRoute{
+		"/api": {Target: closedUpstream.URL + "/v2", StripPrefix: true},
+	})
+	defer proxy.Close()
+
+	stopRecording := logging.Default().Record(logging.LevelError)
+	response, err := http.Get(proxy.URL + "/api/users")
+	entries := stopRecording()
+	assert.Nil(t, err)
+	response.Body.Close()
+
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "/api/users", entries[0].Fields["path"])
+		assert.Equal(t, closedUpstream.URL+"/v2/users", entries[0].Fields["upstream"])
+	}
+}
+
+func TestProxyWebsocket(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append([]byte(r.URL.Path+": "), message...))
+		}
+	}))
+	defer upstream.Close()
+
+	proxy := createProxyServer(map[string]*config.ProxyRoute{
+		"/live": {Target: strings.Replace(upstream.URL, "http://", "ws://", 1), StripPrefix: true, TimeoutMillis: 50},
+	})
+	defer proxy.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(proxy.URL, "http://", "ws://", 1)+"/live/feed", nil)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	// the connection outlives the route's timeout, which only applies to waiting for the upgrade
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	_, message, err := conn.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, "/feed: hello", string(message))
+}
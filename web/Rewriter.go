@@ -0,0 +1,149 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/logging"
+	"github.com/mrcrowl/swarm/monitor"
+	"github.com/mrcrowl/swarm/util"
+)
+
+// Rewriter applies the configured rewrite rules to responses
+type Rewriter struct {
+	rules []*rewriteRule
+}
+
+type rewriteRule struct {
+	paths   *monitor.PathMatcher
+	re      *regexp.Regexp // nil for a literal match
+	match   string
+	replace string
+	limit   int
+}
+
+// NewRewriter compiles a list of rewrite rules
+func NewRewriter(rules []*config.RewriteRule) (*Rewriter, error) {
+	rewriter := &Rewriter{}
+	for _, rule := range rules {
+		if rule == nil || rule.Path == "" || rule.Match == "" {
+			return nil, fmt.Errorf("Invalid rewrite rule: a path and match are needed")
+		}
+		re, err := rule.CompileMatch()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid rewrite regex '%s': %s", rule.Match, err)
+		}
+		rewriter.rules = append(rewriter.rules, &rewriteRule{
+			paths:   monitor.NewPathMatcher([]string{rule.Path}),
+			re:      re,
+			match:   rule.Match,
+			replace: rule.Replace,
+			limit:   rule.Limit,
+		})
+	}
+	return rewriter, nil
+}
+
+// Matches tests whether any rule applies to a URL path
+func (rewriter *Rewriter) Matches(urlPath string) bool {
+	if rewriter == nil {
+		return false
+	}
+	for _, rule := range rewriter.rules {
+		if rule.paths.Matches(urlPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rewrite applies each rule which matches a URL path to the contents of its response, in order
+func (rewriter *Rewriter) Rewrite(urlPath string, contents string) string {
+	if rewriter == nil {
+		return contents
+	}
+	for _, rule := range rewriter.rules {
+		if rule.paths.Matches(urlPath) {
+			contents = rule.apply(contents)
+		}
+	}
+	return contents
+}
+
+func (rule *rewriteRule) apply(contents string) string {
+	n := rule.limit
+	if n <= 0 {
+		n = -1
+	}
+
+	if rule.re == nil {
+		return strings.Replace(contents, rule.match, rule.replace, n)
+	}
+	if n < 0 {
+		return rule.re.ReplaceAllString(contents, rule.replace)
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, submatches := range rule.re.FindAllStringSubmatchIndex(contents, n) {
+		sb.WriteString(contents[last:submatches[0]])
+		sb.Write(rule.re.ExpandString(nil, rule.replace, contents, submatches))
+		last = submatches[1]
+	}
+	sb.WriteString(contents[last:])
+	return sb.String()
+}
+
+// rewritingFileServer serves static files, rewriting those that any rule applies to
+func (server *Server) rewritingFileServer(fileServer http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !server.rewriter.Matches(r.URL.Path) {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		absFilepath := filepath.Join(server.rootFilepath, filepath.FromSlash(r.URL.Path))
+		if info, err := os.Stat(absFilepath); err != nil || info.IsDir() {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		bytes, err := ioutil.ReadFile(absFilepath)
+		if err != nil {
+			logging.Error("rewrite", logging.Fields{"file": absFilepath, "error": err}, "Failed to read %s for rewriting: %s", absFilepath, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", util.MimeTypeFromFilename(absFilepath))
+		io.WriteString(w, server.rewriter.Rewrite(r.URL.Path, string(bytes)))
+	})
+}
+
+// rewriteResponse runs a handler with its response buffered, so that it can be rewritten
+func (server *Server) rewriteResponse(handler http.HandlerFunc, w http.ResponseWriter, r *http.Request) {
+	buffer := &bufferedResponseWriter{header: w.Header(), status: http.StatusOK}
+	handler(buffer, r)
+
+	w.Header().Del("Content-Length")
+	w.WriteHeader(buffer.status)
+	io.WriteString(w, server.rewriter.Rewrite(r.URL.Path, buffer.body.String()))
+}
+
+// bufferedResponseWriter holds onto a response until it's complete
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (buffer *bufferedResponseWriter) Header() http.Header            { return buffer.header }
+func (buffer *bufferedResponseWriter) Write(data []byte) (int, error) { return buffer.body.Write(data) }
+func (buffer *bufferedResponseWriter) WriteHeader(statusCode int)     { buffer.status = statusCode }
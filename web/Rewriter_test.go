@@ -0,0 +1,116 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewrite(t *testing.T) {
+	cases := map[string]struct {
+		rule     config.RewriteRule
+		urlPath  string
+		contents string
+		expected string
+	}{
+		"literal": {
+			rule:     config.RewriteRule{Path: "*.js", Match: "./common", Replace: "../common"},
+			urlPath:  "/app/main.js",
+			contents: `import "./common"; import "./common/x";`,
+			expected: `import "../common"; import "../common/x";`,
+		},
+		"literal-limit": {
+			rule:     config.RewriteRule{Path: "*.js", Match: "a", Replace: "b", Limit: 2},
+			urlPath:  "/main.js",
+			contents: "aaaa",
+			expected: "bbaa",
+		},
+		"regex-limit": {
+			rule:     config.RewriteRule{Path: "*.map", Match: `"(\w+)\.ts"`, Regex: true, Replace: `"src/$1.ts"`, Limit: 1},
+			urlPath:  "/app/main.js.map",
+			contents: `["One.ts","Two.ts"]`,
+			expected: `["src/One.ts","Two.ts"]`,
+		},
+		"anchored-path": {
+			rule:     config.RewriteRule{Path: "/app/index.html", Match: "{{title}}", Replace: "Swarm"},
+			urlPath:  "/app/index.html",
+			contents: "<title>{{title}}</title>",
+			expected: "<title>Swarm</title>",
+		},
+		"path-not-matched": {
+			rule:     config.RewriteRule{Path: "/app/index.html", Match: "{{title}}", Replace: "Swarm"},
+			urlPath:  "/other/app/index.html",
+			contents: "<title>{{title}}</title>",
+			expected: "<title>{{title}}</title>",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rule := tc.rule
+			rewriter, err := NewRewriter([]*config.RewriteRule{&rule})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, rewriter.Rewrite(tc.urlPath, tc.contents))
+		})
+	}
+}
+
+func TestNewRewriterErrors(t *testing.T) {
+	_, err := NewRewriter([]*config.RewriteRule{{Path: "*.js", Match: "(", Regex: true}})
+	assert.NotNil(t, err)
+	_, err = NewRewriter([]*config.RewriteRule{{Path: "*.js"}})
+	assert.NotNil(t, err)
+
+	var rewriter *Rewriter
+	assert.False(t, rewriter.Matches("/main.js"))
+	assert.Equal(t, "unchanged", rewriter.Rewrite("/main.js", "unchanged"))
+}
+
+func TestRewritingFileServer(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+	appDir := testutil.MakeSubdirectoryTree(tempDir, "app")
+	testutil.WriteTextFile(appDir, "systemjs.config.js", systemJSExample)
+	testutil.WriteTextFile(appDir, "other.js", `"./common",`)
+	server, mux := createWebServer(tempDir)
+	server.rewriter, _ = NewRewriter([]*config.RewriteRule{
+		{Path: "systemjs.config.js", Match: `"\./(common|services|utils)",`, Regex: true, Replace: `"../$1", /* <-- REWRITTEN BY SWARM */`},
+	})
+	server.attachStaticFileServer(mux)
+
+	serve := func(url string) *MockWriter {
+		request, _ := http.NewRequest("GET", url, nil)
+		writer := newMockWriter()
+		mux.ServeHTTP(writer, request)
+		return writer
+	}
+
+	writer := serve("/app/systemjs.config.js")
+	assert.Equal(t, systemJSExpected, writer.sb.String())
+	assert.Equal(t, "application/javascript", writer.ContentType())
+	assert.Equal(t, `"./common",`, serve("/app/other.js").sb.String())
+}
+
+func TestRewriteCustomHandlers(t *testing.T) {
+	server, mux := createWebServer("")
+	server.rewriter, _ = NewRewriter([]*config.RewriteRule{
+		{Path: "*.map", Match: `["BaseController.ts"]`, Replace: `["ui/base/BaseController.ts"]`},
+	})
+	server.SetHandlers(map[string]http.HandlerFunc{
+		"/app/main.js.map": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"sources":["BaseController.ts"]}`)
+		},
+	})
+	dispatcher := server.customHandlerDispatcher(mux)
+
+	request, _ := http.NewRequest("GET", "/app/main.js.map", nil)
+	writer := newMockWriter()
+	dispatcher.ServeHTTP(writer, request)
+	assert.Equal(t, `{"sources":["ui/base/BaseController.ts"]}`, writer.sb.String())
+	assert.Equal(t, "application/json", writer.ContentType())
+}
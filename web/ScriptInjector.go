@@ -1,29 +1,195 @@
 package web
 
 import (
+	"bytes"
+	"regexp"
+	"sort"
 	"strings"
+
+	"golang.org/x/net/html"
 )
 
-// InjectSrcJavascript injects a script into an HTML page, just above the closing </body> tag
-func InjectSrcJavascript(html string, src string, isModule bool) string {
-	injection := createSrcScript(src, isModule)
-	return injectBeforeClosingBody(html, injection)
+// InjectSrcJavascript injects a script into an HTML page, at the end of the <body>
+func InjectSrcJavascript(page string, src string, isModule bool) string {
+	injector := NewHTMLInjector()
+	injector.AppendBody(createSrcScript(src, isModule))
+	return injector.Inject(page)
+}
+
+// InjectInlineJavascript injects a snippet of script into an HTML page, at the end of the <body>
+func InjectInlineJavascript(page string, script string, isModule bool) string {
+	injector := NewHTMLInjector()
+	injector.AppendBody(createInlineScript(script, isModule))
+	return injector.Inject(page)
+}
+
+// HTMLInjector collects tags to inject into an HTML page, then injects them all at once.  The page is tokenised
+// (rather than searched) to find where the tags go, but is otherwise left exactly as it was written
+type HTMLInjector struct {
+	baseHref string // "" to leave any <base> tag alone
+	head     []string
+	body     []string
+}
+
+// NewHTMLInjector creates an injector with nothing to inject
+func NewHTMLInjector() *HTMLInjector {
+	return &HTMLInjector{}
+}
+
+// SetBaseHref makes the page's <base href> match a path, adding a <base> tag to the <head> if there isn't one
+func (injector *HTMLInjector) SetBaseHref(href string) {
+	injector.baseHref = href
+}
+
+// AppendHead adds a tag to inject at the end of the <head>
+func (injector *HTMLInjector) AppendHead(tag string) {
+	injector.head = append(injector.head, tag)
+}
+
+// AppendBody adds a tag to inject at the end of the <body>
+func (injector *HTMLInjector) AppendBody(tag string) {
+	injector.body = append(injector.body, tag)
+}
+
+// insertion is text to splice into a page, replacing the bytes from start to end (start == end for a pure insert)
+type insertion struct {
+	start int
+	end   int
+	text  string
+}
+
+// Inject adds the collected tags to a page.  Pages without <head> or <body> tags still get the tags where a
+// browser would put those elements
+func (injector *HTMLInjector) Inject(page string) string {
+	landmarks := findHTMLLandmarks(page)
+
+	var insertions []insertion
+	if injector.baseHref != "" {
+		if landmarks.base != nil {
+			insertions = append(insertions, insertion{landmarks.base.start, landmarks.base.end, createBaseTag(injector.baseHref, landmarks.base.attrs)})
+		} else {
+			insertions = append(insertions, insertion{landmarks.headStart, landmarks.headStart, createBaseTag(injector.baseHref, nil)})
+		}
+	}
+	if len(injector.head) > 0 {
+		insertions = append(insertions, insertion{landmarks.headEnd, landmarks.headEnd, strings.Join(injector.head, "")})
+	}
+	if len(injector.body) > 0 {
+		insertions = append(insertions, insertion{landmarks.bodyEnd, landmarks.bodyEnd, strings.Join(injector.body, "")})
+	}
+
+	// splice from the end backwards, so that earlier offsets stay valid, and insertions at one offset stay in order
+	sort.SliceStable(insertions, func(i, j int) bool { return insertions[i].start < insertions[j].start })
+	for i := len(insertions) - 1; i >= 0; i-- {
+		ins := insertions[i]
+		page = page[:ins.start] + ins.text + page[ins.end:]
+	}
+	return page
+}
+
+// htmlLandmarks are the byte offsets within a page where tags get injected
+type htmlLandmarks struct {
+	headStart int // just after <head>
+	headEnd   int // just before </head>
+	bodyEnd   int // just before </body>
+	base      *htmlTag
 }
 
-// InjectInlineJavascript injects a snippet of script into an HTML page, just above the closing </body> tag
-func InjectInlineJavascript(html string, script string, isModule bool) string {
-	injection := createInlineScript(script, isModule)
-	return injectBeforeClosingBody(html, injection)
+type htmlTag struct {
+	start int
+	end   int
+	attrs []html.Attribute
 }
 
-func injectBeforeClosingBody(html string, text string) string {
-	closingBodyPos := strings.LastIndex(html, "</body>")
-	if closingBodyPos > 0 {
-		injectedHTML := html[:closingBodyPos] + text + html[closingBodyPos:]
-		return injectedHTML
+func findHTMLLandmarks(page string) *htmlLandmarks {
+	htmlStart, headStart, headEnd, bodyStart, bodyEnd, htmlEnd := -1, -1, -1, -1, -1, -1
+	var base *htmlTag
+
+	tokenizer := html.NewTokenizer(strings.NewReader(page))
+	offset := 0
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		start := offset
+		offset += len(tokenizer.Raw())
+		if tokenType == html.SelfClosingTagToken {
+			tokenizer.NextIsNotRawText() // be lenient with e.g. <title />, which would otherwise swallow the page
+		}
+		if tokenType != html.StartTagToken && tokenType != html.EndTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, _ := tokenizer.TagName()
+		isEnd := tokenType == html.EndTagToken
+		switch {
+		case string(name) == "html" && !isEnd && htmlStart < 0:
+			htmlStart = offset
+		case string(name) == "html" && isEnd:
+			htmlEnd = start
+		case string(name) == "head" && !isEnd && headStart < 0:
+			headStart = offset
+		case string(name) == "head" && isEnd && headEnd < 0:
+			headEnd = start
+		case string(name) == "body" && !isEnd && bodyStart < 0:
+			bodyStart = start
+		case string(name) == "body" && isEnd:
+			bodyEnd = start // the last one wins
+		case string(name) == "base" && !isEnd && base == nil && bodyStart < 0:
+			base = &htmlTag{start: start, end: offset, attrs: tokenAttrs(tokenizer)}
+		}
 	}
 
-	return html + "<!-- COULD NOT FIND CLOSING BODY TAG TO INJECT -->"
+	landmarks := &htmlLandmarks{base: base}
+	landmarks.bodyEnd = firstOffset(bodyEnd, htmlEnd, len(page))
+	landmarks.headStart = firstOffset(headStart, htmlStart, 0)
+	landmarks.headEnd = firstOffset(headEnd, bodyStart, landmarks.headStart)
+	if landmarks.headStart > landmarks.headEnd {
+		landmarks.headStart = landmarks.headEnd
+	}
+	return landmarks
+}
+
+func tokenAttrs(tokenizer *html.Tokenizer) []html.Attribute {
+	var attrs []html.Attribute
+	for {
+		key, val, more := tokenizer.TagAttr()
+		if len(key) > 0 {
+			attrs = append(attrs, html.Attribute{Key: string(key), Val: string(val)})
+		}
+		if !more {
+			return attrs
+		}
+	}
+}
+
+// firstOffset gets the first offset that was found (i.e. isn't negative)
+func firstOffset(offsets ...int) int {
+	for _, offset := range offsets {
+		if offset >= 0 {
+			return offset
+		}
+	}
+	return 0
+}
+
+// createBaseTag creates a <base> tag for an href, keeping any other attributes (e.g. target) of the original tag
+func createBaseTag(href string, attrs []html.Attribute) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(`<base href="` + html.EscapeString(href) + `"`)
+	for _, attr := range attrs {
+		if attr.Key != "href" {
+			buffer.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+		}
+	}
+	buffer.WriteString(">")
+	return buffer.String()
+}
+
+// createPreloadLink creates a <link> tag which asks the browser to start fetching a script straight away
+func createPreloadLink(href string) string {
+	return `<link rel="preload" href="` + html.EscapeString(href) + `" as="script">`
 }
 
 func createInlineScript(inline string, isModule bool) string {
@@ -32,7 +198,7 @@ func createInlineScript(inline string, isModule bool) string {
 		typ = "module"
 	}
 
-	return `<script type="` + typ + `">` + inline + `</script>`
+	return `<script type="` + typ + `">` + escapeInlineScript(inline) + `</script>`
 }
 
 func createSrcScript(src string, isModule bool) string {
@@ -41,5 +207,12 @@ func createSrcScript(src string, isModule bool) string {
 		typ = "module"
 	}
 
-	return `<script type="` + typ + `" src="` + src + `"></script>`
+	return `<script type="` + typ + `" src="` + html.EscapeString(src) + `"></script>`
+}
+
+var closingScriptPattern = regexp.MustCompile(`(?i)</(script)`)
+
+// escapeInlineScript stops an inline script from closing its <script> tag early
+func escapeInlineScript(script string) string {
+	return closingScriptPattern.ReplaceAllString(script, `<\/$1`)
 }
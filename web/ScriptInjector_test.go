@@ -1,7 +1,6 @@
 package web
 
 import (
-	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -31,9 +30,60 @@ func TestInjectInlineJavascriptRegular(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
-func TestMissingClosingBodyAppendsComment(t *testing.T) {
-	html := `<html><head><title /></head><body><div></div></html>`
-	actual := InjectInlineJavascript(html, "alert('Hello, world.');", true)
-	assert.True(t, strings.HasPrefix(actual[len(html):], "<!--"))
-	assert.True(t, strings.HasSuffix(actual, "-->"))
+func TestMissingClosingBodyInjectsAtEnd(t *testing.T) {
+	actual := InjectInlineJavascript(`<html><head><title /></head><body><div></div></html>`, "alert('Hello, world.');", true)
+	expected := `<html><head><title /></head><body><div></div><script type="module">alert('Hello, world.');</script></html>`
+	assert.Equal(t, expected, actual)
+}
+
+func TestInjectInlineJavascriptEscapesClosingScript(t *testing.T) {
+	actual := InjectInlineJavascript(`<body></body>`, "document.write('</SCRIPT>');", false)
+	expected := `<body><script type="text/javascript">document.write('<\/SCRIPT>');</script></body>`
+	assert.Equal(t, expected, actual)
+}
+
+func TestHTMLInjector(t *testing.T) {
+	cases := map[string]struct {
+		html     string
+		baseHref string
+		expected string
+	}{
+		"head-and-body": {
+			html:     `<html><head><title>Hi</title></head><body><div></div></body></html>`,
+			expected: `<html><head><title>Hi</title><H></head><body><div></div><B></body></html>`,
+		},
+		"base-href-replaced": {
+			html:     `<html><head><BASE target="_blank" href="app"><title>Hi</title></head><body></body></html>`,
+			baseHref: "/app/",
+			expected: `<html><head><base href="/app/" target="_blank"><title>Hi</title><H></head><body><B></body></html>`,
+		},
+		"base-href-added": {
+			html:     `<html><head lang="en"><title>Hi</title></head><body></body></html>`,
+			baseHref: "/app/",
+			expected: `<html><head lang="en"><base href="/app/"><title>Hi</title><H></head><body><B></body></html>`,
+		},
+		"no-head": {
+			html:     `<!DOCTYPE html><html><body>Hi</body></html>`,
+			baseHref: "/",
+			expected: `<!DOCTYPE html><html><base href="/"><H><body>Hi<B></body></html>`,
+		},
+		"no-tags": {
+			html:     `Hi`,
+			baseHref: "/",
+			expected: `<base href="/"><H>Hi<B>`,
+		},
+		"tags-in-comments-and-scripts-ignored": {
+			html:     `<head><!-- </head> --></head><body><script>var s = "</body>";</script></body><!-- </body> -->`,
+			expected: `<head><!-- </head> --><H></head><body><script>var s = "</body>";</script><B></body><!-- </body> -->`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			injector := NewHTMLInjector()
+			injector.SetBaseHref(tc.baseHref)
+			injector.AppendHead("<H>")
+			injector.AppendBody("<B>")
+			assert.Equal(t, tc.expected, injector.Inject(tc.html))
+		})
+	}
 }
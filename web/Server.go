@@ -6,19 +6,24 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"os"
 	"path"
 	"path/filepath"
-	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"github.com/mrcrowl/swarm/assets"
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/events"
+	"github.com/mrcrowl/swarm/logging"
+	"github.com/mrcrowl/swarm/monitor"
 	"github.com/mrcrowl/swarm/source"
 	"github.com/mrcrowl/swarm/util"
 	"time"
 )
 
 const indexhtml = "index.html"
-const systemJSConfigJS = "systemjs.config.js"
 const swarmVirtualPath = "/__swarm__"
 const assetsPhysicalPath = "/assets/static"
 const hotReloadFilename = "HotReload.js"
@@ -30,10 +35,21 @@ const webSocketServerPath = swarmVirtualPath + "/ws"
 type Server struct {
 	srv          *http.Server
 	rootFilepath string
-	basePath     string
+	basePaths    []string
 	port         uint16
 	handlers     map[string]http.HandlerFunc
+	handlersLock *sync.RWMutex
 	hub          *SocketHub
+	https        bool
+	certFile     string
+	keyFile      string
+	proxyRoutes  map[string]*config.ProxyRoute
+	fallback     *monitor.PathMatcher // paths excluded from the history API fallback, or nil if it's disabled
+	rewriter     *Rewriter
+	inject       *config.InjectConfig
+	events       *events.Bus
+	socketEvents *events.Subscription // forwarded to the websocket clients
+	shutdown     chan struct{}        // closed when the server shuts down, to end event streams
 }
 
 // DefaultPort will be automatically assigned, if no port is specified in the options
@@ -59,10 +75,26 @@ func CreateServer(opts *ServerOptions) *Server {
 	server := &Server{
 		srv:          nil,
 		rootFilepath: opts.RootFilepath,
-		basePath:     opts.BasePath,
+		basePaths:    opts.BasePaths,
 		port:         port,
 		handlers:     opts.Handlers,
+		handlersLock: &sync.RWMutex{},
 		hub:          hub,
+		https:        opts.HTTPS,
+		certFile:     opts.CertFile,
+		keyFile:      opts.KeyFile,
+		proxyRoutes:  opts.Proxy,
+		inject:       opts.Inject,
+		events:       events.Default(),
+		shutdown:     make(chan struct{}),
+	}
+	if opts.HistoryFallback != nil {
+		server.fallback = monitor.NewPathMatcher(opts.HistoryFallback.Exclude)
+	}
+	if rewriter, err := NewRewriter(opts.Rewrite); err != nil {
+		logging.Error("rewrite-config", logging.Fields{"error": err}, "%s (rewrite rules are disabled)", err)
+	} else {
+		server.rewriter = rewriter
 	}
 
 	return server
@@ -73,61 +105,71 @@ func (server *Server) Start() {
 	mux := http.NewServeMux()
 
 	fileServer := server.attachStaticFileServer(mux)
-	server.attachSystemJSRewriteHandler(mux)
-	server.attachCustomHandlers(mux)
+	server.attachProxyHandlers(mux)
+	server.attachEventStream(mux)
+
+	if server.isIndexInjected() || server.fallback != nil {
+		for _, basePath := range server.basePaths {
+			server.attachIndexInjectionListener(mux, fileServer, basePath)
+		}
+	}
 
 	if server.hub != nil {
 		// add HMR support
-		server.attachIndexInjectionListener(mux, fileServer)
 		server.attachWebSocketListeners(mux, server.hub)
 		go server.hub.run()
+		server.socketEvents = server.events.Subscribe()
+		go server.forwardEventsToWebsocket(server.socketEvents)
 	}
 
 	server.srv = &http.Server{
 		Addr:    makeServerAddress(server.port),
-		Handler: mux,
+		Handler: server.customHandlerDispatcher(mux),
 	}
+	server.srv.RegisterOnShutdown(func() { close(server.shutdown) })
 
-	if err := server.srv.ListenAndServe(); err != nil {
-		panic(err)
+	var err error
+	if server.https {
+		err = server.srv.ListenAndServeTLS(server.certFile, server.keyFile)
+	} else {
+		err = server.srv.ListenAndServe()
 	}
-}
-
-func (server *Server) attachCustomHandlers(mux *http.ServeMux) {
-	for url, handler := range server.handlers {
-		mux.HandleFunc(url, handler)
+	if err != nil && err != http.ErrServerClosed {
+		panic(err)
 	}
 }
 
-func (server *Server) attachStaticFileServer(mux *http.ServeMux) http.Handler {
-	fileServer := http.FileServer(http.Dir(server.rootFilepath))
-	mux.Handle("/", fileServer)
-	return fileServer
-}
-
-func (server *Server) attachSystemJSRewriteHandler(mux *http.ServeMux) {
-	systemJSFilepath := filepath.Join(server.rootFilepath, server.basePath, systemJSConfigJS)
-	handler := func(w http.ResponseWriter, r *http.Request) {
-		bytes, err := ioutil.ReadFile(systemJSFilepath)
-		if err != nil {
-			log.Printf("ERROR: Failed to load SystemJS config at: %s", systemJSFilepath)
+// customHandlerDispatcher serves requests with the custom handlers (e.g. bundles) where one matches the path exactly,
+// otherwise it falls back to the mux.  Unlike a mux, the custom handlers can be swapped while the server is running
+func (server *Server) customHandlerDispatcher(fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.handlersLock.RLock()
+		handler, found := server.handlers[r.URL.Path]
+		server.handlersLock.RUnlock()
+
+		if found {
+			if server.rewriter.Matches(r.URL.Path) {
+				server.rewriteResponse(handler, w, r)
+			} else {
+				handler(w, r)
+			}
 			return
 		}
-		configJS := string(bytes)
-		rewrittenConfigJS := rewriteSystemJSConfigPaths(configJS)
-		mimeType := util.MimeTypeFromFilename(systemJSFilepath)
-		w.Header().Set("Content-Type", mimeType)
-		io.WriteString(w, rewrittenConfigJS)
-		return
-	}
-	systemJSPath := path.Join("/", server.basePath, systemJSConfigJS)
-	mux.HandleFunc(systemJSPath, handler)
+		fallback.ServeHTTP(w, r)
+	})
 }
 
-var rewriteSystemJSPattern = regexp.MustCompile(`"\.\/(common|services|utils)",`)
+// SetHandlers replaces the custom handlers, e.g. after the modules in a build have changed
+func (server *Server) SetHandlers(handlers map[string]http.HandlerFunc) {
+	server.handlersLock.Lock()
+	server.handlers = handlers
+	server.handlersLock.Unlock()
+}
 
-func rewriteSystemJSConfigPaths(systemJSConfig string) string {
-	return rewriteSystemJSPattern.ReplaceAllString(systemJSConfig, `"../$1", /* <-- REWRITTEN BY SWARM */`)
+func (server *Server) attachStaticFileServer(mux *http.ServeMux) http.Handler {
+	fileServer := server.rewritingFileServer(http.FileServer(http.Dir(server.rootFilepath)))
+	mux.Handle("/", fileServer)
+	return fileServer
 }
 
 func loadAssetString(assetFilename string) string {
@@ -157,42 +199,151 @@ func (server *Server) attachWebSocketListeners(mux *http.ServeMux, hub *SocketHu
 	})
 }
 
-func (server *Server) attachIndexInjectionListener(mux *http.ServeMux, fileServer http.Handler) {
-	rootedBasePath := path.Join("/", server.basePath)
+func (server *Server) attachIndexInjectionListener(mux *http.ServeMux, fileServer http.Handler, basePath string) {
+	rootedBasePath := path.Join("/", basePath)
 	acceptedIndexPaths := []string{
 		rootedBasePath,
 		rootedBasePath + "/",
 		rootedBasePath + "/" + indexhtml,
 	}
 
-	indexFilepath := filepath.Join(server.rootFilepath, server.basePath, indexhtml)
+	indexFilepath := filepath.Join(server.rootFilepath, basePath, indexhtml)
+	serveIndex := func(w http.ResponseWriter) {
+		bytes, err := ioutil.ReadFile(indexFilepath)
+		if err != nil {
+			logging.Error("index", logging.Fields{"file": indexFilepath, "error": err}, "Failed to load index at: %s", indexFilepath)
+			status := http.StatusInternalServerError
+			if os.IsNotExist(err) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, "Failed to load "+indexhtml, status)
+			return
+		}
+		indexHTML := server.rewriter.Rewrite(rootedBasePath+"/"+indexhtml, string(bytes))
+		if server.isIndexInjected() {
+			indexHTML = server.createIndexInjector(rootedBasePath).Inject(indexHTML)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, indexHTML)
+	}
+
 	indexHandler := func(w http.ResponseWriter, r *http.Request) {
-		for _, path := range acceptedIndexPaths {
-			if r.URL.Path == path {
-				bytes, err := ioutil.ReadFile(indexFilepath)
-				if err != nil {
-					log.Printf("ERROR: Failed to load index at: %s", indexFilepath)
+		if server.isIndexInjected() {
+			for _, path := range acceptedIndexPaths {
+				if r.URL.Path == path {
+					serveIndex(w)
 					return
 				}
-				indexHTML := string(bytes)
-				var injectedIndexHTML string
-				injectedIndexHTML = InjectSrcJavascript(indexHTML, swarmify(cssEscapePolyfillFilename), false)
-				injectedIndexHTML = InjectSrcJavascript(injectedIndexHTML, swarmify(hotReloadFilename), true)
-				w.Header().Set("Content-Type", "text/html; charset=utf-8")
-				io.WriteString(w, injectedIndexHTML)
-				return
 			}
 		}
 
+		if server.isHistoryFallback(r, rootedBasePath) {
+			serveIndex(w)
+			return
+		}
+
 		fileServer.ServeHTTP(w, r)
 	}
 
 	mux.HandleFunc(rootedBasePath+"/", indexHandler)
 }
 
-// TriggerFullReload causes a full HTML reload to be fired
+// isIndexInjected tests whether anything is injected into index.html
+func (server *Server) isIndexInjected() bool {
+	return server.hub != nil || server.inject != nil
+}
+
+// createIndexInjector collects the tags to inject into the index.html beneath a base path: inline config and head
+// scripts, preload links for the bundles, body scripts, then the hot reload scripts
+func (server *Server) createIndexInjector(rootedBasePath string) *HTMLInjector {
+	injector := NewHTMLInjector()
+	if inject := server.inject; inject != nil {
+		if inject.BaseHref {
+			injector.SetBaseHref(strings.TrimSuffix(rootedBasePath, "/") + "/")
+		}
+
+		var names []string
+		for name := range inject.Config {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			nameJSON, _ := json.Marshal(name)
+			injector.AppendHead(createInlineScript(fmt.Sprintf("window[%s] = %s;", nameJSON, inject.Config[name]), false))
+		}
+
+		for _, script := range inject.Head {
+			injector.AppendHead(createInjectScript(script))
+		}
+		if inject.Preload {
+			for _, bundlePath := range server.bundlePaths(rootedBasePath) {
+				injector.AppendHead(createPreloadLink(bundlePath))
+			}
+		}
+		for _, script := range inject.Body {
+			injector.AppendBody(createInjectScript(script))
+		}
+	}
+
+	if server.hub != nil {
+		injector.AppendBody(createSrcScript(swarmify(cssEscapePolyfillFilename), false))
+		injector.AppendBody(createSrcScript(swarmify(hotReloadFilename), true))
+	}
+	return injector
+}
+
+func createInjectScript(script *config.InjectScript) string {
+	if script.Src != "" {
+		return createSrcScript(script.Src, script.Module)
+	}
+	return createInlineScript(script.Inline, script.Module)
+}
+
+// bundlePaths gets the sorted paths of the generated javascript bundles beneath a base path.  The bundles of
+// interpolation variants (e.g. main@fr.js) are left out, since a page only loads one of them
+func (server *Server) bundlePaths(rootedBasePath string) []string {
+	server.handlersLock.RLock()
+	defer server.handlersLock.RUnlock()
+
+	prefix := strings.TrimSuffix(rootedBasePath, "/") + "/"
+	var paths []string
+	for handlerPath := range server.handlers {
+		if strings.HasSuffix(handlerPath, ".js") && strings.HasPrefix(handlerPath, prefix) && !strings.Contains(path.Base(handlerPath), "@") {
+			paths = append(paths, handlerPath)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// isHistoryFallback tests whether a request beneath a base path should be served index.html, because it's a page
+// request for a path that doesn't exist, which isn't an asset or excluded
+func (server *Server) isHistoryFallback(r *http.Request, rootedBasePath string) bool {
+	if server.fallback == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+
+	relativePath := strings.TrimPrefix(r.URL.Path, rootedBasePath+"/")
+	if path.Ext(relativePath) != "" || server.fallback.Matches(relativePath) {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(server.rootFilepath, filepath.FromSlash(r.URL.Path))); err == nil {
+		return false
+	}
+	return true
+}
+
+// TriggerFullReload causes a full HTML reload of every page to be fired
 func (server *Server) TriggerFullReload() {
-	server.hub.broadcast("reload", "")
+	server.TriggerReload(nil)
+}
+
+// TriggerReload causes a full HTML reload of the pages which loaded any of the bundles (or every page, if nil)
+func (server *Server) TriggerReload(bundles []string) {
+	server.hub.target("reload", "", bundles)
 }
 
 // ReloadCSSPayloadData encapsulates the data to reload a specific style sheet
@@ -201,19 +352,74 @@ type ReloadCSSPayloadData struct {
 	CSS string `json:"css"`
 }
 
-// TriggerCSSReload causes a CSS-only reload to be fired
-func (server *Server) TriggerCSSReload(path string, css string) {
+// TriggerCSSReload causes a CSS-only reload of the pages which loaded any of the bundles (or every page, if nil)
+func (server *Server) TriggerCSSReload(path string, css string, bundles []string) {
 	cssReloadData := &ReloadCSSPayloadData{
 		ID:  source.CSSPrefix + path,
 		CSS: css,
 	}
 	jsonBytes, _ := json.Marshal(cssReloadData)
-	server.hub.broadcast("reload-css", string(jsonBytes))
+	server.hub.target("reload-css", string(jsonBytes), bundles)
 }
 
-// URL gets the localhost URL for this server
+// ReloadTemplatePayloadData encapsulates the data to replace a template, under its module ID
+type ReloadTemplatePayloadData struct {
+	ID       string `json:"id"`
+	Template string `json:"template"`
+}
+
+// TriggerTemplateReload sends a changed template (e.g. an .html file loaded through SystemJS) to the pages which
+// loaded any of the bundles (or every page, if nil), so that they can swap it in place
+func (server *Server) TriggerTemplateReload(id string, template string, bundles []string) {
+	templateReloadData := &ReloadTemplatePayloadData{
+		ID:       id,
+		Template: template,
+	}
+	jsonBytes, _ := json.Marshal(templateReloadData)
+	server.hub.target("reload-template", string(jsonBytes), bundles)
+}
+
+// ReloadValuePayloadData encapsulates the data to replace the value of a module, e.g. a JSON file, under its module ID
+type ReloadValuePayloadData struct {
+	ID    string          `json:"id"`
+	Value json.RawMessage `json:"value"`
+}
+
+// TriggerValueReload sends the new value of a module (e.g. a JSON file loaded through SystemJS) to the pages which
+// loaded any of the bundles (or every page, if nil), so that they can swap it in place.  The value must be valid JSON
+func (server *Server) TriggerValueReload(id string, valueJSON string, bundles []string) {
+	valueReloadData := &ReloadValuePayloadData{
+		ID:    id,
+		Value: json.RawMessage(valueJSON),
+	}
+	jsonBytes, _ := json.Marshal(valueReloadData)
+	server.hub.target("reload-value", string(jsonBytes), bundles)
+}
+
+// URL gets the localhost URL for this server (for the first build)
 func (server *Server) URL() string {
-	return fmt.Sprintf("http://localhost:%d/%s", server.Port(), server.basePath)
+	basePath := ""
+	if len(server.basePaths) > 0 {
+		basePath = server.basePaths[0]
+	}
+	return server.urlFor(basePath)
+}
+
+// URLs gets the localhost URL for each build served by this server
+func (server *Server) URLs() []string {
+	urls := make([]string, len(server.basePaths))
+	for i, basePath := range server.basePaths {
+		urls[i] = server.urlFor(basePath)
+	}
+	return urls
+}
+
+func (server *Server) urlFor(basePath string) string {
+	scheme := "http"
+	if server.https {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://localhost:%d/%s", scheme, server.Port(), basePath)
 }
 
 // IsHotReloadEnabled gets whether hot reload is enabled
@@ -221,6 +427,14 @@ func (server *Server) IsHotReloadEnabled() bool {
 	return server.hub != nil
 }
 
+// ClientCount gets the number of browsers connected for hot reload
+func (server *Server) ClientCount() int {
+	if server.hub == nil {
+		return 0
+	}
+	return server.hub.ClientCount()
+}
+
 // Port gets the port number for this server
 func (server *Server) Port() uint16 {
 	return server.port
@@ -237,6 +451,9 @@ func (server *Server) Stop() {
 		server.srv.Shutdown(ctx)
 		server.srv = nil
 	}
+	if server.socketEvents != nil {
+		server.socketEvents.Close()
+	}
 	if server.hub != nil {
 		server.hub.stop()
 	}
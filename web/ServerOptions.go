@@ -1,8 +1,10 @@
 package web
 
 import (
+	"errors"
 	"net/http"
 	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/logging"
 )
 
 // ServerOptions specifies the parameters for the web server
@@ -11,7 +13,14 @@ type ServerOptions struct {
 	Port            uint16
 	EnableHotReload bool
 	Handlers        map[string]http.HandlerFunc
-	BasePath        string
+	BasePaths       []string // one per build
+	HTTPS           bool
+	CertFile        string
+	KeyFile         string
+	Proxy           map[string]*config.ProxyRoute // path prefix --> upstream
+	HistoryFallback *config.HistoryFallbackConfig // nil if disabled
+	Rewrite         []*config.RewriteRule
+	Inject          *config.InjectConfig // nil if nothing is configured to be injected
 }
 
 // CreateServerOptions forms a server options object from various sources
@@ -19,13 +28,53 @@ func CreateServerOptions(
 	rootFilepath string,
 	serverConfig *config.ServerConfig,
 	handlers map[string]http.HandlerFunc,
-	basePath string,
+	basePaths ...string,
 ) *ServerOptions {
+	var historyFallback *config.HistoryFallbackConfig
+	if serverConfig.IsHistoryFallbackEnabled() {
+		historyFallback = serverConfig.HistoryFallback
+	}
+
 	return &ServerOptions{
 		RootFilepath:    rootFilepath,
 		Port:            serverConfig.Port,
 		EnableHotReload: serverConfig.HotReload,
 		Handlers:        handlers,
-		BasePath:        basePath,
+		BasePaths:       basePaths,
+		HTTPS:           serverConfig.HTTPS,
+		CertFile:        serverConfig.CertFile,
+		KeyFile:         serverConfig.KeyFile,
+		Proxy:           serverConfig.Proxy,
+		HistoryFallback: historyFallback,
+		Rewrite:         serverConfig.Rewrite,
+		Inject:          serverConfig.Inject,
+	}
+}
+
+// ResolveCertificate generates (or reuses) a self-signed localhost certificate, if HTTPS is enabled without one.
+// A certificate and its key must be configured together
+func (opts *ServerOptions) ResolveCertificate() error {
+	if !opts.HTTPS {
+		return nil
+	}
+	if opts.CertFile != "" && opts.KeyFile == "" {
+		return errors.New("server.certFile is set, but server.keyFile isn't (both are needed to use your own certificate)")
+	}
+	if opts.KeyFile != "" && opts.CertFile == "" {
+		return errors.New("server.keyFile is set, but server.certFile isn't (both are needed to use your own certificate)")
+	}
+	if opts.CertFile != "" {
+		return nil
+	}
+
+	cacheDir, err := certificateCacheDir()
+	if err != nil {
+		return err
+	}
+	opts.CertFile, opts.KeyFile, err = ensureLocalhostCertificate(cacheDir)
+	if err != nil {
+		return err
 	}
+	logging.Info("certificate", logging.Fields{"file": opts.CertFile}, "Using self-signed certificate: %s (trust it to avoid browser warnings)", opts.CertFile)
+	return nil
 }
@@ -1,8 +1,11 @@
 package web
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -73,6 +76,13 @@ func TestURL(t *testing.T) {
 	assert.Equal(t, "http://localhost:9001/app", actual)
 }
 
+func TestURLs(t *testing.T) {
+	opts := CreateServerOptions("", config.NewServerConfig(9001, false, true), nil, "app", "controlpanel")
+	server := CreateServer(opts)
+	assert.Equal(t, []string{"http://localhost:9001/app", "http://localhost:9001/controlpanel"}, server.URLs())
+	assert.Equal(t, "http://localhost:9001/app", server.URL())
+}
+
 func TestPort(t *testing.T) {
 	server, _ := createWebServer("")
 	actual := server.Port()
@@ -85,7 +95,11 @@ func TestMakeServerAddress(t *testing.T) {
 }
 
 func TestSystemJSConfigRewritePaths(t *testing.T) {
-	actual := rewriteSystemJSConfigPaths(systemJSExample)
+	rewriter, err := NewRewriter([]*config.RewriteRule{
+		{Path: "systemjs.config.js", Match: `"\./(common|services|utils)",`, Regex: true, Replace: `"../$1", /* <-- REWRITTEN BY SWARM */`},
+	})
+	assert.Nil(t, err)
+	actual := rewriter.Rewrite("/app/systemjs.config.js", systemJSExample)
 	assert.Equal(t, systemJSExpected, actual)
 }
 
@@ -121,7 +135,7 @@ func TestIndexInjectionListener(t *testing.T) {
 
 	server, mux := createWebServer(tempDir)
 	fileServer := http.FileServer(http.Dir(server.rootFilepath))
-	server.attachIndexInjectionListener(mux, fileServer)
+	server.attachIndexInjectionListener(mux, fileServer, "app")
 
 	cases := map[string]struct {
 		url      string
@@ -155,6 +169,136 @@ func TestIndexInjectionListener(t *testing.T) {
 	}
 }
 
+func TestIndexInjectConfig(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+	appDir := testutil.MakeSubdirectoryTree(tempDir, "app")
+	testutil.WriteTextFile(appDir, "index.html", `<html><head><base href="."></head><body>HELLO WORLD</body></html>`)
+
+	server, mux := createWebServer(tempDir)
+	server.hub = nil
+	server.inject = &config.InjectConfig{
+		Head:     []*config.InjectScript{{Src: "/polyfills.js"}},
+		Body:     []*config.InjectScript{{Inline: "start();", Module: true}},
+		Config:   map[string]json.RawMessage{"appConfig": json.RawMessage(`{"api": "/api"}`)},
+		Preload:  true,
+		BaseHref: true,
+	}
+	server.SetHandlers(map[string]http.HandlerFunc{
+		"/app/src/main.js":          nil,
+		"/app/src/main.js.map":      nil,
+		"/app/src/main@fr.js":       nil,
+		"/app/src/lib.js":           nil,
+		"/controlpanel/src/main.js": nil,
+	})
+	server.attachIndexInjectionListener(mux, http.FileServer(http.Dir(server.rootFilepath)), "app")
+
+	request, _ := http.NewRequest("GET", "/app/", nil)
+	writer := newMockWriter()
+	mux.ServeHTTP(writer, request)
+
+	expected := `<html><head><base href="/app/">` +
+		`<script type="text/javascript">window["appConfig"] = {"api": "/api"};</script>` +
+		`<script type="text/javascript" src="/polyfills.js"></script>` +
+		`<link rel="preload" href="/app/src/lib.js" as="script">` +
+		`<link rel="preload" href="/app/src/main.js" as="script">` +
+		`</head><body>HELLO WORLD<script type="module">start();</script></body></html>`
+	assert.Equal(t, expected, writer.sb.String())
+}
+
+func TestHistoryFallback(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+	appDir := testutil.MakeSubdirectoryTree(tempDir, "app")
+	testutil.WriteTextFile(appDir, "index.html", "<body>INDEX</body>")
+	testutil.WriteTextFile(appDir, "real.html", "<body>REAL</body>")
+
+	serverConfig := config.NewServerConfig(9001, false, false)
+	serverConfig.HistoryFallback = &config.HistoryFallbackConfig{Enabled: true, Exclude: []string{"api/"}}
+	server := CreateServer(CreateServerOptions(tempDir, serverConfig, nil, "app"))
+	mux := http.NewServeMux()
+	fileServer := server.attachStaticFileServer(mux)
+	server.attachIndexInjectionListener(mux, fileServer, "app")
+
+	const htmlAccept = "text/html,application/xhtml+xml,*/*;q=0.8"
+	cases := map[string]struct {
+		method   string
+		url      string
+		accept   string
+		status   int
+		expected string
+	}{
+		"deep-link":        {url: "/app/students/42", accept: htmlAccept, status: http.StatusOK, expected: "<body>INDEX</body>"},
+		"existing-file":    {url: "/app/real.html", accept: htmlAccept, status: http.StatusOK, expected: "<body>REAL</body>"},
+		"missing-asset":    {url: "/app/students/42.js", accept: htmlAccept, status: http.StatusNotFound},
+		"excluded":         {url: "/app/api/students", accept: htmlAccept, status: http.StatusNotFound},
+		"not-html":         {url: "/app/students/42", accept: "application/json", status: http.StatusNotFound},
+		"not-get":          {method: "POST", url: "/app/students/42", accept: htmlAccept, status: http.StatusNotFound},
+		"outside-basehref": {url: "/other/students/42", accept: htmlAccept, status: http.StatusNotFound},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = "GET"
+			}
+			request, _ := http.NewRequest(method, tc.url, nil)
+			request.Header.Set("Accept", tc.accept)
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tc.status, recorder.Code)
+			if tc.expected != "" {
+				assert.Equal(t, tc.expected, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestHistoryFallbackMissingIndex(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+	testutil.MakeSubdirectoryTree(tempDir, "app")
+
+	serverConfig := config.NewServerConfig(9001, false, false)
+	serverConfig.HistoryFallback = &config.HistoryFallbackConfig{Enabled: true}
+	server := CreateServer(CreateServerOptions(tempDir, serverConfig, nil, "app"))
+	mux := http.NewServeMux()
+	server.attachIndexInjectionListener(mux, server.attachStaticFileServer(mux), "app")
+
+	// deep links fail visibly, rather than as empty pages
+	request, _ := http.NewRequest("GET", "/app/students/42", nil)
+	request.Header.Set("Accept", "text/html")
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Failed to load index.html")
+}
+
+func TestSetHandlers(t *testing.T) {
+	server, mux := createWebServer("")
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "fallback") })
+	dispatcher := server.customHandlerDispatcher(mux)
+	serve := func(url string) string {
+		request, _ := http.NewRequest("GET", url, nil)
+		writer := newMockWriter()
+		dispatcher.ServeHTTP(writer, request)
+		return writer.sb.String()
+	}
+
+	server.SetHandlers(map[string]http.HandlerFunc{
+		"/app/main.js": func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "before") },
+	})
+	assert.Equal(t, "before", serve("/app/main.js"))
+	assert.Equal(t, "fallback", serve("/app/other.js"))
+
+	server.SetHandlers(map[string]http.HandlerFunc{
+		"/app/other.js": func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "after") },
+	})
+	assert.Equal(t, "fallback", serve("/app/main.js"))
+	assert.Equal(t, "after", serve("/app/other.js"))
+}
+
 func TestHotReloadScripts(t *testing.T) {
 	server, mux := createWebServer("c:\\")
 	// configure files and server
@@ -1,11 +1,12 @@
 package web
 
 import (
-	"log"
+	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/mrcrowl/swarm/logging"
 )
 
 const (
@@ -18,8 +19,8 @@ const (
 	// Send pings to peer with this period. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512
+	// Maximum message size allowed from peer (large enough to register a page with many bundles).
+	maxMessageSize = 64 * 1024
 )
 
 var (
@@ -41,6 +42,9 @@ type SocketClient struct {
 
 	// Buffered channel of outbound messages.
 	send chan []byte
+
+	// The URL of the client's page, once registered (only used by readPump).
+	url string
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -57,15 +61,54 @@ func (client *SocketClient) readPump() {
 	client.ws.SetReadDeadline(time.Now().Add(pongWait))
 	client.ws.SetPongHandler(func(string) error { client.ws.SetReadDeadline(time.Now().Add(pongWait)); return nil })
 	for {
-		_, _, err := client.ws.ReadMessage()
+		_, data, err := client.ws.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error: %v", err)
+				logging.Error("websocket", logging.Fields{"error": err}, "Websocket closed unexpectedly: %s", err)
 			}
 			break
 		}
-		// message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
-		// client.hub.broadcast <- message
+		client.receive(data)
+	}
+}
+
+// receive handles a message from the client: registrations are passed to the hub (to target messages), and failed
+// acknowledgements are reported
+func (client *SocketClient) receive(data []byte) {
+	message, err := parseClientMessage(data)
+	if err != nil {
+		logging.Warn("hot-reload-client", logging.Fields{"error": err}, "%s", err)
+		return
+	}
+
+	switch message.Type {
+	case clientRegister:
+		registration := &ClientRegistration{}
+		if err := json.Unmarshal(message.Data, registration); err != nil {
+			logging.Warn("hot-reload-client", logging.Fields{"error": err}, "Invalid hot reload registration: %s", err)
+			return
+		}
+		client.url = registration.URL
+		client.hub.registrationChannel <- &clientRegistrationUpdate{client, registration}
+		fields := logging.Fields{"url": registration.URL, "baseHref": registration.BaseHref, "bundles": registration.Bundles}
+		logging.Debug("hot-reload-client", fields, "Hot reload client registered: %s", registration.URL)
+
+	case clientAck:
+		ack := &ClientAck{}
+		if err := json.Unmarshal(message.Data, ack); err != nil {
+			logging.Warn("hot-reload-client", logging.Fields{"error": err}, "Invalid hot reload acknowledgement: %s", err)
+			return
+		}
+		fields := logging.Fields{"id": ack.ID, "url": client.url}
+		if ack.Error != "" {
+			fields["error"] = ack.Error
+			logging.Error("reload-failed", fields, "Hot reload failed in %s: %s", client.url, ack.Error)
+		} else {
+			logging.Debug("reload-applied", fields, "Hot reload applied in %s", client.url)
+		}
+
+	default:
+		logging.Warn("hot-reload-client", logging.Fields{"type": message.Type}, "Unknown hot reload message: %s", message.Type)
 	}
 }
 
@@ -120,7 +163,7 @@ func (client *SocketClient) writePump() {
 func serveWebsocket(hub *SocketHub, w http.ResponseWriter, r *http.Request) {
 	socket, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade socket: %s\n", err)
+		logging.Error("websocket", logging.Fields{"error": err}, "Failed to upgrade socket: %s", err)
 		return
 	}
 	client := &SocketClient{hub: hub, ws: socket, send: make(chan []byte, 256)}
@@ -2,20 +2,31 @@ package web
 
 import (
 	"encoding/json"
+	"sync/atomic"
 	"time"
 )
 
-// SocketHub maintains the set of active clients and broadcasts messages to them
+// SocketHub maintains the set of active clients and sends messages to them, either to all of them, or targeted at
+// the clients which loaded particular bundles
 type SocketHub struct {
-	// registered clients.
-	clients map[*SocketClient]bool
+	// connected clients, and how they registered (nil until they do).
+	clients map[*SocketClient]*ClientRegistration
+
+	// the number of registered clients, which can be read from other goroutines
+	clientCount int32
+
+	// the id of the last message sent.
+	lastID int64
 
 	// used to broadcast to clients.
-	broadcastChannel chan []byte
+	broadcastChannel chan *hubMessage
 
 	// register requests from the clients.
 	registerChannel chan *SocketClient
 
+	// registration messages from the clients.
+	registrationChannel chan *clientRegistrationUpdate
+
 	// unregister requests from clients.
 	unregisterChannel chan *SocketClient
 
@@ -25,63 +36,106 @@ type SocketHub struct {
 
 func newSocketHub() *SocketHub {
 	return &SocketHub{
-		broadcastChannel:  make(chan []byte),
-		registerChannel:   make(chan *SocketClient),
-		unregisterChannel: make(chan *SocketClient),
-		stopChannel:       make(chan bool),
-		clients:           make(map[*SocketClient]bool),
+		broadcastChannel:    make(chan *hubMessage),
+		registerChannel:     make(chan *SocketClient),
+		unregisterChannel:   make(chan *SocketClient),
+		registrationChannel: make(chan *clientRegistrationUpdate),
+		stopChannel:         make(chan bool),
+		clients:             make(map[*SocketClient]*ClientRegistration),
 	}
 }
 
+// hubMessage is a message for the clients which loaded any of the bundles (or every client, if bundles is nil)
+type hubMessage struct {
+	payload []byte
+	bundles []string
+}
+
+type clientRegistrationUpdate struct {
+	client       *SocketClient
+	registration *ClientRegistration
+}
+
 const (
 	messageInterval = 2 * time.Second
 )
 
 // SocketPayload encapsulates a message to the client
 type SocketPayload struct {
-	Type string `json:"type"`
-	Data string `json:"data"`
+	Version int    `json:"v"`
+	ID      int64  `json:"id"` // for clients to acknowledge the message
+	Type    string `json:"type"`
+	Data    string `json:"data"`
+}
+
+// broadcast sends a message to every client
+func (hub *SocketHub) broadcast(typ string, data string) int64 {
+	return hub.target(typ, data, nil)
 }
 
-func (hub *SocketHub) broadcast(typ string, data string) {
+// target sends a message to the clients which loaded any of the bundles, or to every client if bundles is nil.
+// Returns the message's id
+func (hub *SocketHub) target(typ string, data string, bundles []string) int64 {
+	message := &SocketPayload{Version: socketProtocolVersion, ID: atomic.AddInt64(&hub.lastID, 1), Type: typ, Data: data}
+	jsonBytes, _ := json.Marshal(message)
 	go func() {
-		message := &SocketPayload{Type: typ, Data: data}
-		jsonBytes, _ := json.Marshal(message)
-		hub.broadcastChannel <- jsonBytes
+		hub.broadcastChannel <- &hubMessage{jsonBytes, bundles}
 	}()
+	return message.ID
 }
 
 func (hub *SocketHub) run() {
 	for {
 		select {
 		case client := <-hub.registerChannel:
-			hub.clients[client] = true
+			hub.clients[client] = nil
+			hub.countClients()
+
+		case update := <-hub.registrationChannel:
+			if _, ok := hub.clients[update.client]; ok {
+				hub.clients[update.client] = update.registration
+			}
 
 		case client := <-hub.unregisterChannel:
 			if _, ok := hub.clients[client]; ok {
 				delete(hub.clients, client)
 				close(client.send)
+				hub.countClients()
 			}
 
 		case message := <-hub.broadcastChannel:
-			for client := range hub.clients {
+			for client, registration := range hub.clients {
+				if !registration.hasLoadedAny(message.bundles) {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- message.payload:
 				default:
 					close(client.send)
 					delete(hub.clients, client)
 				}
 			}
+			hub.countClients()
 
 		case <-hub.stopChannel:
 			for client := range hub.clients {
 				close(client.send)
 				delete(hub.clients, client)
 			}
+			hub.countClients()
 		}
 	}
 }
 
+// ClientCount gets the number of connected clients
+func (hub *SocketHub) ClientCount() int {
+	return int(atomic.LoadInt32(&hub.clientCount))
+}
+
+func (hub *SocketHub) countClients() {
+	atomic.StoreInt32(&hub.clientCount, int32(len(hub.clients)))
+}
+
 func (hub *SocketHub) stop() {
 	hub.stopChannel <- true
 }
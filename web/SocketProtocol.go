@@ -0,0 +1,71 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// socketProtocolVersion is the version of the hot reload protocol.  Version 1 only sent messages to clients, and
+// its clients never registered, so they're still sent every message
+const socketProtocolVersion = 2
+
+// Types of messages sent by hot reload clients
+const (
+	// clientRegister describes the page a client is on, and the bundles it loaded.  It can be sent again, e.g. once
+	// more bundles have been loaded
+	clientRegister = "register"
+	// clientAck acknowledges that a message from the server was applied, or explains why it couldn't be
+	clientAck = "ack"
+)
+
+// ClientMessage is a message from a hot reload client
+type ClientMessage struct {
+	Version int             `json:"v"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// ClientRegistration is the data of a register message
+type ClientRegistration struct {
+	URL      string   `json:"url"`
+	BaseHref string   `json:"baseHref"`
+	Bundles  []string `json:"bundles"` // the URL paths of the scripts the page loaded, e.g. /app/src/main.js
+}
+
+// ClientAck is the data of an ack message
+type ClientAck struct {
+	ID    int64  `json:"id"`              // the id of the message being acknowledged
+	Error string `json:"error,omitempty"` // why the message couldn't be applied, if it couldn't
+}
+
+// parseClientMessage decodes a message from a client, checking that it speaks the same version of the protocol
+func parseClientMessage(data []byte) (*ClientMessage, error) {
+	message := &ClientMessage{}
+	if err := json.Unmarshal(data, message); err != nil {
+		return nil, fmt.Errorf("Invalid hot reload message: %s", err)
+	}
+	if message.Version != socketProtocolVersion {
+		return nil, fmt.Errorf("Hot reload client uses protocol version %d, but swarm uses version %d (reloading the page should fix this)", message.Version, socketProtocolVersion)
+	}
+	if message.Type == "" {
+		return nil, errors.New("Invalid hot reload message: missing type")
+	}
+	return message, nil
+}
+
+// hasLoadedAny tests whether a registered client has loaded any of the bundles.  nil bundles target every client,
+// and clients that haven't registered (e.g. older clients) are assumed to have loaded everything
+func (registration *ClientRegistration) hasLoadedAny(bundles []string) bool {
+	if bundles == nil || registration == nil {
+		return true
+	}
+	for _, bundle := range bundles {
+		for _, loaded := range registration.Bundles {
+			if bundle == loaded {
+				return true
+			}
+		}
+	}
+	return false
+}
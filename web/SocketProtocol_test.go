@@ -0,0 +1,108 @@
+package web
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mrcrowl/swarm/logging"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClientMessage(t *testing.T) {
+	cases := map[string]struct {
+		message     string
+		expected    *ClientMessage
+		expectedErr string
+	}{
+		"register":     {`{"v":2,"type":"register","data":{"url":"http://localhost/app"}}`, &ClientMessage{2, "register", json.RawMessage(`{"url":"http://localhost/app"}`)}, ""},
+		"not json":     {`reload`, nil, "Invalid hot reload message: invalid character 'r' looking for beginning of value"},
+		"old version":  {`{"event":"register"}`, nil, "Hot reload client uses protocol version 0, but swarm uses version 2 (reloading the page should fix this)"},
+		"missing type": {`{"v":2}`, nil, "Invalid hot reload message: missing type"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			message, err := parseClientMessage([]byte(tc.message))
+			assert.Equal(t, tc.expected, message)
+			if tc.expectedErr == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestHasLoadedAny(t *testing.T) {
+	registration := &ClientRegistration{Bundles: []string{"/app/main.js", "/common/util.js"}}
+	assert.True(t, registration.hasLoadedAny(nil))
+	assert.True(t, registration.hasLoadedAny([]string{"/other.js", "/common/util.js"}))
+	assert.False(t, registration.hasLoadedAny([]string{"/other.js"}))
+	assert.True(t, (*ClientRegistration)(nil).hasLoadedAny([]string{"/other.js"}), "unregistered clients get everything")
+}
+
+func TestTargetedMessages(t *testing.T) {
+	hub := newSocketHub()
+	go hub.run()
+	defer hub.stop()
+	app := connectTestClient(hub, &ClientRegistration{URL: "http://localhost/app", Bundles: []string{"/app/main.js"}})
+	other := connectTestClient(hub, &ClientRegistration{URL: "http://localhost/other", Bundles: []string{"/other/main.js"}})
+	unregistered := connectTestClient(hub, nil)
+
+	id := hub.target("reload", "", []string{"/app/main.js"})
+	assert.Equal(t, &SocketPayload{socketProtocolVersion, id, "reload", ""}, receivePayload(t, app))
+	assert.Equal(t, &SocketPayload{socketProtocolVersion, id, "reload", ""}, receivePayload(t, unregistered))
+	assert.Nil(t, receivePayload(t, other))
+
+	id = hub.broadcast("build-end", "{}")
+	assert.Equal(t, id, receivePayload(t, other).ID)
+}
+
+func TestAcknowledgementFailuresAreReported(t *testing.T) {
+	hub := newSocketHub()
+	go hub.run()
+	defer hub.stop()
+	client := &SocketClient{hub: hub, send: make(chan []byte, 256)}
+	hub.registerChannel <- client
+
+	stop := logging.Default().Record(logging.LevelDebug)
+	client.receive([]byte(`{"v":2,"type":"register","data":{"url":"http://localhost/app","bundles":["/app/main.js"]}}`))
+	client.receive([]byte(`{"v":2,"type":"ack","data":{"id":3}}`))
+	client.receive([]byte(`{"v":2,"type":"ack","data":{"id":4,"error":"TypeError: x is undefined"}}`))
+	client.receive([]byte(`{"v":2,"type":"unknown"}`))
+	entries := stop()
+
+	var messages []string
+	for _, entry := range entries {
+		messages = append(messages, entry.Level.String()+": "+entry.Message)
+	}
+	assert.Equal(t, []string{
+		"debug: Hot reload client registered: http://localhost/app",
+		"debug: Hot reload applied in http://localhost/app",
+		"error: Hot reload failed in http://localhost/app: TypeError: x is undefined",
+		"warn: Unknown hot reload message: unknown",
+	}, messages)
+}
+
+// connectTestClient adds a client to a running hub, without a websocket
+func connectTestClient(hub *SocketHub, registration *ClientRegistration) *SocketClient {
+	client := &SocketClient{hub: hub, send: make(chan []byte, 256)}
+	hub.registerChannel <- client
+	if registration != nil {
+		hub.registrationChannel <- &clientRegistrationUpdate{client, registration}
+	}
+	return client
+}
+
+// receivePayload gets the next message sent to a client, or nil if there isn't one
+func receivePayload(t *testing.T, client *SocketClient) *SocketPayload {
+	select {
+	case message := <-client.send:
+		payload := &SocketPayload{}
+		assert.Nil(t, json.Unmarshal(message, payload))
+		return payload
+	case <-time.After(50 * time.Millisecond):
+		return nil
+	}
+}